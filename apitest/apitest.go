@@ -0,0 +1,198 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Dav16Akin/go-dictionary/cache"
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/problem"
+	"github.com/Dav16Akin/go-dictionary/ratelimit"
+)
+
+// Setup turns off rate limiting and response caching, which would make the answers depend on
+// the requests other tests made before, and the logs, which the failures already describe.
+// Call it from TestMain, before building any handler.
+func Setup() {
+	ratelimit.Setup(config.RateLimit{})
+	cache.Setup(config.Cache{})
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+}
+
+// Server is an httptest.Server closed when the test that started it ends.
+type Server struct {
+	*httptest.Server
+}
+
+// NewServer serves h until the test ends.
+func NewServer(t testing.TB, h http.Handler) *Server {
+	t.Helper()
+	s := httptest.NewServer(h)
+	t.Cleanup(s.Close)
+	return &Server{Server: s}
+}
+
+// Response is an answer read to the end, so a test can look at it as often as it likes.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Do sends a request to the server and reads the response. A string or []byte body is sent as
+// is, anything else but nil as JSON with a Content-Type to match. header holds name, value pairs,
+// a Content-Type among them overriding the JSON one.
+func (s *Server) Do(t testing.TB, method, path string, body any, header ...string) *Response {
+	t.Helper()
+
+	var reader io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			t.Fatalf("encoding request body: %v", err)
+		}
+		reader, contentType = bytes.NewReader(encoded), "application/json"
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if len(header)%2 != 0 {
+		t.Fatalf("header %q is not name, value pairs", header)
+	}
+	for i := 0; i < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	// the tests look at redirects and compressed bodies themselves
+	client := &http.Client{
+		Transport:     &http.Transport{DisableCompression: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	read, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: reading body: %v", method, path, err)
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: read}
+}
+
+// Status fails the test unless the response has status.
+func (r *Response) Status(t testing.TB, status int) *Response {
+	t.Helper()
+	if r.StatusCode != status {
+		t.Fatalf("status = %d, want %d; body: %s", r.StatusCode, status, r.Body)
+	}
+	return r
+}
+
+// Decode reads the JSON body into v.
+func (r *Response) Decode(t testing.TB, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("decoding %s: %v", r.Body, err)
+	}
+}
+
+// JSON fails the test unless the body is the same JSON value as want, whatever the spacing and
+// the order of object keys.
+func (r *Response) JSON(t testing.TB, want string) {
+	t.Helper()
+	EqualJSON(t, r.Body, want)
+}
+
+// Problem fails the test unless the body is an application/problem+json document with detail.
+func (r *Response) Problem(t testing.TB, detail string) {
+	t.Helper()
+	if ct := r.Header.Get("Content-Type"); ct != problem.MIME {
+		t.Fatalf("Content-Type = %q, want application/problem+json; body: %s", ct, r.Body)
+	}
+	var p struct {
+		Status int    `json:"status"`
+		Detail string `json:"detail"`
+	}
+	r.Decode(t, &p)
+	if p.Status != r.StatusCode || p.Detail != detail {
+		t.Fatalf("problem = %d %q, want %d %q", p.Status, p.Detail, r.StatusCode, detail)
+	}
+}
+
+// Error fails the test unless the body is a Gin {"error": message} object carrying the request
+// ID the response header names.
+func (r *Response) Error(t testing.TB, message string) {
+	t.Helper()
+	var e struct {
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	r.Decode(t, &e)
+	if e.Error != message {
+		t.Fatalf("error = %q, want %q", e.Error, message)
+	}
+	if id := r.Header.Get(logging.HeaderRequestID); e.RequestID == "" || e.RequestID != id {
+		t.Fatalf("request_id = %q, want the %s header %q", e.RequestID, logging.HeaderRequestID, id)
+	}
+}
+
+// EqualJSON fails the test unless got and want hold the same JSON value.
+func EqualJSON(t testing.TB, got []byte, want string) {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("got is not JSON: %v\n%s", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("want is not JSON: %v\n%s", err, want)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Fatalf("JSON differs\n got: %s\nwant: %s", bytes.TrimSpace(got), want)
+	}
+}
+
+// TempDB returns the path of a SQLite database file in a directory removed when the test ends.
+// The file does not exist yet, opening it creates it.
+func TempDB(t testing.TB) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), "test.db")
+}
+
+// CopyDB copies the database at src, such as a committed fixture, to a TempDB so the test can
+// change it.
+func CopyDB(t testing.TB, src string) string {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("reading %s: %v", src, err)
+	}
+	path := TempDB(t)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("copying %s: %v", src, err)
+	}
+	return path
+}
@@ -0,0 +1,221 @@
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// Format is one of the wire formats accepted by the bulk import and export endpoints.
+type Format string
+
+const (
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+)
+
+const (
+	MIMENDJSON = "application/x-ndjson"
+	MIMECSV    = "text/csv"
+)
+
+// MaxRows caps how many rows a single import can carry so one upload cannot exhaust memory.
+const MaxRows = 10000
+
+var ErrTooManyRows = fmt.Errorf("import exceeds the limit of %d rows", MaxRows)
+
+// ContentType returns the MIME type written for the format.
+func (f Format) ContentType() string {
+	switch f {
+	case NDJSON:
+		return MIMENDJSON
+	case CSV:
+		return MIMECSV
+	default:
+		return "application/json"
+	}
+}
+
+// FormatFromContentType maps a request Content-Type header to a Format.
+func FormatFromContentType(contentType string) (Format, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid Content-Type %q", contentType)
+	}
+
+	switch mediaType {
+	case "application/json":
+		return JSON, nil
+	case MIMENDJSON, "application/ndjson", "application/jsonl":
+		return NDJSON, nil
+	case MIMECSV:
+		return CSV, nil
+	}
+	return "", fmt.Errorf("unsupported Content-Type %q", mediaType)
+}
+
+// FormatForExport picks the export format from the ?format= query value and falls back to the Accept header.
+func FormatForExport(query, accept string) (Format, error) {
+	switch strings.ToLower(query) {
+	case "json":
+		return JSON, nil
+	case "ndjson", "jsonl":
+		return NDJSON, nil
+	case "csv":
+		return CSV, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported format %q", query)
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json", "*/*", "application/*":
+			return JSON, nil
+		case MIMENDJSON, "application/ndjson":
+			return NDJSON, nil
+		case MIMECSV, "text/*":
+			return CSV, nil
+		}
+	}
+
+	if accept == "" {
+		return JSON, nil
+	}
+	return "", fmt.Errorf("none of %q can be produced", accept)
+}
+
+// Row is one decoded input record. Err is set when the record itself could not be converted.
+type Row[T any] struct {
+	Line  int
+	Value T
+	Err   error
+}
+
+// Decode reads every record of r in the given format.
+// JSON and NDJSON rows are decoded straight into T; CSV rows are keyed by the header line and handed to fromCSV.
+// A malformed document is returned as an error, a malformed CSV value only fails its own row.
+func Decode[T any](r io.Reader, f Format, fromCSV func(record map[string]string) (T, error)) ([]Row[T], error) {
+	switch f {
+	case JSON:
+		return decodeJSONArray[T](r)
+	case NDJSON:
+		return decodeNDJSON[T](r)
+	case CSV:
+		return decodeCSV(r, fromCSV)
+	}
+	return nil, fmt.Errorf("unsupported format %q", f)
+}
+
+func decodeJSONArray[T any](r io.Reader) ([]Row[T], error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("JSON body must be an array")
+	}
+
+	var rows []Row[T]
+	for decoder.More() {
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+
+		var value T
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid JSON at element %d: %w", len(rows)+1, err)
+		}
+		rows = append(rows, Row[T]{Line: len(rows) + 1, Value: value})
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return rows, nil
+}
+
+func decodeNDJSON[T any](r io.Reader) ([]Row[T], error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []Row[T]
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+
+		var value T
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&value)
+		if err != nil {
+			err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		rows = append(rows, Row[T]{Line: line, Value: value, Err: err})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func decodeCSV[T any](r io.Reader, fromCSV func(record map[string]string) (T, error)) ([]Row[T], error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV body is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var rows []Row[T]
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+
+		fields := make(map[string]string, len(header))
+		for i, name := range header {
+			fields[name] = record[i]
+		}
+
+		line, _ := reader.FieldPos(0)
+		value, err := fromCSV(fields)
+		rows = append(rows, Row[T]{Line: line, Value: value, Err: err})
+	}
+	return rows, nil
+}
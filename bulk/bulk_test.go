@@ -0,0 +1,123 @@
+package bulk
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type row struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func rowFromCSV(record map[string]string) (row, error) {
+	age, err := strconv.Atoi(record["age"])
+	if err != nil {
+		return row{}, errors.New("age must be a number")
+	}
+	return row{Name: record["name"], Age: age}, nil
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		body    string
+		want    []Row[row]
+		rowErrs []bool
+	}{
+		{"json", JSON, `[{"name":"ada","age":36},{"name":"alan","age":41}]`,
+			[]Row[row]{{Line: 1, Value: row{"ada", 36}}, {Line: 2, Value: row{"alan", 41}}}, []bool{false, false}},
+		{"json empty", JSON, `[]`, nil, nil},
+		{"ndjson", NDJSON, "{\"name\":\"ada\",\"age\":36}\n\n{\"name\":\"alan\",\"age\":41}\n",
+			[]Row[row]{{Line: 1, Value: row{"ada", 36}}, {Line: 3, Value: row{"alan", 41}}}, []bool{false, false}},
+		{"ndjson bad line", NDJSON, "{\"name\":\"ada\"}\n{\"name\":\n{\"nick\":\"x\"}\n",
+			[]Row[row]{{Line: 1, Value: row{Name: "ada"}}, {Line: 2}, {Line: 3}}, []bool{false, true, true}},
+		{"csv", CSV, "Name, Age\nada,36\nalan,41\n",
+			[]Row[row]{{Line: 2, Value: row{"ada", 36}}, {Line: 3, Value: row{"alan", 41}}}, []bool{false, false}},
+		{"csv bad value", CSV, "name,age\nada,old\n",
+			[]Row[row]{{Line: 2}}, []bool{true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := Decode(strings.NewReader(tt.body), tt.format, rowFromCSV)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(tt.want))
+			}
+			for i, got := range rows {
+				if got.Line != tt.want[i].Line || got.Value != tt.want[i].Value || (got.Err != nil) != tt.rowErrs[i] {
+					t.Errorf("row %d = %+v, want %+v with error %v", i, got, tt.want[i], tt.rowErrs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		body   string
+		want   string
+	}{
+		{"json not array", JSON, `{"name":"ada"}`, "JSON body must be an array"},
+		{"json empty body", JSON, ``, "invalid JSON body"},
+		{"json bad element", JSON, `[{"name":"ada"},{"name":1}]`, "invalid JSON at element 2"},
+		{"json unknown field", JSON, `[{"nick":"ada"}]`, "invalid JSON at element 1"},
+		{"json unterminated", JSON, `[{"name":"ada"}`, "invalid JSON"},
+		{"csv empty", CSV, ``, "CSV body is empty"},
+		{"csv bad header", CSV, "\"name,age\n", "invalid CSV header"},
+		{"csv short record", CSV, "name,age\nada\n", "invalid CSV"},
+		{"unknown format", Format("xml"), `<rows/>`, "unsupported format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(strings.NewReader(tt.body), tt.format, rowFromCSV)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeTooManyRows(t *testing.T) {
+	bodies := map[Format]string{
+		JSON:   "[" + strings.Repeat(`{"name":"a"},`, MaxRows) + `{"name":"a"}]`,
+		NDJSON: strings.Repeat("{\"name\":\"a\"}\n", MaxRows+1),
+		CSV:    "name,age\n" + strings.Repeat("a,1\n", MaxRows+1),
+	}
+	for format, body := range bodies {
+		t.Run(string(format), func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(body), format, rowFromCSV); !errors.Is(err, ErrTooManyRows) {
+				t.Errorf("got %v, want %v", err, ErrTooManyRows)
+			}
+		})
+	}
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        Format
+		wantErr     bool
+	}{
+		{"application/json", JSON, false},
+		{"application/json; charset=utf-8", JSON, false},
+		{"application/x-ndjson", NDJSON, false},
+		{"application/jsonl", NDJSON, false},
+		{"text/csv", CSV, false},
+		{"application/xml", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := FormatFromContentType(tt.contentType)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("FormatFromContentType(%q) = %q, %v", tt.contentType, got, err)
+		}
+	}
+}
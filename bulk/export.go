@@ -0,0 +1,117 @@
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// flushEvery is how many rows are buffered before an export pushes them to the client.
+const flushEvery = 100
+
+// Writer streams rows to w in one of the export formats without holding the whole table in memory.
+type Writer[T any] struct {
+	format   Format
+	out      *bufio.Writer
+	flusher  http.Flusher
+	csv      *csv.Writer
+	json     *json.Encoder
+	toRecord func(T) []string
+	header   []string
+	count    int
+}
+
+// NewWriter prepares an export. header and toRecord are only used for CSV.
+func NewWriter[T any](w io.Writer, f Format, header []string, toRecord func(T) []string) *Writer[T] {
+	out := bufio.NewWriter(w)
+	writer := &Writer[T]{format: f, out: out, toRecord: toRecord, header: header}
+
+	if flusher, ok := w.(http.Flusher); ok {
+		writer.flusher = flusher
+	}
+
+	if f == CSV {
+		writer.csv = csv.NewWriter(out)
+	} else {
+		writer.json = json.NewEncoder(out)
+	}
+	return writer
+}
+
+// Write appends one row to the export.
+func (w *Writer[T]) Write(value T) error {
+	if w.count == 0 {
+		if err := w.begin(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	switch w.format {
+	case CSV:
+		err = w.csv.Write(w.toRecord(value))
+	case JSON:
+		if w.count > 0 {
+			if _, err = w.out.WriteString(","); err != nil {
+				return err
+			}
+		}
+		err = w.json.Encode(value)
+	default:
+		err = w.json.Encode(value)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.count++
+	if w.count%flushEvery == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close terminates the document and flushes whatever is still buffered.
+func (w *Writer[T]) Close() error {
+	if w.count == 0 {
+		if err := w.begin(); err != nil {
+			return err
+		}
+	}
+
+	if w.format == JSON {
+		if _, err := w.out.WriteString("]\n"); err != nil {
+			return err
+		}
+	}
+	return w.flush()
+}
+
+func (w *Writer[T]) begin() error {
+	switch w.format {
+	case CSV:
+		return w.csv.Write(w.header)
+	case JSON:
+		_, err := w.out.WriteString("[")
+		return err
+	}
+	return nil
+}
+
+func (w *Writer[T]) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := w.out.Flush(); err != nil {
+		return err
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return nil
+}
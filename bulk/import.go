@@ -0,0 +1,135 @@
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+)
+
+// Mode decides what happens to the good rows of an import when some rows fail.
+type Mode string
+
+const (
+	// AllOrNothing commits only when every row succeeds.
+	AllOrNothing Mode = "all-or-nothing"
+	// BestEffort commits the rows that succeeded and reports the rest.
+	BestEffort Mode = "best-effort"
+)
+
+// ParseMode reads the ?mode= query value, defaulting to AllOrNothing.
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", string(AllOrNothing), "atomic":
+		return AllOrNothing, nil
+	case string(BestEffort):
+		return BestEffort, nil
+	}
+	return "", fmt.Errorf("unknown mode %q, expected %q or %q", s, AllOrNothing, BestEffort)
+}
+
+const (
+	StatusCreated    = "created"
+	StatusFailed     = "failed"
+	StatusRolledBack = "rolled_back"
+)
+
+// RowResult is the outcome of one input row.
+type RowResult struct {
+	Row    int    `json:"row"`
+	ID     int64  `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is returned by the import endpoints.
+type Report struct {
+	Mode      Mode        `json:"mode"`
+	Committed bool        `json:"committed"`
+	Total     int         `json:"total"`
+	Succeeded int         `json:"succeeded"`
+	Failed    int         `json:"failed"`
+	Rows      []RowResult `json:"rows"`
+}
+
+// HTTPStatus maps the report to the status code the import endpoints answer with.
+func (r Report) HTTPStatus() int {
+	switch {
+	case !r.Committed:
+		return http.StatusUnprocessableEntity
+	case r.Failed > 0:
+		return http.StatusMultiStatus
+	default:
+		return http.StatusCreated
+	}
+}
+
+// Import inserts all rows inside a single transaction.
+// Every row runs inside its own savepoint so a failing row never leaves partial writes behind,
+// and every row is attempted so the report covers the whole upload.
+func Import[T any](ctx context.Context, db *sql.DB, mode Mode, rows []Row[T], insert func(tx *sql.Tx, value T) (int64, error)) (Report, error) {
+	report := Report{Mode: mode, Total: len(rows), Rows: make([]RowResult, 0, len(rows))}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		result := RowResult{Row: row.Line}
+
+		id, err := insertRow(ctx, tx, row, insert)
+		if err != nil {
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			report.Failed++
+		} else {
+			result.Status = StatusCreated
+			result.ID = id
+			report.Succeeded++
+		}
+
+		report.Rows = append(report.Rows, result)
+	}
+
+	if mode == AllOrNothing && report.Failed > 0 {
+		for i := range report.Rows {
+			if report.Rows[i].Status == StatusCreated {
+				report.Rows[i].Status = StatusRolledBack
+				report.Rows[i].ID = 0
+			}
+		}
+		report.Succeeded = 0
+		return report, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+	report.Committed = true
+
+	return report, nil
+}
+
+func insertRow[T any](ctx context.Context, tx *sql.Tx, row Row[T], insert func(tx *sql.Tx, value T) (int64, error)) (int64, error) {
+	if row.Err != nil {
+		return 0, row.Err
+	}
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT bulk_row"); err != nil {
+		return 0, err
+	}
+
+	id, err := insert(tx, row.Value)
+	if err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO bulk_row"); rbErr != nil {
+			return 0, rbErr
+		}
+	}
+
+	if _, relErr := tx.ExecContext(ctx, "RELEASE bulk_row"); relErr != nil && err == nil {
+		return 0, relErr
+	}
+	return id, err
+}
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+)
+
+var (
+	lookups = metrics.NewCounterVec("cache_requests_total",
+		"Cacheable requests, by service and result (hit, miss or bypass).", "service", "result")
+	entries = metrics.NewGaugeFunc("cache_entries", "Responses held in the cache, by service.", "service")
+	size    = metrics.NewGaugeFunc("cache_bytes", "Bytes of responses held in the cache, by service.", "service")
+)
+
+// entry is one stored response. header only holds what the handler set, so headers that
+// belong to the request being answered, such as X-Request-ID, are not replayed.
+type entry struct {
+	key      string
+	status   int
+	header   http.Header
+	body     []byte
+	stored   time.Time
+	modified time.Time
+}
+
+func (e *entry) size() int64 {
+	n := int64(len(e.key) + len(e.body))
+	for name, values := range e.header {
+		for _, value := range values {
+			n += int64(len(name) + len(value))
+		}
+	}
+	return n
+}
+
+// Cache holds the responses of one service, dropped all at once whenever a request
+// changing data succeeds. A nil *Cache caches nothing, like the nil *ratelimit.Limiter.
+type Cache struct {
+	service    string
+	ttl        time.Duration
+	maxAge     time.Duration
+	maxEntries int
+	maxBytes   int64
+
+	mutex sync.Mutex
+	order *list.List // front is the most recently used
+	items map[string]*list.Element
+	bytes int64
+	// modified is the Last-Modified of every response: the last invalidation, or the start.
+	modified time.Time
+	// generation counts invalidations, so a response rendered before one is not stored after it.
+	generation uint64
+}
+
+// New returns a cache reusing responses for ttl and telling clients they may for maxAge,
+// holding at most maxEntries responses and maxBytes bytes.
+func New(service string, ttl, maxAge time.Duration, maxEntries int, maxBytes int64) *Cache {
+	c := &Cache{
+		service:    service,
+		ttl:        ttl,
+		maxAge:     maxAge,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		modified:   time.Now().Truncate(time.Second),
+	}
+	entries.Set(func() float64 {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return float64(c.order.Len())
+	}, service)
+	size.Set(func() float64 {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return float64(c.bytes)
+	}, service)
+	return c
+}
+
+var (
+	policyMutex sync.RWMutex
+	policy      = config.Default().Cache
+)
+
+// Setup sets the policy the caches returned by ForService follow.
+func Setup(cfg config.Cache) {
+	policyMutex.Lock()
+	defer policyMutex.Unlock()
+	policy = cfg
+}
+
+// ForService returns a cache following the configured policy, or nil when caching is disabled.
+func ForService(service string) *Cache {
+	policyMutex.RLock()
+	cfg := policy
+	policyMutex.RUnlock()
+
+	if !cfg.Enabled {
+		return nil
+	}
+	return New(service, time.Duration(cfg.TTL), time.Duration(cfg.MaxAge), cfg.MaxEntries, cfg.MaxBytes)
+}
+
+// Invalidate drops every response. The handlers that change data do not need to call it,
+// the adapters do when such a request succeeds.
+func (c *Cache) Invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.order.Init()
+	clear(c.items)
+	c.bytes = 0
+	c.generation++
+
+	// Last-Modified has a resolution of one second, make sure it moves forward
+	now := time.Now().Truncate(time.Second)
+	if !now.After(c.modified) {
+		now = c.modified.Add(time.Second)
+	}
+	c.modified = now
+}
+
+// get returns the fresh response stored under key.
+func (c *Cache) get(key string) (*entry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if time.Since(e.stored) > c.ttl {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return e, true
+}
+
+// state returns what a response rendered now is stamped with.
+func (c *Cache) state() (time.Time, uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.modified, c.generation
+}
+
+// put stores e unless the cache was invalidated since generation, evicting the least recently used responses to make room.
+func (c *Cache) put(e *entry, generation uint64) {
+	n := e.size()
+	if n > c.maxBytes {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if element, ok := c.items[e.key]; ok {
+		c.remove(element)
+	}
+	for c.order.Len() >= c.maxEntries || c.bytes+n > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+
+	c.items[e.key] = c.order.PushFront(e)
+	c.bytes += n
+}
+
+func (c *Cache) remove(element *list.Element) {
+	e := c.order.Remove(element).(*entry)
+	delete(c.items, e.key)
+	c.bytes -= e.size()
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newEntry(key string, body string) *entry {
+	return &entry{key: key, status: http.StatusOK, body: []byte(body), stored: time.Now()}
+}
+
+func TestGenerationInvalidation(t *testing.T) {
+	c := New("test", time.Minute, 0, 10, 1<<20)
+
+	// a response rendered before an invalidation is not stored after it
+	_, generation := c.state()
+	c.Invalidate()
+	c.put(newEntry("a", "stale"), generation)
+	if _, ok := c.get("a"); ok {
+		t.Error("response rendered before the invalidation was stored")
+	}
+
+	_, generation = c.state()
+	c.put(newEntry("a", "fresh"), generation)
+	if e, ok := c.get("a"); !ok || string(e.body) != "fresh" {
+		t.Errorf("get = %v, %v, want the fresh response", e, ok)
+	}
+
+	before, _ := c.state()
+	c.Invalidate()
+	if _, ok := c.get("a"); ok {
+		t.Error("response kept after Invalidate")
+	}
+	if after, _ := c.state(); !after.After(before) {
+		t.Errorf("Last-Modified went from %v to %v, it must move forward", before, after)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	c := New("test", time.Minute, 0, 2, 1<<20)
+	_, generation := c.state()
+	c.put(newEntry("a", "1"), generation)
+	c.put(newEntry("b", "2"), generation)
+	c.get("a")
+	c.put(newEntry("c", "3"), generation)
+
+	if _, ok := c.get("b"); ok {
+		t.Error("b kept, the least recently used response must go")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.get(key); !ok {
+			t.Errorf("%s evicted", key)
+		}
+	}
+
+	// the byte bound evicts too, and a response larger than it is never stored
+	small := newEntry("d", "0123456789")
+	c = New("test", time.Minute, 0, 10, 2*small.size())
+	c.put(newEntry("d", "0123456789"), generation)
+	c.put(newEntry("e", "0123456789"), generation)
+	c.put(newEntry("f", "0123456789"), generation)
+	if _, ok := c.get("d"); ok {
+		t.Error("d kept beyond the byte bound")
+	}
+	c.put(newEntry("g", string(make([]byte, 3*small.size()))), generation)
+	if _, ok := c.get("g"); ok {
+		t.Error("response larger than the cache was stored")
+	}
+	if _, ok := c.get("f"); !ok {
+		t.Error("f evicted for a response that was never stored")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c := New("test", time.Minute, 0, 10, 1<<20)
+	_, generation := c.state()
+	e := newEntry("a", "old")
+	e.stored = time.Now().Add(-2 * time.Minute)
+	c.put(e, generation)
+	if _, ok := c.get("a"); ok {
+		t.Error("expired response returned")
+	}
+	if c.order.Len() != 0 {
+		t.Error("expired response kept")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var renders atomic.Int32
+	c := New("test", time.Minute, 30*time.Second, 10, 1<<20)
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		n := renders.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strconv.Itoa(int(n))))
+	}))
+
+	get := func(header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/items", nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	first := get()
+	if first.Header().Get(HeaderCache) != "MISS" || first.Body.String() != "1" {
+		t.Fatalf("first GET: %s %q", first.Header().Get(HeaderCache), first.Body)
+	}
+	if got := first.Header().Get("Cache-Control"); got != "max-age=30" {
+		t.Errorf("Cache-Control = %q, want max-age=30", got)
+	}
+
+	second := get()
+	if second.Header().Get(HeaderCache) != "HIT" || second.Body.String() != "1" {
+		t.Errorf("second GET: %s %q, want a HIT of the first", second.Header().Get(HeaderCache), second.Body)
+	}
+	if got := second.Header().Get("Content-Type"); got != "text/plain" {
+		t.Errorf("replayed Content-Type = %q", got)
+	}
+
+	if w := get("If-Modified-Since", first.Header().Get("Last-Modified")); w.Code != http.StatusNotModified {
+		t.Errorf("conditional GET: status %d, want 304", w.Code)
+	}
+	if w := get("Cache-Control", "no-cache"); w.Body.String() != "2" {
+		t.Errorf("no-cache GET: %q, want a fresh render", w.Body)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/items", nil))
+	if w := get(); w.Header().Get(HeaderCache) != "MISS" {
+		t.Errorf("GET after a POST: %s, want MISS", w.Header().Get(HeaderCache))
+	}
+}
@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/internal/recorder"
+	"github.com/emicklei/go-restful"
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderCache tells whether a response came from the cache (HIT) or was rendered (MISS).
+const HeaderCache = "X-Cache"
+
+// key tells responses apart by URL and by Accept, since the read endpoints negotiate their format.
+func key(r *http.Request) string {
+	return r.URL.RequestURI() + "\n" + r.Header.Get("Accept")
+}
+
+// requestDirectives reports the no-cache and no-store directives of the request's Cache-Control.
+func requestDirectives(r *http.Request) (noCache, noStore bool) {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache":
+			noCache = true
+		case "no-store":
+			noStore = true
+		}
+	}
+	return noCache, noStore
+}
+
+// changes reports whether r may modify data, in which case a successful response invalidates the cache.
+func changes(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+func succeeded(status int) bool {
+	return status >= 200 && status < 300
+}
+
+// notModified evaluates If-Modified-Since against the Last-Modified of a stored response.
+func notModified(r *http.Request, modified time.Time) bool {
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modified.After(since)
+}
+
+// replay answers r from the cache, reporting whether it could.
+func (c *Cache) replay(w http.ResponseWriter, r *http.Request) bool {
+	if noCache, _ := requestDirectives(r); noCache {
+		return false
+	}
+	e, ok := c.get(key(r))
+	if !ok {
+		return false
+	}
+	lookups.Inc(c.service, "hit")
+
+	header := w.Header()
+	for name, values := range e.header {
+		header[name] = slices.Clone(values)
+	}
+	header.Set(HeaderCache, "HIT")
+	header.Set("Age", strconv.Itoa(int(time.Since(e.stored).Seconds())))
+
+	if notModified(r, e.modified) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+	return true
+}
+
+// begin stamps the response about to be rendered for r and returns the writer recording it.
+func (c *Cache) begin(w http.ResponseWriter, r *http.Request) *bodyRecorder {
+	noCache, noStore := requestDirectives(r)
+	result := "miss"
+	if noCache || noStore {
+		result = "bypass"
+	}
+	lookups.Inc(c.service, result)
+
+	modified, generation := c.state()
+	header := w.Header()
+	before := header.Clone()
+
+	if c.maxAge > 0 {
+		header.Set("Cache-Control", "max-age="+strconv.Itoa(int(c.maxAge.Seconds())))
+	} else {
+		header.Set("Cache-Control", "no-cache")
+	}
+	header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	header.Add("Vary", "Accept")
+	header.Set(HeaderCache, "MISS")
+
+	return &bodyRecorder{
+		Recorder:   recorder.New(w),
+		key:        key(r),
+		before:     before,
+		noStore:    noStore,
+		limit:      c.maxBytes,
+		modified:   modified,
+		generation: generation,
+	}
+}
+
+// finish stores what rec recorded, when it is a complete 200 response that may be shared.
+func (c *Cache) finish(rec *bodyRecorder) {
+	if rec.Status != http.StatusOK || rec.overflow || rec.noStore {
+		return
+	}
+
+	header := rec.Header()
+	if header.Get("Set-Cookie") != "" {
+		return
+	}
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-store", "private":
+			return
+		}
+	}
+
+	stored := http.Header{}
+	for name, values := range header {
+		if name == HeaderCache || slices.Equal(rec.before[name], values) {
+			continue
+		}
+		stored[name] = slices.Clone(values)
+	}
+
+	c.put(&entry{
+		key:      rec.key,
+		status:   rec.Status,
+		header:   stored,
+		body:     bytes.Clone(rec.body.Bytes()),
+		stored:   time.Now(),
+		modified: rec.modified,
+	}, rec.generation)
+}
+
+// Middleware caches the GET responses of next and drops them when a request changing data succeeds.
+// It fits an alice chain as c.Middleware.
+func (c *Cache) Middleware(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rec := recorder.New(w)
+			next.ServeHTTP(rec, r)
+			if changes(r) && succeeded(rec.Status) {
+				c.Invalidate()
+			}
+			return
+		}
+
+		if c.replay(w, r) {
+			return
+		}
+		rec := c.begin(w, r)
+		next.ServeHTTP(rec, r)
+		c.finish(rec)
+	})
+}
+
+// Restful does what Middleware does, as a go-restful filter. Added to a web service with
+// ws.Filter, it only caches that service and is invalidated by its own routes.
+func (c *Cache) Restful(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	if c == nil {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+	if req.Request.Method != http.MethodGet {
+		chain.ProcessFilter(req, resp)
+		if changes(req.Request) && succeeded(resp.StatusCode()) {
+			c.Invalidate()
+		}
+		return
+	}
+
+	if c.replay(resp, req.Request) {
+		return
+	}
+
+	// the handler writes through resp, swap the writer under it for the recording one
+	rec := c.begin(resp.ResponseWriter, req.Request)
+	original := resp.ResponseWriter
+	resp.ResponseWriter = rec
+	chain.ProcessFilter(req, resp)
+	resp.ResponseWriter = original
+
+	c.finish(rec)
+}
+
+// Gin does what Middleware does, as Gin middleware. Used on a route group, it only
+// caches the routes of that group and is invalidated by them.
+func (c *Cache) Gin(ctx *gin.Context) {
+	if c == nil {
+		ctx.Next()
+		return
+	}
+	if ctx.Request.Method != http.MethodGet {
+		ctx.Next()
+		if changes(ctx.Request) && succeeded(ctx.Writer.Status()) {
+			c.Invalidate()
+		}
+		return
+	}
+
+	if c.replay(ctx.Writer, ctx.Request) {
+		ctx.Abort()
+		return
+	}
+
+	original := ctx.Writer
+	rec := c.begin(original, ctx.Request)
+	ctx.Writer = &ginRecorder{ResponseWriter: original, recorder: rec}
+	ctx.Next()
+	ctx.Writer = original
+
+	// Gin writes the status lazily, ask the writer instead of relying on WriteHeader calls
+	rec.Status = original.Status()
+	c.finish(rec)
+}
+
+// bodyRecorder passes a response through while keeping a copy of it, up to limit bytes.
+type bodyRecorder struct {
+	*recorder.Recorder
+	key        string
+	before     http.Header
+	body       bytes.Buffer
+	limit      int64
+	overflow   bool
+	noStore    bool
+	modified   time.Time
+	generation uint64
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	if !r.overflow {
+		if int64(r.body.Len()+len(b)) > r.limit {
+			// too large to store anyway, stop copying
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.Recorder.Write(b)
+}
+
+// ginRecorder routes the writes of a Gin handler through a recorder.
+type ginRecorder struct {
+	gin.ResponseWriter
+	recorder *bodyRecorder
+}
+
+func (g *ginRecorder) WriteHeader(code int) {
+	g.recorder.WriteHeader(code)
+}
+
+func (g *ginRecorder) Write(b []byte) (int, error) {
+	return g.recorder.Write(b)
+}
+
+func (g *ginRecorder) WriteString(s string) (int, error) {
+	return g.recorder.Write([]byte(s))
+}
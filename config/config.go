@@ -0,0 +1,634 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is put in front of every env tag, so the rail address is read from GODICT_RAIL_ADDR.
+const EnvPrefix = "GODICT_"
+
+// Config holds the settings of every service. Each Run function receives only its own section.
+type Config struct {
+	Rail      Rail      `yaml:"rail" toml:"rail" json:"rail"`
+	Gin       Gin       `yaml:"gin" toml:"gin" json:"gin"`
+	Users     Users     `yaml:"users" toml:"users" json:"users"`
+	Cities    Cities    `yaml:"cities" toml:"cities" json:"cities"`
+	RPCServer RPCServer `yaml:"rpc_server" toml:"rpc_server" json:"rpc_server"`
+	RPCClient RPCClient `yaml:"rpc_client" toml:"rpc_client" json:"rpc_client"`
+	JSONRPC   JSONRPC   `yaml:"jsonrpc" toml:"jsonrpc" json:"jsonrpc"`
+	Mux       Mux       `yaml:"mux" toml:"mux" json:"mux"`
+	Ping      Ping      `yaml:"ping" toml:"ping" json:"ping"`
+	Books     Books     `yaml:"books" toml:"books" json:"books"`
+	Serve     Serve     `yaml:"serve" toml:"serve" json:"serve"`
+	Lifecycle Lifecycle `yaml:"lifecycle" toml:"lifecycle" json:"lifecycle"`
+	Logging   Logging   `yaml:"logging" toml:"logging" json:"logging"`
+	Tracing   Tracing   `yaml:"tracing" toml:"tracing" json:"tracing"`
+	RateLimit RateLimit `yaml:"rate_limit" toml:"rate_limit" json:"rate_limit"`
+	Cache     Cache     `yaml:"cache" toml:"cache" json:"cache"`
+	CORS      CORS      `yaml:"cors" toml:"cors" json:"cors"`
+	Security  Security  `yaml:"security" toml:"security" json:"security"`
+
+	Idempotency    Idempotency    `yaml:"idempotency" toml:"idempotency" json:"idempotency"`
+	TraceCollector TraceCollector `yaml:"trace_collector" toml:"trace_collector" json:"trace_collector"`
+	Accounts       Accounts       `yaml:"accounts" toml:"accounts" json:"accounts"`
+	LoadTest       LoadTest       `yaml:"load_test" toml:"load_test" json:"load_test"`
+}
+
+type Rail struct {
+	Addr   string `yaml:"addr" toml:"addr" json:"addr" env:"RAIL_ADDR"`
+	DBPath string `yaml:"db_path" toml:"db_path" json:"db_path" env:"RAIL_DB_PATH"`
+}
+
+type Gin struct {
+	Addr   string `yaml:"addr" toml:"addr" json:"addr" env:"GIN_ADDR"`
+	DBPath string `yaml:"db_path" toml:"db_path" json:"db_path" env:"GIN_DB_PATH"`
+}
+
+// Users keeps the users in memory unless DBPath names a SQLite database.
+type Users struct {
+	Addr   string `yaml:"addr" toml:"addr" json:"addr" env:"USERS_ADDR"`
+	DBPath string `yaml:"db_path" toml:"db_path" json:"db_path" env:"USERS_DB_PATH"`
+}
+
+type Cities struct {
+	Addr string `yaml:"addr" toml:"addr" json:"addr" env:"CITIES_ADDR"`
+}
+
+type RPCServer struct {
+	Addr string `yaml:"addr" toml:"addr" json:"addr" env:"RPC_SERVER_ADDR"`
+}
+
+type RPCClient struct {
+	// Addr is the host:port of the server to dial.
+	Addr string `yaml:"addr" toml:"addr" json:"addr" env:"RPC_CLIENT_ADDR"`
+	// Path is the HTTP path the server is mounted on.
+	Path string `yaml:"path" toml:"path" json:"path" env:"RPC_CLIENT_PATH"`
+	// Book asks the JSON-RPC book server at Addr and Path for this book instead of TimeServer for the time.
+	Book string `yaml:"book" toml:"book" json:"book" env:"RPC_CLIENT_BOOK"`
+	// Timeout bounds one call, retries included, unless the caller's context ends sooner.
+	Timeout Duration `yaml:"timeout" toml:"timeout" json:"timeout" env:"RPC_CLIENT_TIMEOUT"`
+	// Retries is how many times a call is retried on a new connection after the old one broke.
+	Retries int `yaml:"retries" toml:"retries" json:"retries" env:"RPC_CLIENT_RETRIES"`
+	// Backoff is the wait before the first retry, doubled for every further one up to MaxBackoff.
+	Backoff    Duration `yaml:"backoff" toml:"backoff" json:"backoff" env:"RPC_CLIENT_BACKOFF"`
+	MaxBackoff Duration `yaml:"max_backoff" toml:"max_backoff" json:"max_backoff" env:"RPC_CLIENT_MAX_BACKOFF"`
+	// PoolSize is how many connections calls are spread over.
+	PoolSize int `yaml:"pool_size" toml:"pool_size" json:"pool_size" env:"RPC_CLIENT_POOL_SIZE"`
+}
+
+type JSONRPC struct {
+	Addr      string `yaml:"addr" toml:"addr" json:"addr" env:"JSONRPC_ADDR"`
+	BooksFile string `yaml:"books_file" toml:"books_file" json:"books_file" env:"JSONRPC_BOOKS_FILE"`
+}
+
+type Mux struct {
+	Addr string `yaml:"addr" toml:"addr" json:"addr" env:"MUX_ADDR"`
+	// Router is either gorilla or httprouter.
+	Router string `yaml:"router" toml:"router" json:"router" env:"MUX_ROUTER"`
+	// StaticDir is served under /static/ by httprouter; empty disables it.
+	StaticDir string `yaml:"static_dir" toml:"static_dir" json:"static_dir" env:"MUX_STATIC_DIR"`
+	// ShowFile is the file returned by /api/v1/show-file.
+	ShowFile string `yaml:"show_file" toml:"show_file" json:"show_file" env:"MUX_SHOW_FILE"`
+}
+
+type Ping struct {
+	Addr string `yaml:"addr" toml:"addr" json:"addr" env:"PING_ADDR"`
+}
+
+type Books struct {
+	DBPath string `yaml:"db_path" toml:"db_path" json:"db_path" env:"BOOKS_DB_PATH"`
+}
+
+type Serve struct {
+	Addr string `yaml:"addr" toml:"addr" json:"addr" env:"SERVE_ADDR"`
+	// Mounts are service[=prefix] entries, e.g. "rail" or "gin=/stations".
+	Mounts []string `yaml:"mounts" toml:"mounts" json:"mounts" env:"SERVE_MOUNTS"`
+}
+
+// Lifecycle controls how servers shut down on SIGINT/SIGTERM.
+type Lifecycle struct {
+	// DrainTimeout bounds how long in-flight requests get to finish before connections are cut.
+	DrainTimeout Duration `yaml:"drain_timeout" toml:"drain_timeout" json:"drain_timeout" env:"DRAIN_TIMEOUT"`
+	// ShutdownDelay keeps serving while reporting not-ready, so load balancers stop routing first.
+	ShutdownDelay Duration `yaml:"shutdown_delay" toml:"shutdown_delay" json:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+}
+
+// Logging selects how much the servers log and in which format.
+type Logging struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" toml:"level" json:"level" env:"LOG_LEVEL"`
+	// Format is json or text.
+	Format string `yaml:"format" toml:"format" json:"format" env:"LOG_FORMAT"`
+}
+
+// Tracing selects where finished spans go.
+type Tracing struct {
+	// Exporter is none, file or otlp.
+	Exporter string `yaml:"exporter" toml:"exporter" json:"exporter" env:"TRACE_EXPORTER"`
+	// File receives spans as JSON lines with the file exporter.
+	File string `yaml:"file" toml:"file" json:"file" env:"TRACE_FILE"`
+	// Endpoint is the base URL of the OTLP/HTTP collector, /v1/traces is appended.
+	Endpoint string `yaml:"endpoint" toml:"endpoint" json:"endpoint" env:"TRACE_ENDPOINT"`
+}
+
+// RateLimit throttles the HTTP APIs with a token bucket per client.
+type RateLimit struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" json:"enabled" env:"RATE_LIMIT_ENABLED"`
+	// Rate is how many requests per second a client may sustain.
+	Rate float64 `yaml:"rate" toml:"rate" json:"rate" env:"RATE_LIMIT_RATE"`
+	// Burst is how many requests a client may make at once after being idle.
+	Burst int `yaml:"burst" toml:"burst" json:"burst" env:"RATE_LIMIT_BURST"`
+	// Key is ip, api-key or route; route shares one bucket between all clients of an endpoint.
+	Key string `yaml:"key" toml:"key" json:"key" env:"RATE_LIMIT_KEY"`
+	// APIKeys are the keys given their own bucket when Key is api-key; other clients are limited by IP.
+	APIKeys []string `yaml:"api_keys" toml:"api_keys" json:"api_keys" env:"RATE_LIMIT_API_KEYS"`
+	// MaxKeys bounds the number of buckets kept, the least recently used are forgotten first.
+	MaxKeys int `yaml:"max_keys" toml:"max_keys" json:"max_keys" env:"RATE_LIMIT_MAX_KEYS"`
+	// TrustProxy takes the client IP from X-Forwarded-For, only safe behind a proxy that sets it.
+	TrustProxy bool `yaml:"trust_proxy" toml:"trust_proxy" json:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY"`
+}
+
+// Cache keeps the responses of the read endpoints of rail and Gin in memory.
+type Cache struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" json:"enabled" env:"CACHE_ENABLED"`
+	// TTL bounds how long a response is reused, in case the data changes behind the server's back.
+	TTL Duration `yaml:"ttl" toml:"ttl" json:"ttl" env:"CACHE_TTL"`
+	// MaxAge is the max-age sent to clients; zero makes them revalidate every time.
+	MaxAge Duration `yaml:"max_age" toml:"max_age" json:"max_age" env:"CACHE_MAX_AGE"`
+	// MaxEntries and MaxBytes bound the cache of each service, the least recently used responses go first.
+	MaxEntries int   `yaml:"max_entries" toml:"max_entries" json:"max_entries" env:"CACHE_MAX_ENTRIES"`
+	MaxBytes   int64 `yaml:"max_bytes" toml:"max_bytes" json:"max_bytes" env:"CACHE_MAX_BYTES"`
+}
+
+// CORS lists what browsers may do from pages served by other origins.
+type CORS struct {
+	// AllowedOrigins are scheme://host[:port] values, or "*" for any origin; empty disables CORS.
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins" json:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods []string `yaml:"allowed_methods" toml:"allowed_methods" json:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders []string `yaml:"allowed_headers" toml:"allowed_headers" json:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	// ExposedHeaders are the response headers scripts may read besides the basic ones.
+	ExposedHeaders   []string `yaml:"exposed_headers" toml:"exposed_headers" json:"exposed_headers" env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials" json:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge Duration `yaml:"max_age" toml:"max_age" json:"max_age" env:"CORS_MAX_AGE"`
+}
+
+// Security holds the hardening applied to every response.
+type Security struct {
+	// HSTS is the max-age of Strict-Transport-Security; zero leaves the header out, as the servers speak plain HTTP.
+	HSTS Duration `yaml:"hsts" toml:"hsts" json:"hsts" env:"SECURITY_HSTS"`
+	// ContentSecurityPolicy and FrameOptions default to refusing everything, which suits JSON APIs.
+	ContentSecurityPolicy string `yaml:"content_security_policy" toml:"content_security_policy" json:"content_security_policy" env:"SECURITY_CSP"`
+	FrameOptions          string `yaml:"frame_options" toml:"frame_options" json:"frame_options" env:"SECURITY_FRAME_OPTIONS"`
+	// MaxBodyBytes bounds request bodies, larger ones get 413.
+	MaxBodyBytes int64 `yaml:"max_body_bytes" toml:"max_body_bytes" json:"max_body_bytes" env:"SECURITY_MAX_BODY_BYTES"`
+	// Compression gzip or brotli encodes responses for clients accepting it.
+	Compression bool `yaml:"compression" toml:"compression" json:"compression" env:"SECURITY_COMPRESSION"`
+}
+
+// Idempotency makes retried POST requests carrying an Idempotency-Key replay the first response.
+type Idempotency struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" json:"enabled" env:"IDEMPOTENCY_ENABLED"`
+	// Window is how long a key is remembered.
+	Window Duration `yaml:"window" toml:"window" json:"window" env:"IDEMPOTENCY_WINDOW"`
+	// MaxBody bounds the request bodies read to compare retries with the first request.
+	MaxBody int64 `yaml:"max_body" toml:"max_body" json:"max_body" env:"IDEMPOTENCY_MAX_BODY"`
+}
+
+// TraceCollector is the local stand-in for an OTLP collector.
+type TraceCollector struct {
+	Addr string `yaml:"addr" toml:"addr" json:"addr" env:"TRACE_COLLECTOR_ADDR"`
+	// Output receives the spans as JSON lines; empty only logs them.
+	Output string `yaml:"output" toml:"output" json:"output" env:"TRACE_COLLECTOR_OUTPUT"`
+}
+
+// Accounts are the password logins of the users API.
+type Accounts struct {
+	// OutboxDir receives the verification and password reset emails as .eml files instead of sending them.
+	OutboxDir string `yaml:"outbox_dir" toml:"outbox_dir" json:"outbox_dir" env:"ACCOUNTS_OUTBOX_DIR"`
+	// PublicURL is the base URL the links in those emails point to.
+	PublicURL string `yaml:"public_url" toml:"public_url" json:"public_url" env:"ACCOUNTS_PUBLIC_URL"`
+	// SessionTTL is how long a login stays valid.
+	SessionTTL Duration `yaml:"session_ttl" toml:"session_ttl" json:"session_ttl" env:"ACCOUNTS_SESSION_TTL"`
+	// TokenTTL is how long an email verification or password reset link stays valid.
+	TokenTTL Duration `yaml:"token_ttl" toml:"token_ttl" json:"token_ttl" env:"ACCOUNTS_TOKEN_TTL"`
+	// MaxFailedLogins wrong passwords in a row lock an account for LockoutDuration.
+	MaxFailedLogins int      `yaml:"max_failed_logins" toml:"max_failed_logins" json:"max_failed_logins" env:"ACCOUNTS_MAX_FAILED_LOGINS"`
+	LockoutDuration Duration `yaml:"lockout_duration" toml:"lockout_duration" json:"lockout_duration" env:"ACCOUNTS_LOCKOUT_DURATION"`
+	// BcryptCost is the work factor passwords are hashed with.
+	BcryptCost int `yaml:"bcrypt_cost" toml:"bcrypt_cost" json:"bcrypt_cost" env:"ACCOUNTS_BCRYPT_COST"`
+	// SecureCookie sets Secure on the session cookie, for deployments behind HTTPS.
+	SecureCookie bool `yaml:"secure_cookie" toml:"secure_cookie" json:"secure_cookie" env:"ACCOUNTS_SECURE_COOKIE"`
+}
+
+// LoadTest drives a request mix against a running server and reports its latency and errors.
+type LoadTest struct {
+	// Target is the base URL of the HTTP server, or host:port[/path] of the RPC server for the rpc scenario.
+	Target string `yaml:"target" toml:"target" json:"target" env:"LOADTEST_TARGET"`
+	// Scenario is stations, trains, users, cities, health or rpc.
+	Scenario string `yaml:"scenario" toml:"scenario" json:"scenario" env:"LOADTEST_SCENARIO"`
+	// Reads is the fraction of requests that read, the others create or delete.
+	Reads       float64  `yaml:"reads" toml:"reads" json:"reads" env:"LOADTEST_READS"`
+	Concurrency int      `yaml:"concurrency" toml:"concurrency" json:"concurrency" env:"LOADTEST_CONCURRENCY"`
+	Duration    Duration `yaml:"duration" toml:"duration" json:"duration" env:"LOADTEST_DURATION"`
+	// Timeout bounds one request, slower ones count as errors.
+	Timeout Duration `yaml:"timeout" toml:"timeout" json:"timeout" env:"LOADTEST_TIMEOUT"`
+	// Format of the report is text or json.
+	Format string `yaml:"format" toml:"format" json:"format" env:"LOADTEST_FORMAT"`
+	// Compare serves the same endpoint with every router in-process and compares them, instead of Target.
+	Compare bool `yaml:"compare" toml:"compare" json:"compare" env:"LOADTEST_COMPARE"`
+}
+
+// Default returns the values the examples used before they were configurable.
+func Default() *Config {
+	return &Config{
+		Rail:      Rail{Addr: ":8000", DBPath: "./railapi.db"},
+		Gin:       Gin{Addr: ":8000", DBPath: "./railapi.db"},
+		Users:     Users{Addr: ":8080"},
+		Cities:    Cities{Addr: ":8080"},
+		RPCServer: RPCServer{Addr: ":1234"},
+		RPCClient: RPCClient{
+			Addr:       "localhost:1234",
+			Path:       "/_goRPC_",
+			Timeout:    Duration(5 * time.Second),
+			Retries:    3,
+			Backoff:    Duration(100 * time.Millisecond),
+			MaxBackoff: Duration(2 * time.Second),
+			PoolSize:   2,
+		},
+		JSONRPC:   JSONRPC{Addr: ":1234", BooksFile: "./book.json"},
+		Mux:       Mux{Addr: ":8000", Router: "gorilla", ShowFile: "./latin.txt"},
+		Ping:      Ping{Addr: ":8000"},
+		Books:     Books{DBPath: "./books.db"},
+		Serve:     Serve{Addr: ":8000"},
+		Lifecycle: Lifecycle{DrainTimeout: Duration(15 * time.Second)},
+		Logging:   Logging{Level: "info", Format: "json"},
+		Tracing:   Tracing{Exporter: "none", File: "./traces.jsonl", Endpoint: "http://localhost:4318"},
+		RateLimit: RateLimit{Enabled: true, Rate: 10, Burst: 20, Key: "ip", MaxKeys: 10000},
+		Cache:     Cache{Enabled: true, TTL: Duration(5 * time.Minute), MaxEntries: 1000, MaxBytes: 16 << 20},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-Request-ID", "X-API-Key", "Idempotency-Key"},
+			ExposedHeaders: []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
+			MaxAge:         Duration(10 * time.Minute),
+		},
+		Security: Security{
+			ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+			FrameOptions:          "DENY",
+			MaxBodyBytes:          8 << 20,
+			Compression:           true,
+		},
+
+		Idempotency:    Idempotency{Enabled: true, Window: Duration(24 * time.Hour), MaxBody: 1 << 20},
+		TraceCollector: TraceCollector{Addr: ":4318"},
+		Accounts: Accounts{
+			OutboxDir:       "./outbox",
+			PublicURL:       "http://localhost:8080",
+			SessionTTL:      Duration(24 * time.Hour),
+			TokenTTL:        Duration(time.Hour),
+			MaxFailedLogins: 5,
+			LockoutDuration: Duration(15 * time.Minute),
+			BcryptCost:      12,
+		},
+		LoadTest: LoadTest{
+			Target:      "http://localhost:8000",
+			Scenario:    "stations",
+			Reads:       0.9,
+			Concurrency: 10,
+			Duration:    Duration(10 * time.Second),
+			Timeout:     Duration(5 * time.Second),
+			Format:      "text",
+		},
+	}
+}
+
+func (r *Rail) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&r.Addr, "addr", r.Addr, "address to listen on")
+	fs.StringVar(&r.DBPath, "db", r.DBPath, "path to the SQLite database")
+}
+
+func (g *Gin) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&g.Addr, "addr", g.Addr, "address to listen on")
+	fs.StringVar(&g.DBPath, "db", g.DBPath, "path to the SQLite database")
+}
+
+func (u *Users) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&u.Addr, "addr", u.Addr, "address to listen on")
+	fs.StringVar(&u.DBPath, "db", u.DBPath, "path to a SQLite database to keep the users in, in memory when empty")
+}
+
+func (c *Cities) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "addr", c.Addr, "address to listen on")
+}
+
+func (r *RPCServer) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&r.Addr, "addr", r.Addr, "address to listen on")
+}
+
+func (r *RPCClient) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&r.Addr, "addr", r.Addr, "address of the RPC server")
+	fs.StringVar(&r.Path, "path", r.Path, "HTTP path the RPC server is mounted on")
+	fs.StringVar(&r.Book, "book", r.Book, "ID of a book to look up on the JSON-RPC book server instead of asking for the time")
+	fs.Var(&r.Timeout, "timeout", "how long a call may take, retries included")
+	fs.IntVar(&r.Retries, "retries", r.Retries, "how many times a call is retried after the connection broke")
+	fs.Var(&r.Backoff, "backoff", "wait before the first retry, doubled for every further one")
+}
+
+func (j *JSONRPC) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&j.Addr, "addr", j.Addr, "address to listen on")
+	fs.StringVar(&j.BooksFile, "books", j.BooksFile, "JSON file holding the books")
+}
+
+func (m *Mux) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&m.Addr, "addr", m.Addr, "address to listen on")
+	fs.StringVar(&m.Router, "router", m.Router, "router to run: gorilla or httprouter")
+	fs.StringVar(&m.StaticDir, "static", m.StaticDir, "directory served under /static/ by httprouter")
+	fs.StringVar(&m.ShowFile, "show-file", m.ShowFile, "file returned by /api/v1/show-file")
+}
+
+func (p *Ping) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&p.Addr, "addr", p.Addr, "address to listen on")
+}
+
+func (b *Books) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&b.DBPath, "db", b.DBPath, "path to the SQLite database")
+}
+
+func (l *Lifecycle) BindFlags(fs *flag.FlagSet) {
+	fs.Var(&l.DrainTimeout, "drain-timeout", "how long in-flight requests may take to finish on shutdown")
+	fs.Var(&l.ShutdownDelay, "shutdown-delay", "how long to keep serving as not-ready before draining")
+}
+
+func (l *Logging) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&l.Level, "log-level", l.Level, "minimum log level: debug, info, warn or error")
+	fs.StringVar(&l.Format, "log-format", l.Format, "log format: json or text")
+}
+
+func (t *Tracing) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&t.Exporter, "trace-exporter", t.Exporter, "where spans go: none, file or otlp")
+	fs.StringVar(&t.File, "trace-file", t.File, "JSON lines file written by the file exporter")
+	fs.StringVar(&t.Endpoint, "trace-endpoint", t.Endpoint, "base URL of the OTLP/HTTP collector")
+}
+
+func (r *RateLimit) BindFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&r.Enabled, "rate-limit", r.Enabled, "throttle clients of the HTTP APIs")
+	fs.Float64Var(&r.Rate, "rate-limit-rate", r.Rate, "requests per second a client may sustain")
+	fs.IntVar(&r.Burst, "rate-limit-burst", r.Burst, "requests a client may make at once")
+	fs.StringVar(&r.Key, "rate-limit-key", r.Key, "what a bucket belongs to: ip, api-key or route")
+	fs.Var(stringList{&r.APIKeys}, "rate-limit-api-keys", "comma-separated API keys given their own bucket with -rate-limit-key api-key")
+	fs.IntVar(&r.MaxKeys, "rate-limit-max-keys", r.MaxKeys, "number of buckets kept in memory")
+	fs.BoolVar(&r.TrustProxy, "rate-limit-trust-proxy", r.TrustProxy, "take the client IP from X-Forwarded-For")
+}
+
+func (c *Cache) BindFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.Enabled, "cache", c.Enabled, "cache the responses of read endpoints")
+	fs.Var(&c.TTL, "cache-ttl", "how long a cached response is reused")
+	fs.Var(&c.MaxAge, "cache-max-age", "max-age sent to clients, 0 makes them revalidate")
+	fs.IntVar(&c.MaxEntries, "cache-max-entries", c.MaxEntries, "responses kept per service")
+	fs.Int64Var(&c.MaxBytes, "cache-max-bytes", c.MaxBytes, "bytes of responses kept per service")
+}
+
+func (c *CORS) BindFlags(fs *flag.FlagSet) {
+	fs.Var(stringList{&c.AllowedOrigins}, "cors-origins", "comma-separated origins allowed to call the APIs from a browser, \"*\" for any")
+	fs.BoolVar(&c.AllowCredentials, "cors-credentials", c.AllowCredentials, "let browsers send cookies and credentials cross-origin")
+}
+
+func (s *Security) BindFlags(fs *flag.FlagSet) {
+	fs.Var(&s.HSTS, "hsts", "max-age of Strict-Transport-Security, 0 leaves it out")
+	fs.Int64Var(&s.MaxBodyBytes, "max-body", s.MaxBodyBytes, "largest request body accepted, in bytes")
+	fs.BoolVar(&s.Compression, "compress", s.Compression, "gzip or brotli encode responses")
+}
+
+func (i *Idempotency) BindFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&i.Enabled, "idempotency", i.Enabled, "replay the first response to POST requests retried with the same Idempotency-Key")
+	fs.Var(&i.Window, "idempotency-window", "how long an Idempotency-Key is remembered")
+	fs.Int64Var(&i.MaxBody, "idempotency-max-body", i.MaxBody, "largest request body accepted with an Idempotency-Key")
+}
+
+func (t *TraceCollector) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&t.Addr, "addr", t.Addr, "address to receive OTLP/HTTP JSON on")
+	fs.StringVar(&t.Output, "output", t.Output, "JSON lines file the received spans are appended to")
+}
+
+func (a *Accounts) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&a.OutboxDir, "outbox", a.OutboxDir, "directory the account emails are written to")
+	fs.StringVar(&a.PublicURL, "public-url", a.PublicURL, "base URL of the links in account emails")
+	fs.Var(&a.SessionTTL, "session-ttl", "how long a login stays valid")
+	fs.IntVar(&a.MaxFailedLogins, "max-failed-logins", a.MaxFailedLogins, "wrong passwords in a row that lock an account")
+	fs.Var(&a.LockoutDuration, "lockout", "how long a locked account stays locked")
+}
+
+func (l *LoadTest) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&l.Target, "target", l.Target, "base URL of the server, host:port[/path] for the rpc scenario")
+	fs.StringVar(&l.Scenario, "scenario", l.Scenario, "requests to send: stations, trains, users, cities, health or rpc")
+	fs.Float64Var(&l.Reads, "reads", l.Reads, "fraction of requests that read, the others create or delete")
+	fs.IntVar(&l.Concurrency, "concurrency", l.Concurrency, "requests in flight at once")
+	fs.Var(&l.Duration, "duration", "how long to send requests for")
+	fs.Var(&l.Timeout, "timeout", "how long one request may take before it counts as an error")
+	fs.StringVar(&l.Format, "format", l.Format, "report format: text or json")
+	fs.BoolVar(&l.Compare, "compare", l.Compare, "compare gorilla, httprouter, Gin, go-restful and the standard library serving the same endpoint in-process")
+}
+
+// BindServeFlags binds the serve flags. The services it mounts also read their own sections,
+// so their database and file paths get prefixed flags here.
+func (c *Config) BindServeFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Serve.Addr, "addr", c.Serve.Addr, "address to listen on")
+	fs.StringVar(&c.Rail.DBPath, "rail-db", c.Rail.DBPath, "path to the SQLite database used by rail")
+	fs.StringVar(&c.Gin.DBPath, "gin-db", c.Gin.DBPath, "path to the SQLite database used by gin")
+	fs.StringVar(&c.Users.DBPath, "users-db", c.Users.DBPath, "path to the SQLite database used by users, in memory when empty")
+	fs.StringVar(&c.Accounts.OutboxDir, "outbox", c.Accounts.OutboxDir, "directory the users account emails are written to")
+	fs.StringVar(&c.Mux.StaticDir, "static", c.Mux.StaticDir, "directory served under /static/ by httprouter")
+	fs.StringVar(&c.Mux.ShowFile, "show-file", c.Mux.ShowFile, "file returned by /api/v1/show-file")
+	fs.StringVar(&c.JSONRPC.BooksFile, "books", c.JSONRPC.BooksFile, "JSON file holding the books")
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	checkAddr := func(field, addr string) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid address %q", field, addr))
+		}
+	}
+	checkPath := func(field, path string) {
+		if strings.TrimSpace(path) == "" {
+			errs = append(errs, fmt.Errorf("%s: must not be empty", field))
+		}
+	}
+
+	checkAddr("rail.addr", c.Rail.Addr)
+	checkPath("rail.db_path", c.Rail.DBPath)
+	checkAddr("gin.addr", c.Gin.Addr)
+	checkPath("gin.db_path", c.Gin.DBPath)
+	checkAddr("users.addr", c.Users.Addr)
+	checkAddr("cities.addr", c.Cities.Addr)
+	checkAddr("rpc_server.addr", c.RPCServer.Addr)
+	checkAddr("rpc_client.addr", c.RPCClient.Addr)
+	if !strings.HasPrefix(c.RPCClient.Path, "/") {
+		errs = append(errs, fmt.Errorf("rpc_client.path: %q must start with /", c.RPCClient.Path))
+	}
+	if c.RPCClient.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("rpc_client.timeout: must be positive"))
+	}
+	if c.RPCClient.Retries < 0 {
+		errs = append(errs, fmt.Errorf("rpc_client.retries: must not be negative"))
+	}
+	if c.RPCClient.Backoff <= 0 {
+		errs = append(errs, fmt.Errorf("rpc_client.backoff: must be positive"))
+	}
+	if c.RPCClient.MaxBackoff < c.RPCClient.Backoff {
+		errs = append(errs, fmt.Errorf("rpc_client.max_backoff: must not be less than backoff"))
+	}
+	if c.RPCClient.PoolSize < 1 {
+		errs = append(errs, fmt.Errorf("rpc_client.pool_size: must be at least 1"))
+	}
+	checkAddr("jsonrpc.addr", c.JSONRPC.Addr)
+	checkPath("jsonrpc.books_file", c.JSONRPC.BooksFile)
+	checkAddr("mux.addr", c.Mux.Addr)
+	if c.Mux.Router != "gorilla" && c.Mux.Router != "httprouter" {
+		errs = append(errs, fmt.Errorf("mux.router: %q is neither gorilla nor httprouter", c.Mux.Router))
+	}
+	checkPath("mux.show_file", c.Mux.ShowFile)
+	checkAddr("ping.addr", c.Ping.Addr)
+	checkPath("books.db_path", c.Books.DBPath)
+	checkAddr("serve.addr", c.Serve.Addr)
+	if c.Lifecycle.DrainTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("lifecycle.drain_timeout: must be positive"))
+	}
+	if c.Lifecycle.ShutdownDelay < 0 {
+		errs = append(errs, fmt.Errorf("lifecycle.shutdown_delay: must not be negative"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Logging.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level: %q is not one of debug, info, warn or error", c.Logging.Level))
+	}
+	if c.Logging.Format != "json" && c.Logging.Format != "text" {
+		errs = append(errs, fmt.Errorf("logging.format: %q is neither json nor text", c.Logging.Format))
+	}
+	switch c.Tracing.Exporter {
+	case "none":
+	case "file":
+		checkPath("tracing.file", c.Tracing.File)
+	case "otlp":
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint: %q is not an http(s) URL", c.Tracing.Endpoint))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: %q is not one of none, file or otlp", c.Tracing.Exporter))
+	}
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Rate <= 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.rate: must be positive"))
+		}
+		if c.RateLimit.Burst < 1 {
+			errs = append(errs, fmt.Errorf("rate_limit.burst: must be at least 1"))
+		}
+		if c.RateLimit.Key != "ip" && c.RateLimit.Key != "api-key" && c.RateLimit.Key != "route" {
+			errs = append(errs, fmt.Errorf("rate_limit.key: %q is not one of ip, api-key or route", c.RateLimit.Key))
+		}
+		if c.RateLimit.Key == "api-key" && len(c.RateLimit.APIKeys) == 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.api_keys: required when rate_limit.key is api-key"))
+		}
+		if c.RateLimit.MaxKeys < 1 {
+			errs = append(errs, fmt.Errorf("rate_limit.max_keys: must be at least 1"))
+		}
+	}
+	if c.Cache.Enabled {
+		if c.Cache.TTL <= 0 {
+			errs = append(errs, fmt.Errorf("cache.ttl: must be positive"))
+		}
+		if c.Cache.MaxAge < 0 {
+			errs = append(errs, fmt.Errorf("cache.max_age: must not be negative"))
+		}
+		if c.Cache.MaxEntries < 1 {
+			errs = append(errs, fmt.Errorf("cache.max_entries: must be at least 1"))
+		}
+		if c.Cache.MaxBytes < 1 {
+			errs = append(errs, fmt.Errorf("cache.max_bytes: must be at least 1"))
+		}
+	}
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				errs = append(errs, fmt.Errorf("cors.allowed_origins: \"*\" cannot be combined with allow_credentials"))
+			}
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins: %q is not scheme://host[:port]", origin))
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("cors.max_age: must not be negative"))
+	}
+	if c.Security.HSTS < 0 {
+		errs = append(errs, fmt.Errorf("security.hsts: must not be negative"))
+	}
+	if c.Security.MaxBodyBytes < 1 {
+		errs = append(errs, fmt.Errorf("security.max_body_bytes: must be at least 1"))
+	}
+	if c.Idempotency.Enabled {
+		if c.Idempotency.Window <= 0 {
+			errs = append(errs, fmt.Errorf("idempotency.window: must be positive"))
+		}
+		if c.Idempotency.MaxBody < 1 {
+			errs = append(errs, fmt.Errorf("idempotency.max_body: must be at least 1"))
+		}
+	}
+	checkAddr("trace_collector.addr", c.TraceCollector.Addr)
+	checkPath("accounts.outbox_dir", c.Accounts.OutboxDir)
+	if u, err := url.Parse(c.Accounts.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("accounts.public_url: %q is not an absolute URL", c.Accounts.PublicURL))
+	}
+	if c.Accounts.SessionTTL <= 0 {
+		errs = append(errs, fmt.Errorf("accounts.session_ttl: must be positive"))
+	}
+	if c.Accounts.TokenTTL <= 0 {
+		errs = append(errs, fmt.Errorf("accounts.token_ttl: must be positive"))
+	}
+	if c.Accounts.MaxFailedLogins < 1 {
+		errs = append(errs, fmt.Errorf("accounts.max_failed_logins: must be at least 1"))
+	}
+	if c.Accounts.LockoutDuration <= 0 {
+		errs = append(errs, fmt.Errorf("accounts.lockout_duration: must be positive"))
+	}
+	if c.Accounts.BcryptCost < 4 || c.Accounts.BcryptCost > 31 {
+		errs = append(errs, fmt.Errorf("accounts.bcrypt_cost: must be between 4 and 31"))
+	}
+	switch c.LoadTest.Scenario {
+	case "stations", "trains", "users", "cities", "health":
+		if u, err := url.Parse(c.LoadTest.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("load_test.target: %q is not an http(s) URL", c.LoadTest.Target))
+		}
+	case "rpc":
+		addr, _, _ := strings.Cut(c.LoadTest.Target, "/")
+		checkAddr("load_test.target", addr)
+	default:
+		errs = append(errs, fmt.Errorf("load_test.scenario: %q is not one of stations, trains, users, cities, health or rpc", c.LoadTest.Scenario))
+	}
+	if c.LoadTest.Reads < 0 || c.LoadTest.Reads > 1 {
+		errs = append(errs, fmt.Errorf("load_test.reads: must be between 0 and 1"))
+	}
+	if c.LoadTest.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("load_test.concurrency: must be at least 1"))
+	}
+	if c.LoadTest.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("load_test.duration: must be positive"))
+	}
+	if c.LoadTest.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("load_test.timeout: must be positive"))
+	}
+	if c.LoadTest.Format != "text" && c.LoadTest.Format != "json" {
+		errs = append(errs, fmt.Errorf("load_test.format: %q is neither text nor json", c.LoadTest.Format))
+	}
+
+	return errors.Join(errs...)
+}
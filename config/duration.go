@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// Duration is a time.Duration written as "15s" or "1m30s" in files, environment variables and flags.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Set lets a Duration be bound with flag.Var.
+func (d *Duration) Set(s string) error {
+	return d.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Load builds the configuration of one command. Later layers win:
+//
+//	defaults < config file < GODICT_* environment variables < command-line flags
+//
+// The file comes from -config or GODICT_CONFIG and may be YAML, TOML or JSON.
+// bind registers the command's flags on the section they belong to.
+// The returned slice holds the positional arguments left after the flags.
+func Load(name string, args []string, bind func(fs *flag.FlagSet, cfg *Config)) (*Config, []string, error) {
+	// first pass: only find out which flags were given, the values they were bound to are thrown away
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "YAML, TOML or JSON config file")
+	bindAll(fs, Default(), bind)
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+
+	if *configPath != "" {
+		if err := loadFile(*configPath, cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := loadEnv(cfg); err != nil {
+		return nil, nil, err
+	}
+
+	// second pass: replay the flags that were set explicitly on top of file and environment
+	overrides := flag.NewFlagSet(name, flag.ContinueOnError)
+	bindAll(overrides, cfg, bind)
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "config" || flagErr != nil {
+			return
+		}
+		flagErr = overrides.Set(f.Name, f.Value.String())
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// bindAll registers the flags every command shares next to the command's own.
+func bindAll(fs *flag.FlagSet, cfg *Config, bind func(fs *flag.FlagSet, cfg *Config)) {
+	bind(fs, cfg)
+	cfg.Lifecycle.BindFlags(fs)
+	cfg.Logging.BindFlags(fs)
+	cfg.Tracing.BindFlags(fs)
+	cfg.RateLimit.BindFlags(fs)
+	cfg.Cache.BindFlags(fs)
+	cfg.Idempotency.BindFlags(fs)
+	cfg.CORS.BindFlags(fs)
+	cfg.Security.BindFlags(fs)
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalWithOptions(data, cfg, yaml.Strict(), yaml.DisallowUnknownField())
+	case ".toml":
+		err = toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(cfg)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfg)
+	default:
+		return fmt.Errorf("config file %s: unknown extension, expected .yaml, .yml, .toml or .json", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv walks the config and overrides every field carrying an env tag whose variable is set.
+func loadEnv(cfg *Config) error {
+	return loadEnvStruct(reflect.ValueOf(cfg).Elem())
+}
+
+func loadEnvStruct(v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			if err := loadEnvStruct(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tag := t.Field(i).Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+
+		name := EnvPrefix + tag
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, raw string) error {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		field.Set(reflect.ValueOf(splitList(raw)))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// stringList binds a []string to a flag taking a comma-separated list, which replaces the list.
+type stringList struct {
+	list *[]string
+}
+
+func (s stringList) String() string {
+	if s.list == nil {
+		return ""
+	}
+	return strings.Join(*s.list, ",")
+}
+
+func (s stringList) Set(raw string) error {
+	*s.list = splitList(raw)
+	return nil
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bindRPCClient(fs *flag.FlagSet, cfg *Config) { cfg.RPCClient.BindFlags(fs) }
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", "rpc_client:\n  addr: file:1234\n  timeout: 7s\n")
+
+	tests := []struct {
+		name    string
+		file    bool
+		env     string
+		flag    string
+		want    string
+		timeout time.Duration
+	}{
+		{"defaults", false, "", "", "localhost:1234", 5 * time.Second},
+		{"file over defaults", true, "", "", "file:1234", 7 * time.Second},
+		{"env over file", true, "env:1234", "", "env:1234", 7 * time.Second},
+		{"flag over env", true, "env:1234", "flag:1234", "flag:1234", 7 * time.Second},
+		{"flag over defaults", false, "", "flag:1234", "flag:1234", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvPrefix+"CONFIG", "")
+			if tt.env != "" {
+				t.Setenv(EnvPrefix+"RPC_CLIENT_ADDR", tt.env)
+			}
+			var args []string
+			if tt.file {
+				args = append(args, "-config", file)
+			}
+			if tt.flag != "" {
+				args = append(args, "-addr", tt.flag)
+			}
+
+			cfg, _, err := Load("test", args, bindRPCClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.RPCClient.Addr != tt.want {
+				t.Errorf("addr = %q, want %q", cfg.RPCClient.Addr, tt.want)
+			}
+			if got := time.Duration(cfg.RPCClient.Timeout); got != tt.timeout {
+				t.Errorf("timeout = %v, want %v", got, tt.timeout)
+			}
+		})
+	}
+}
+
+// A flag given on the command line wins even when it repeats the default.
+func TestLoadFlagEqualToDefault(t *testing.T) {
+	t.Setenv(EnvPrefix+"RPC_CLIENT_TIMEOUT", "9s")
+	cfg, _, err := Load("test", []string{"-timeout", "5s"}, bindRPCClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Duration(cfg.RPCClient.Timeout); got != 5*time.Second {
+		t.Errorf("timeout = %v, want 5s", got)
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvPrefix+"CONFIG", writeFile(t, "config.json", `{"rpc_client": {"addr": "json:1234"}}`))
+	cfg, args, err := Load("test", []string{"extra"}, bindRPCClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RPCClient.Addr != "json:1234" {
+		t.Errorf("addr = %q, want json:1234", cfg.RPCClient.Addr)
+	}
+	if len(args) != 1 || args[0] != "extra" {
+		t.Errorf("args = %q, want [extra]", args)
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": "rpc_client:\n  addr: x:1\n",
+		"config.yml":  "rpc_client:\n  addr: x:1\n",
+		"config.toml": "[rpc_client]\naddr = \"x:1\"\n",
+		"config.json": `{"rpc_client": {"addr": "x:1"}}`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(EnvPrefix+"CONFIG", "")
+			cfg, _, err := Load("test", []string{"-config", writeFile(t, name, content)}, bindRPCClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.RPCClient.Addr != "x:1" {
+				t.Errorf("addr = %q, want x:1", cfg.RPCClient.Addr)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		body string
+		env  [2]string
+		args []string
+		want string
+	}{
+		{"unknown yaml field", "config.yaml", "rpc_client:\n  adr: x:1\n", [2]string{}, nil, "parsing config file"},
+		{"unknown toml field", "config.toml", "[rpc_client]\nadr = \"x:1\"\n", [2]string{}, nil, "parsing config file"},
+		{"unknown json field", "config.json", `{"rpc_client": {"adr": "x:1"}}`, [2]string{}, nil, "parsing config file"},
+		{"unknown extension", "config.ini", "addr=x", [2]string{}, nil, "unknown extension"},
+		{"bad env value", "", "", [2]string{"RPC_CLIENT_RETRIES", "many"}, nil, EnvPrefix + "RPC_CLIENT_RETRIES"},
+		{"bad flag value", "", "", [2]string{}, []string{"-retries", "many"}, "invalid value"},
+		{"invalid value", "", "", [2]string{}, []string{"-retries", "-1"}, "rpc_client.retries"},
+		{"api keys missing", "", "", [2]string{"RATE_LIMIT_KEY", "api-key"}, nil, "rate_limit.api_keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvPrefix+"CONFIG", "")
+			if tt.env[0] != "" {
+				t.Setenv(EnvPrefix+tt.env[0], tt.env[1])
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeFile(t, tt.file, tt.body))
+			}
+
+			_, _, err := Load("test", args, bindRPCClient)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The protocols a contract can be about. They differ in how struct fields are named on the
+// wire: net/rpc's gob uses the Go field names, JSON-RPC the json tags, matched case-insensitively.
+const (
+	NetRPC  = "net/rpc"
+	JSONRPC = "json-rpc"
+)
+
+// Contract lists the calls one consumer makes to one provider, as the consumer sees them.
+type Contract struct {
+	Consumer     string        `json:"consumer"`
+	Provider     string        `json:"provider"`
+	Protocol     string        `json:"protocol"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one method the consumer calls, with what it sends and what it reads back.
+type Interaction struct {
+	Method string `json:"method"`
+	Args   Shape  `json:"args"`
+	Reply  Shape  `json:"reply"`
+}
+
+// Shape is a value as the codec sees it. Kind is bool, int, uint, float, string, bytes, time,
+// struct, slice, map or any; structs list their fields, slices and maps their element.
+type Shape struct {
+	Kind   string           `json:"kind"`
+	Fields map[string]Shape `json:"fields,omitempty"`
+	Elem   *Shape           `json:"elem,omitempty"`
+}
+
+// ShapeOf describes t as protocol encodes it.
+func ShapeOf(t reflect.Type, protocol string) Shape {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Shape{Kind: "bool"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Shape{Kind: "int"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Shape{Kind: "uint"}
+	case reflect.Float32, reflect.Float64:
+		return Shape{Kind: "float"}
+	case reflect.String:
+		return Shape{Kind: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Shape{Kind: "bytes"}
+		}
+		elem := ShapeOf(t.Elem(), protocol)
+		return Shape{Kind: "slice", Elem: &elem}
+	case reflect.Map:
+		elem := ShapeOf(t.Elem(), protocol)
+		return Shape{Kind: "map", Elem: &elem}
+	case reflect.Struct:
+		if t == reflect.TypeFor[time.Time]() {
+			// both codecs marshal times themselves
+			return Shape{Kind: "time"}
+		}
+		var fields map[string]Shape
+		for _, f := range reflect.VisibleFields(t) {
+			if !f.IsExported() || f.Anonymous {
+				continue
+			}
+			name := fieldName(f, protocol)
+			if name == "" {
+				continue
+			}
+			if fields == nil {
+				fields = map[string]Shape{}
+			}
+			fields[name] = ShapeOf(f.Type, protocol)
+		}
+		return Shape{Kind: "struct", Fields: fields}
+	default:
+		return Shape{Kind: "any"}
+	}
+}
+
+// fieldName is the name of f on the wire, empty when it is not encoded.
+func fieldName(f reflect.StructField, protocol string) string {
+	if protocol != JSONRPC {
+		return f.Name
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// Compatible lists how got, the provider's side of a value, fails what want, the consumer's
+// side, expects. Whichever way the value travels, every field the consumer knows must exist on
+// the provider with the same kind: a field only the sender has is dropped by both codecs, and
+// one only the receiver has stays at its zero value, silently in both cases.
+func Compatible(path string, want, got Shape, protocol string) []string {
+	if want.Kind == "any" || got.Kind == "any" {
+		return nil
+	}
+	if want.Kind != got.Kind {
+		return []string{fmt.Sprintf("%s: consumer has %s, provider has %s", path, want.Kind, got.Kind)}
+	}
+
+	var problems []string
+	if want.Elem != nil && got.Elem != nil {
+		problems = append(problems, Compatible(path+"[]", *want.Elem, *got.Elem, protocol)...)
+	}
+
+	names := make([]string, 0, len(want.Fields))
+	for name := range want.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, ok := lookup(got.Fields, name, protocol)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s.%s: missing on the provider", path, name))
+			continue
+		}
+		problems = append(problems, Compatible(path+"."+name, want.Fields[name], field, protocol)...)
+	}
+	return problems
+}
+
+// lookup finds a field the way the protocol's decoder does.
+func lookup(fields map[string]Shape, name, protocol string) (Shape, bool) {
+	if field, ok := fields[name]; ok {
+		return field, true
+	}
+	if protocol == JSONRPC {
+		for other, field := range fields {
+			if strings.EqualFold(other, name) {
+				return field, true
+			}
+		}
+	}
+	return Shape{}, false
+}
+
+// Load reads a contract file.
+func Load(path string) (Contract, error) {
+	var c Contract
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Write stores c at path, indented so changes read well in a diff.
+func Write(path string, c Contract) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
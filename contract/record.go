@@ -0,0 +1,69 @@
+package contract
+
+import (
+	"encoding/json"
+	"flag"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update-contracts", false, "rewrite the contract files from the calls the consumers make")
+
+// Recorder stands in for the provider in consumer tests. The consumer calls it like an
+// *rpc.Client; every call is answered with a zero reply and recorded as an interaction.
+type Recorder struct {
+	t        testing.TB
+	contract Contract
+}
+
+func NewRecorder(t testing.TB, consumer, provider, protocol string) *Recorder {
+	return &Recorder{t: t, contract: Contract{Consumer: consumer, Provider: provider, Protocol: protocol}}
+}
+
+// Call records method with the types of args and reply, and leaves reply untouched.
+func (r *Recorder) Call(method string, args any, reply any) error {
+	r.t.Helper()
+	if reflect.TypeOf(reply).Kind() != reflect.Pointer {
+		r.t.Fatalf("%s: reply must be a pointer, not %T", method, reply)
+	}
+
+	interaction := Interaction{
+		Method: method,
+		Args:   ShapeOf(reflect.TypeOf(args), r.contract.Protocol),
+		Reply:  ShapeOf(reflect.TypeOf(reply), r.contract.Protocol),
+	}
+	i, found := slices.BinarySearchFunc(r.contract.Interactions, method, func(in Interaction, method string) int {
+		return strings.Compare(in.Method, method)
+	})
+	if found {
+		r.contract.Interactions[i] = interaction
+	} else {
+		r.contract.Interactions = slices.Insert(r.contract.Interactions, i, interaction)
+	}
+	return nil
+}
+
+// Check compares the recorded calls with the contract file at path. With -update-contracts the
+// file is rewritten instead, after which the provider tests show whether the servers still
+// satisfy the consumer.
+func (r *Recorder) Check(path string) {
+	r.t.Helper()
+	if *update {
+		if err := Write(path, r.contract); err != nil {
+			r.t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := Load(path)
+	if err != nil {
+		r.t.Fatalf("%v; run the test with -update-contracts to create it", err)
+	}
+	if !reflect.DeepEqual(r.contract, want) {
+		got, _ := json.MarshalIndent(r.contract, "", "  ")
+		r.t.Errorf("%s calls the provider differently than %s says; run the test with -update-contracts and the provider tests. Recorded:\n%s",
+			r.contract.Consumer, path, got)
+	}
+}
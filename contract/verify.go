@@ -0,0 +1,183 @@
+package contract
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gjson "github.com/gorilla/rpc/json"
+)
+
+// Provider is the server side of the contracts.
+type Provider struct {
+	// Name is what the contracts call the provider.
+	Name string
+	// Handler serves the RPC endpoint, as NewHandler of the server package returns it.
+	Handler http.Handler
+	// Path is where Handler answers RPC calls.
+	Path string
+	// Receivers are the values registered on the RPC server.
+	Receivers []any
+}
+
+// Verify checks p against every contract in dir written for it. Each method must be
+// registered with args and reply types carrying what the consumer sends and reads, and a call
+// built from the contract alone must succeed through p.Handler.
+func Verify(t *testing.T, dir string, p Provider) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(p.Handler)
+	t.Cleanup(srv.Close)
+
+	verified := 0
+	for _, path := range paths {
+		c, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Provider != p.Name {
+			continue
+		}
+		verified++
+
+		for _, in := range c.Interactions {
+			t.Run(c.Consumer+"/"+in.Method, func(t *testing.T) {
+				args, reply, err := signature(p.Receivers, in.Method, c.Protocol)
+				if err != nil {
+					t.Fatalf("%s calls %s: %v", c.Consumer, in.Method, err)
+				}
+				for _, problem := range Compatible("args", in.Args, ShapeOf(args, c.Protocol), c.Protocol) {
+					t.Error(problem)
+				}
+				for _, problem := range Compatible("reply", in.Reply, ShapeOf(reply, c.Protocol), c.Protocol) {
+					t.Error(problem)
+				}
+
+				if err := call(srv, p.Path, c.Protocol, in); err != nil {
+					t.Errorf("calling %s as %s does: %v", in.Method, c.Consumer, err)
+				}
+			})
+		}
+	}
+	if verified == 0 {
+		t.Fatalf("no contract in %s is written for %s", dir, p.Name)
+	}
+}
+
+// signature finds the registered method and returns its args and reply types, following the
+// rules net/rpc and gorilla/rpc register methods by.
+func signature(receivers []any, method, protocol string) (args, reply reflect.Type, err error) {
+	service, name, ok := strings.Cut(method, ".")
+	if !ok {
+		return nil, nil, fmt.Errorf("method %q is not Service.Method", method)
+	}
+
+	for _, receiver := range receivers {
+		if reflect.Indirect(reflect.ValueOf(receiver)).Type().Name() != service {
+			continue
+		}
+		m, ok := reflect.TypeOf(receiver).MethodByName(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s has no method %s", service, name)
+		}
+
+		// net/rpc: (args T, reply *R) error, gorilla/rpc: (r *http.Request, args *T, reply *R) error
+		in := 3
+		if protocol == JSONRPC {
+			in = 4
+		}
+		t := m.Type
+		if t.NumIn() != in || t.NumOut() != 1 || t.Out(0) != reflect.TypeFor[error]() || t.In(in-1).Kind() != reflect.Pointer ||
+			(protocol == JSONRPC && t.In(1) != reflect.TypeFor[*http.Request]()) {
+			return nil, nil, fmt.Errorf("%s is not an RPC method: %s", method, t)
+		}
+		return t.In(in - 2), t.In(in - 1), nil
+	}
+	return nil, nil, fmt.Errorf("no service %s is registered", service)
+}
+
+// call sends zero values of the types the contract describes, as a client knowing nothing but
+// the contract would.
+func call(srv *httptest.Server, path, protocol string, in Interaction) error {
+	args := reflect.New(typeOf(in.Args, protocol)).Interface()
+	reply := reflect.New(typeOf(in.Reply, protocol)).Interface()
+
+	if protocol == JSONRPC {
+		body, err := gjson.EncodeClientRequest(in.Method, args)
+		if err != nil {
+			return err
+		}
+		resp, err := srv.Client().Post(srv.URL+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		return gjson.DecodeClientResponse(resp.Body, reply)
+	}
+
+	client, err := rpc.DialHTTPPath("tcp", srv.Listener.Addr().String(), path)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Call(in.Method, args, reply)
+}
+
+// typeOf builds a Go type encoding like s.
+func typeOf(s Shape, protocol string) reflect.Type {
+	switch s.Kind {
+	case "bool":
+		return reflect.TypeFor[bool]()
+	case "int":
+		return reflect.TypeFor[int64]()
+	case "uint":
+		return reflect.TypeFor[uint64]()
+	case "float":
+		return reflect.TypeFor[float64]()
+	case "string":
+		return reflect.TypeFor[string]()
+	case "bytes":
+		return reflect.TypeFor[[]byte]()
+	case "time":
+		return reflect.TypeFor[time.Time]()
+	case "slice":
+		return reflect.SliceOf(typeOf(*s.Elem, protocol))
+	case "map":
+		return reflect.MapOf(reflect.TypeFor[string](), typeOf(*s.Elem, protocol))
+	case "struct":
+		names := make([]string, 0, len(s.Fields))
+		for name := range s.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fields := make([]reflect.StructField, len(names))
+		for i, name := range names {
+			fields[i] = reflect.StructField{Name: name, Type: typeOf(s.Fields[name], protocol)}
+			if protocol == JSONRPC {
+				// json tags may not be Go identifiers
+				fields[i].Name = "F" + strconv.Itoa(i)
+				fields[i].Tag = reflect.StructTag(`json:"` + name + `"`)
+			}
+		}
+		return reflect.StructOf(fields)
+	default:
+		return reflect.TypeFor[any]()
+	}
+}
@@ -0,0 +1,91 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// EarthRadiusKm is the mean radius of the earth, the haversine distance is measured on a sphere of it.
+const EarthRadiusKm = 6371.0
+
+// kmPerDegree is the length of one degree of latitude.
+const kmPerDegree = EarthRadiusKm * math.Pi / 180
+
+// Point is a WGS 84 position in degrees.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Validate reports an error when p is not on the globe.
+func (p Point) Validate() error {
+	if math.IsNaN(p.Lat) || p.Lat < -90 || p.Lat > 90 {
+		return fmt.Errorf("latitude %v is not between -90 and 90", p.Lat)
+	}
+	if math.IsNaN(p.Lon) || p.Lon < -180 || p.Lon > 180 {
+		return fmt.Errorf("longitude %v is not between -180 and 180", p.Lon)
+	}
+	return nil
+}
+
+// ParsePoint reads a point from lat and lon query values.
+func ParsePoint(lat, lon string) (Point, error) {
+	if lat == "" || lon == "" {
+		return Point{}, errors.New("lat and lon are required")
+	}
+
+	var p Point
+	var err error
+	if p.Lat, err = strconv.ParseFloat(lat, 64); err != nil {
+		return Point{}, fmt.Errorf("lat %q is not a number", lat)
+	}
+	if p.Lon, err = strconv.ParseFloat(lon, 64); err != nil {
+		return Point{}, fmt.Errorf("lon %q is not a number", lon)
+	}
+	return p, p.Validate()
+}
+
+// DistanceKm is the great-circle distance between p and q, by the haversine formula.
+func (p Point) DistanceKm(q Point) float64 {
+	lat1, lat2 := p.Lat*math.Pi/180, q.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (q.Lon - p.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// BBox is a latitude/longitude rectangle, edges included.
+type BBox struct {
+	MinLat, MinLon, MaxLat, MaxLon float64
+}
+
+// Around returns a box holding every point within radiusKm of p. It is a prefilter: the corners
+// are further away than radiusKm, so candidates still need their DistanceKm checked.
+// When the circle reaches a pole or crosses the antimeridian it spans every longitude.
+func Around(p Point, radiusKm float64) BBox {
+	dLat := radiusKm / kmPerDegree
+	b := BBox{MinLat: math.Max(-90, p.Lat-dLat), MaxLat: math.Min(90, p.Lat+dLat), MinLon: -180, MaxLon: 180}
+	if p.Lat+dLat >= 90 || p.Lat-dLat <= -90 {
+		return b
+	}
+
+	// the meridians tangent to the circle, which are further apart than dLat/cos(lat)
+	dLon := math.Asin(math.Sin(radiusKm/EarthRadiusKm)/math.Cos(p.Lat*math.Pi/180)) * 180 / math.Pi
+	if p.Lon-dLon >= -180 && p.Lon+dLon <= 180 {
+		b.MinLon, b.MaxLon = p.Lon-dLon, p.Lon+dLon
+	}
+	return b
+}
+
+// Contains reports whether p lies in b.
+func (b BBox) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+}
+
+// Intersects reports whether b and o share at least a point.
+func (b BBox) Intersects(o BBox) bool {
+	return b.MinLat <= o.MaxLat && o.MinLat <= b.MaxLat && b.MinLon <= o.MaxLon && o.MinLon <= b.MaxLon
+}
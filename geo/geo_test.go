@@ -0,0 +1,167 @@
+package geo
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		name string
+		p, q Point
+		want float64
+	}{
+		{"same point", Point{6.5244, 3.3792}, Point{6.5244, 3.3792}, 0},
+		{"Lagos to Abuja", Point{6.5244, 3.3792}, Point{9.0765, 7.3986}, 525.9},
+		{"London to Paris", Point{51.5074, -0.1278}, Point{48.8566, 2.3522}, 343.6},
+		{"New York to Los Angeles", Point{40.7128, -74.0060}, Point{34.0522, -118.2437}, 3935.7},
+		{"Sydney to Auckland", Point{-33.8688, 151.2093}, Point{-36.8485, 174.7633}, 2155.9},
+		{"across the antimeridian", Point{0, 179.5}, Point{0, -179.5}, 111.2},
+		{"antipodes", Point{0, 0}, Point{0, 180}, math.Pi * EarthRadiusKm},
+		{"pole to pole", Point{90, 0}, Point{-90, 0}, math.Pi * EarthRadiusKm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.DistanceKm(tt.q); math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("DistanceKm = %.2f, want %.1f", got, tt.want)
+			}
+			if there, back := tt.p.DistanceKm(tt.q), tt.q.DistanceKm(tt.p); there != back {
+				t.Errorf("DistanceKm is %v one way and %v the other", there, back)
+			}
+		})
+	}
+}
+
+func TestAround(t *testing.T) {
+	// a radius of one degree of latitude
+	degree := kmPerDegree
+
+	tests := []struct {
+		name   string
+		center Point
+		radius float64
+		want   BBox
+		// inside are points within radius of center the box must hold
+		inside []Point
+	}{
+		{"equator", Point{0, 10}, degree, BBox{MinLat: -1, MinLon: 9, MaxLat: 1, MaxLon: 11}, []Point{{0, 10.99}, {-0.99, 10}}},
+		{"sixty degrees north", Point{60, 10}, degree, BBox{MinLat: 59, MinLon: 8, MaxLat: 61, MaxLon: 12}, []Point{{60, 11.9}, {60.0151, 12.0001}}},
+		{"near the north pole", Point{89.5, 0}, degree, BBox{MinLat: 88.5, MinLon: -180, MaxLat: 90, MaxLon: 180}, []Point{{89.9, 179}, {90, 0}}},
+		{"on the south pole", Point{-90, 0}, degree, BBox{MinLat: -90, MinLon: -180, MaxLat: -89, MaxLon: 180}, []Point{{-89.5, 90}}},
+		{"west of the antimeridian", Point{0, 179.8}, 50, BBox{MinLat: -50 / degree, MinLon: -180, MaxLat: 50 / degree, MaxLon: 180}, []Point{{0, -179.9}}},
+		{"east of the antimeridian", Point{0, -179.8}, 50, BBox{MinLat: -50 / degree, MinLon: -180, MaxLat: 50 / degree, MaxLon: 180}, []Point{{0, 179.9}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Around(tt.center, tt.radius)
+			for _, pair := range [][2]float64{{got.MinLat, tt.want.MinLat}, {got.MinLon, tt.want.MinLon}, {got.MaxLat, tt.want.MaxLat}, {got.MaxLon, tt.want.MaxLon}} {
+				if math.Abs(pair[0]-pair[1]) > 1e-3 {
+					t.Fatalf("Around = %+v, want %+v", got, tt.want)
+				}
+			}
+			for _, p := range tt.inside {
+				if d := tt.center.DistanceKm(p); d > tt.radius {
+					t.Fatalf("test point %v is %.1f km away, outside the radius", p, d)
+				}
+				if !got.Contains(p) {
+					t.Errorf("box %+v misses %v", got, p)
+				}
+			}
+		})
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	square := func(minLon, minLat, maxLon, maxLat float64) []Position {
+		return []Position{{minLon, minLat}, {maxLon, minLat}, {maxLon, maxLat}, {minLon, maxLat}, {minLon, minLat}}
+	}
+	// 10 by 10 degrees with a 2 by 2 hole in the middle
+	donut := Polygon{square(0, 0, 10, 10), square(4, 4, 6, 6)}
+	// the square east of donut, sharing its edge at longitude 10
+	east := Polygon{square(10, 0, 20, 10)}
+	triangle := Polygon{{{0, 0}, {10, 0}, {0, 10}, {0, 0}}}
+
+	tests := []struct {
+		name    string
+		polygon Polygon
+		point   Point
+		want    bool
+	}{
+		{"inside", donut, Point{2, 2}, true},
+		{"outside", donut, Point{2, 12}, false},
+		{"in the hole", donut, Point{5, 5}, false},
+		{"left of the hole", donut, Point{5, 3.9}, true},
+		{"inside the triangle", triangle, Point{4, 4}, true},
+		{"beyond the hypotenuse", triangle, Point{6, 6}, false},
+		{"no rings", Polygon{}, Point{0, 0}, false},
+
+		// edges are half-open: the west and south ones belong to the polygon, the east and
+		// north ones to its neighbour, so a point on a shared edge is in exactly one polygon
+		{"west edge", donut, Point{5, 0}, true},
+		{"south edge", donut, Point{0, 5}, true},
+		{"east edge", donut, Point{5, 10}, false},
+		{"east edge from the east", east, Point{5, 10}, true},
+		{"north edge", donut, Point{10, 5}, false},
+		{"south-west vertex", donut, Point{0, 0}, true},
+		{"south-east vertex", donut, Point{0, 10}, false},
+		{"north-east vertex", donut, Point{10, 10}, false},
+		{"north-west vertex", donut, Point{10, 0}, false},
+
+		// the edges of a hole are flipped: its west edge is outside the polygon, its east edge inside
+		{"west edge of the hole", donut, Point{5, 4}, false},
+		{"east edge of the hole", donut, Point{5, 6}, true},
+		{"vertex of the hole", donut, Point{4, 4}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.polygon.Contains(tt.point); got != tt.want {
+				t.Errorf("Contains(%v) = %v, want %v", tt.point, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	x := NewIndex(1)
+	x.Insert(1, BBox{MinLat: 0, MinLon: 0, MaxLat: 2, MaxLon: 2})
+	x.Insert(2, BBox{MinLat: 1.5, MinLon: 1.5, MaxLat: 3, MaxLon: 3})
+	x.Insert(3, BBox{MinLat: -1.5, MinLon: -1.5, MaxLat: -0.5, MaxLon: -0.5})
+
+	search := func(name string, p Point, want ...int) {
+		t.Helper()
+		if got := x.Search(p); !slices.Equal(got, want) {
+			t.Errorf("%s: Search(%v) = %v, want %v", name, p, got, want)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		point Point
+		want  []int
+	}{
+		{"one box", Point{0.5, 0.5}, []int{1}},
+		{"overlap", Point{1.7, 1.7}, []int{1, 2}},
+		{"on a shared corner", Point{2, 2}, []int{1, 2}},
+		{"in a cell but not its box", Point{1.2, 1.2}, []int{1}},
+		{"negative coordinates", Point{-1, -1}, []int{3}},
+		{"on a cell border", Point{-1, -0.5}, []int{3}},
+		{"nothing there", Point{5, 5}, nil},
+	}
+	for _, tt := range tests {
+		search(tt.name, tt.point, tt.want...)
+	}
+
+	// inserting again moves the box, removing drops it from every cell it was in
+	x.Insert(1, BBox{MinLat: 10, MinLon: 10, MaxLat: 11, MaxLon: 11})
+	search("moved away", Point{0.5, 0.5})
+	search("moved to", Point{10.5, 10.5}, 1)
+	x.Remove(2)
+	x.Remove(2)
+	search("removed", Point{2.5, 2.5})
+	x.Remove(1)
+	x.Remove(3)
+	if len(x.cells) != 0 || len(x.boxes) != 0 {
+		t.Errorf("empty index holds %d cells and %d boxes", len(x.cells), len(x.boxes))
+	}
+}
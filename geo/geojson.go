@@ -0,0 +1,59 @@
+package geo
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// MIME is the GeoJSON media type (RFC 7946).
+const MIME = "application/geo+json"
+
+// Geometry is a GeoJSON geometry object.
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// PointGeometry returns p as a GeoJSON Point.
+func PointGeometry(p Point) *Geometry {
+	return &Geometry{Type: "Point", Coordinates: Position{p.Lon, p.Lat}}
+}
+
+// PolygonGeometry returns p as a GeoJSON Polygon.
+func PolygonGeometry(p Polygon) *Geometry {
+	return &Geometry{Type: "Polygon", Coordinates: [][]Position(p)}
+}
+
+// Feature is a GeoJSON feature. A nil Geometry is written as null, for records without a location.
+type Feature struct {
+	Type       string         `json:"type"`
+	ID         any            `json:"id,omitempty"`
+	Geometry   *Geometry      `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// NewFeature returns a feature with the given id, geometry and properties.
+func NewFeature(id any, geometry *Geometry, properties map[string]any) Feature {
+	return Feature{Type: "Feature", ID: id, Geometry: geometry, Properties: properties}
+}
+
+// FeatureCollection is a GeoJSON feature collection, the document mapping tools load.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// NewFeatureCollection returns a collection of features, empty rather than null when there are none.
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// Write sends fc as an application/geo+json response.
+func (fc FeatureCollection) Write(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", MIME)
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(fc)
+}
@@ -0,0 +1,76 @@
+package geo
+
+import (
+	"math"
+	"slices"
+)
+
+type cell struct {
+	lat, lon int
+}
+
+// Index finds the boxes that may hold a point without looking at every box. It buckets each
+// box into the grid cells it overlaps; a lookup only checks the boxes of the point's cell.
+// Index is not safe for concurrent use, its owner guards it.
+type Index struct {
+	size  float64
+	cells map[cell][]int
+	boxes map[int]BBox
+}
+
+// NewIndex returns an empty index with cells of size degrees.
+func NewIndex(size float64) *Index {
+	return &Index{size: size, cells: make(map[cell][]int), boxes: make(map[int]BBox)}
+}
+
+func (x *Index) cellOf(lat, lon float64) cell {
+	return cell{lat: int(math.Floor(lat / x.size)), lon: int(math.Floor(lon / x.size))}
+}
+
+// Insert adds id with box b, replacing the box it had.
+func (x *Index) Insert(id int, b BBox) {
+	x.Remove(id)
+	x.boxes[id] = b
+
+	lo, hi := x.cellOf(b.MinLat, b.MinLon), x.cellOf(b.MaxLat, b.MaxLon)
+	for lat := lo.lat; lat <= hi.lat; lat++ {
+		for lon := lo.lon; lon <= hi.lon; lon++ {
+			c := cell{lat, lon}
+			x.cells[c] = append(x.cells[c], id)
+		}
+	}
+}
+
+// Remove drops id, it does nothing when id is not indexed.
+func (x *Index) Remove(id int) {
+	b, ok := x.boxes[id]
+	if !ok {
+		return
+	}
+	delete(x.boxes, id)
+
+	lo, hi := x.cellOf(b.MinLat, b.MinLon), x.cellOf(b.MaxLat, b.MaxLon)
+	for lat := lo.lat; lat <= hi.lat; lat++ {
+		for lon := lo.lon; lon <= hi.lon; lon++ {
+			c := cell{lat, lon}
+			ids := slices.DeleteFunc(x.cells[c], func(other int) bool { return other == id })
+			if len(ids) == 0 {
+				delete(x.cells, c)
+			} else {
+				x.cells[c] = ids
+			}
+		}
+	}
+}
+
+// Search returns the ids whose box contains p, in ascending order.
+func (x *Index) Search(p Point) []int {
+	var ids []int
+	for _, id := range x.cells[x.cellOf(p.Lat, p.Lon)] {
+		if x.boxes[id].Contains(p) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,110 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Position is a GeoJSON position, longitude first.
+type Position [2]float64
+
+// Point returns the position as a Point.
+func (p Position) Point() Point {
+	return Point{Lat: p[1], Lon: p[0]}
+}
+
+// Polygon is a GeoJSON polygon: an outer ring followed by the rings of its holes. Each ring is
+// closed, its last position repeats the first one.
+type Polygon [][]Position
+
+// polygonJSON is the GeoJSON geometry object a Polygon is read from and written as.
+type polygonJSON struct {
+	Type        string       `json:"type"`
+	Coordinates [][]Position `json:"coordinates"`
+}
+
+func (p Polygon) MarshalJSON() ([]byte, error) {
+	return json.Marshal(polygonJSON{Type: "Polygon", Coordinates: p})
+}
+
+func (p *Polygon) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*p = nil
+		return nil
+	}
+
+	var g polygonJSON
+	if err := json.Unmarshal(data, &g); err != nil {
+		return err
+	}
+	if g.Type != "Polygon" {
+		return fmt.Errorf("geometry type %q is not Polygon", g.Type)
+	}
+	*p = g.Coordinates
+	return nil
+}
+
+// Validate checks that p has an outer ring, that every ring is closed with at least four
+// positions, and that every position is on the globe.
+func (p Polygon) Validate() error {
+	if len(p) == 0 {
+		return errors.New("polygon has no rings")
+	}
+	for i, ring := range p {
+		if len(ring) < 4 {
+			return fmt.Errorf("ring %d has %d positions, at least 4 are needed", i, len(ring))
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("ring %d is not closed", i)
+		}
+		for _, pos := range ring {
+			if err := pos.Point().Validate(); err != nil {
+				return fmt.Errorf("ring %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Bounds returns the box around the outer ring.
+func (p Polygon) Bounds() BBox {
+	b := BBox{MinLat: 90, MinLon: 180, MaxLat: -90, MaxLon: -180}
+	if len(p) == 0 {
+		return b
+	}
+	for _, pos := range p[0] {
+		b.MinLon, b.MaxLon = min(b.MinLon, pos[0]), max(b.MaxLon, pos[0])
+		b.MinLat, b.MaxLat = min(b.MinLat, pos[1]), max(b.MaxLat, pos[1])
+	}
+	return b
+}
+
+// Contains reports whether pt lies inside the outer ring and outside every hole. Edges are
+// treated as planar in degrees, which is close enough for city-sized polygons.
+func (p Polygon) Contains(pt Point) bool {
+	if len(p) == 0 || !inRing(p[0], pt) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if inRing(hole, pt) {
+			return false
+		}
+	}
+	return true
+}
+
+// inRing casts a ray from pt towards increasing longitude and counts the edges it crosses.
+func inRing(ring []Position, pt Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > pt.Lat) != (b[1] > pt.Lat) {
+			lon := a[0] + (pt.Lat-a[1])*(b[0]-a[0])/(b[1]-a[1])
+			if pt.Lon < lon {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
@@ -0,0 +1,162 @@
+package ginfundamentals
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dav16Akin/go-dictionary/bulk"
+)
+
+var stationCSVHeader = []string{"id", "name", "opening_time", "closing_time", "latitude", "longitude"}
+
+// csvCoordinate reads an optional coordinate column, empty means unknown.
+func csvCoordinate(record map[string]string, column string) (*float64, error) {
+	value := record[column]
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q is not a number", column, value)
+	}
+	return &parsed, nil
+}
+
+func stationFromCSV(record map[string]string) (StationResource, error) {
+	latitude, err := csvCoordinate(record, "latitude")
+	if err != nil {
+		return StationResource{}, err
+	}
+	longitude, err := csvCoordinate(record, "longitude")
+	if err != nil {
+		return StationResource{}, err
+	}
+
+	return StationResource{
+		Name:        record["name"],
+		OpeningTime: record["opening_time"],
+		ClosingTime: record["closing_time"],
+		Latitude:    latitude,
+		Longitude:   longitude,
+	}, nil
+}
+
+func stationToCSV(station StationResource) []string {
+	coordinate := func(value *float64) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*value, 'f', -1, 64)
+	}
+	return []string{strconv.Itoa(station.ID), station.Name, station.OpeningTime, station.ClosingTime, coordinate(station.Latitude), coordinate(station.Longitude)}
+}
+
+func insertStation(tx *sql.Tx, station StationResource) (int64, error) {
+	if station.Name == "" {
+		return 0, errors.New("name is required")
+	}
+	if err := station.validateLocation(); err != nil {
+		return 0, err
+	}
+	if err := station.validateTimes(); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec("insert into station (NAME, OPENING_TIME, CLOSING_TIME, LATITUDE, LONGITUDE) values (?, ?, ?, ?, ?)", station.Name, station.OpeningTime, station.ClosingTime, station.Latitude, station.Longitude)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func ImportStations(c *gin.Context) {
+	mode, err := bulk.ParseMode(c.Query("mode"))
+	if err != nil {
+		errorJSON(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format, err := bulk.FormatFromContentType(c.GetHeader("Content-Type"))
+	if err != nil {
+		errorJSON(c, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	rows, err := bulk.Decode(c.Request.Body, format, stationFromCSV)
+	if err != nil {
+		errorJSON(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(rows) == 0 {
+		errorJSON(c, http.StatusBadRequest, "No stations to import")
+		return
+	}
+
+	report, err := bulk.Import(c.Request.Context(), DB, mode, rows, insertStation)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error importing stations", "err", err)
+		errorJSON(c, http.StatusInternalServerError, "Failed to import stations")
+		return
+	}
+
+	c.JSON(report.HTTPStatus(), gin.H{"result": report})
+}
+
+func ExportStations(c *gin.Context) {
+	format, err := bulk.FormatForExport(c.Query("format"), c.GetHeader("Accept"))
+	if err != nil {
+		errorJSON(c, http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	rows, err := DB.QueryContext(c.Request.Context(), "SELECT ID, NAME, CAST(OPENING_TIME as CHAR), CAST(CLOSING_TIME as CHAR), LATITUDE, LONGITUDE FROM station ORDER BY ID")
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error querying stations for export", "err", err)
+		errorJSON(c, http.StatusInternalServerError, "Failed to export stations")
+		return
+	}
+
+	defer rows.Close()
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="stations.`+string(format)+`"`)
+	c.Status(http.StatusOK)
+
+	writer := bulk.NewWriter(c.Writer, format, stationCSVHeader, stationToCSV)
+
+	for rows.Next() {
+		var station StationResource
+		var name, openingTime, closingTime sql.NullString
+
+		if err := rows.Scan(&station.ID, &name, &openingTime, &closingTime, &station.Latitude, &station.Longitude); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error scanning station", "err", err)
+			return
+		}
+
+		station.Name = name.String
+		station.OpeningTime = openingTime.String
+		station.ClosingTime = closingTime.String
+
+		if err := writer.Write(station); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error writing station export", "err", err)
+			return
+		}
+	}
+
+	// headers are already sent, a late failure can only be logged
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Row iteration error", "err", err)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error finishing station export", "err", err)
+	}
+}
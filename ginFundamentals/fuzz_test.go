@@ -0,0 +1,110 @@
+package ginfundamentals
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dav16Akin/go-dictionary/railAPI/railtest"
+)
+
+// fuzzRouter serves the stations API from a database seeded with the rail network, shared by
+// every input of the fuzz target.
+func fuzzRouter(f *testing.F) *gin.Engine {
+	db := railtest.NewDB(f)
+	db.SeedNetwork()
+	if err := Open(db.Path); err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { DB.Close() })
+	return NewRouter()
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// FuzzCreateStation posts arbitrary bodies. Malformed ones must be refused with a 4xx, and an
+// accepted station must read back exactly as it was created.
+func FuzzCreateStation(f *testing.F) {
+	for _, seed := range []string{
+		`{"name":"Grand Central","opening_time":"08:00:00","closing_time":"22:00:00","latitude":40.7527,"longitude":-73.9772}`,
+		`{"name":"Kajola"}`,
+		`{"name":"Half","latitude":6.5}`,
+		`{"name":"Pole","latitude":90,"longitude":180}`,
+		`{"name":"Beyond","latitude":91,"longitude":0}`,
+		`{"name":"Early","opening_time":"1.50","closing_time":"1e3"}`,
+		`{"name":"Padded","opening_time":" 12 "}`,
+		`{"name":7}`,
+		`{"latitude":"north"}`,
+		`{"name":"é🚉` + "\x00\xff" + `"}`,
+		`[]`,
+		`null`,
+		``,
+	} {
+		f.Add([]byte(seed))
+	}
+	router := fuzzRouter(f)
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest("POST", "/v1/stations", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := serve(router, req)
+
+		switch {
+		case rec.Code >= 500:
+			t.Fatalf("POST %q = %d: %s", body, rec.Code, rec.Body)
+		case rec.Code != http.StatusCreated:
+			return
+		}
+
+		var created struct {
+			Result StationResource `json:"result"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+			t.Fatalf("created station %s: %v", rec.Body, err)
+		}
+
+		got := serve(router, httptest.NewRequest("GET", "/v1/stations/"+strconv.Itoa(created.Result.ID), nil))
+		if got.Code != http.StatusOK {
+			t.Fatalf("GET created station %d = %d: %s", created.Result.ID, got.Code, got.Body)
+		}
+		var read struct {
+			Result StationResource `json:"result"`
+		}
+		if err := json.Unmarshal(got.Body.Bytes(), &read); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(read, created) {
+			t.Fatalf("station read back as %s, created as %s", got.Body, rec.Body)
+		}
+	})
+}
+
+// FuzzStationID requests stations by arbitrary path parameters, which must never fail the server.
+func FuzzStationID(f *testing.F) {
+	for _, seed := range []string{"1", "5", "0", "-1", "01", "1.5", " 1", "abc", "9223372036854775808", "1 OR 1=1", "%", ""} {
+		f.Add(seed)
+	}
+	router := fuzzRouter(f)
+
+	f.Fuzz(func(t *testing.T, id string) {
+		for _, path := range []string{"/v1/stations/" + url.PathEscape(id), "/v1/stations/" + url.PathEscape(id) + "/city"} {
+			for _, method := range []string{"GET", "DELETE"} {
+				rec := serve(router, httptest.NewRequest(method, path, nil))
+				if rec.Code >= 500 {
+					t.Fatalf("%s %s = %d: %s", method, path, rec.Code, rec.Body)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package ginfundamentals
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dav16Akin/go-dictionary/geo"
+	"github.com/Dav16Akin/go-dictionary/negotiate"
+)
+
+const (
+	defaultRadiusKm = 10.0
+	maxRadiusKm     = 500.0
+)
+
+// NearStation is a station found around a point, with its distance from it.
+type NearStation struct {
+	StationResource
+	DistanceKm float64 `json:"distance_km" xml:"distance_km"`
+}
+
+// Location returns where the station is, false when it has no coordinates.
+func (s StationResource) Location() (geo.Point, bool) {
+	if s.Latitude == nil || s.Longitude == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: *s.Latitude, Lon: *s.Longitude}, true
+}
+
+func (s StationResource) validateLocation() error {
+	if (s.Latitude == nil) != (s.Longitude == nil) {
+		return errors.New("latitude and longitude go together")
+	}
+	if location, ok := s.Location(); ok {
+		return location.Validate()
+	}
+	return nil
+}
+
+// feature returns the station as a GeoJSON point feature, with a null geometry when it has no coordinates.
+func (s StationResource) feature(properties map[string]any) geo.Feature {
+	var geometry *geo.Geometry
+	if location, ok := s.Location(); ok {
+		geometry = geo.PointGeometry(location)
+	}
+	properties["name"] = s.Name
+	properties["opening_time"] = s.OpeningTime
+	properties["closing_time"] = s.ClosingTime
+	return geo.NewFeature(s.ID, geometry, properties)
+}
+
+// NearStations lists the stations within radius_km of lat and lon, nearest first. The spatial
+// index narrows the search to a box around the point, the distance check does the rest.
+// format=geojson returns them as a FeatureCollection.
+func NearStations(c *gin.Context) {
+	point, err := geo.ParsePoint(c.Query("lat"), c.Query("lon"))
+	if err != nil {
+		errorJSON(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	radius := defaultRadiusKm
+	if value := c.Query("radius_km"); value != "" {
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || radius <= 0 || radius > maxRadiusKm {
+			errorJSON(c, http.StatusBadRequest, "radius_km must be a number above 0 and at most 500")
+			return
+		}
+	}
+
+	box := geo.Around(point, radius)
+	rows, err := DB.QueryContext(c.Request.Context(), `
+		SELECT s.ID, s.NAME, CAST(s.OPENING_TIME as CHAR), CAST(s.CLOSING_TIME as CHAR), s.LATITUDE, s.LONGITUDE
+		FROM station_location l JOIN station s ON s.ID = l.ID
+		WHERE l.MAX_LAT >= ? AND l.MIN_LAT <= ? AND l.MAX_LON >= ? AND l.MIN_LON <= ?`,
+		box.MinLat, box.MaxLat, box.MinLon, box.MaxLon)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error querying stations near a point", "err", err)
+		errorJSON(c, http.StatusInternalServerError, "Error getting data from the database")
+		return
+	}
+
+	defer rows.Close()
+
+	stations := []NearStation{}
+	for rows.Next() {
+		station, err := scanStation(rows)
+		if err != nil {
+			errorJSON(c, http.StatusInternalServerError, "Error scanning station")
+			return
+		}
+
+		location, _ := station.Location()
+		if distance := point.DistanceKm(location); distance <= radius {
+			stations = append(stations, NearStation{StationResource: station, DistanceKm: distance})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		errorJSON(c, http.StatusInternalServerError, "Row iteration error")
+		return
+	}
+
+	slices.SortFunc(stations, func(a, b NearStation) int {
+		return cmp.Or(cmp.Compare(a.DistanceKm, b.DistanceKm), cmp.Compare(a.ID, b.ID))
+	})
+
+	if c.Query("format") == "geojson" {
+		features := make([]geo.Feature, 0, len(stations))
+		for _, station := range stations {
+			features = append(features, station.feature(map[string]any{"distance_km": station.DistanceKm}))
+		}
+		writeFeatures(c, features)
+		return
+	}
+
+	negotiate.Gin(c, http.StatusOK, gin.H{"stations": stations})
+}
+
+// StationsGeoJSON returns every station as a GeoJSON FeatureCollection, for mapping tools.
+// Stations without coordinates have a null geometry.
+func StationsGeoJSON(c *gin.Context) {
+	rows, err := DB.QueryContext(c.Request.Context(), "SELECT ID, NAME, CAST(OPENING_TIME as CHAR), CAST(CLOSING_TIME as CHAR), LATITUDE, LONGITUDE FROM station ORDER BY ID")
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error querying stations for GeoJSON", "err", err)
+		errorJSON(c, http.StatusInternalServerError, "Error getting data from the database")
+		return
+	}
+
+	defer rows.Close()
+
+	features := []geo.Feature{}
+	for rows.Next() {
+		station, err := scanStation(rows)
+		if err != nil {
+			errorJSON(c, http.StatusInternalServerError, "Error scanning station")
+			return
+		}
+		features = append(features, station.feature(map[string]any{}))
+	}
+	if err := rows.Err(); err != nil {
+		errorJSON(c, http.StatusInternalServerError, "Row iteration error")
+		return
+	}
+
+	writeFeatures(c, features)
+}
+
+// scanStation reads a row of ID, NAME, OPENING_TIME, CLOSING_TIME, LATITUDE and LONGITUDE,
+// imported stations may leave the names and times NULL.
+func scanStation(rows *sql.Rows) (StationResource, error) {
+	var station StationResource
+	var name, openingTime, closingTime sql.NullString
+	if err := rows.Scan(&station.ID, &name, &openingTime, &closingTime, &station.Latitude, &station.Longitude); err != nil {
+		return station, err
+	}
+	station.Name = name.String
+	station.OpeningTime = openingTime.String
+	station.ClosingTime = closingTime.String
+	return station, nil
+}
+
+func writeFeatures(c *gin.Context, features []geo.Feature) {
+	if err := geo.NewFeatureCollection(features).Write(c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error writing GeoJSON", "err", err)
+	}
+}
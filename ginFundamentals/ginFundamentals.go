@@ -1,29 +1,66 @@
 package ginfundamentals
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
 	"net/http"
-
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/cache"
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/negotiate"
 	dbutils "github.com/Dav16Akin/go-dictionary/railAPI/dbUtils"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/Dav16Akin/go-dictionary/ratelimit"
+	"github.com/Dav16Akin/go-dictionary/tracing"
+	"github.com/mattn/go-sqlite3"
 
 	"github.com/gin-gonic/gin"
+	"github.com/justinas/alice"
 )
 
 var DB *sql.DB
 
 type StationResource struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	OpeningTime string `json:"opening_time"`
-	ClosingTime string `json:"closing_time"`
+	ID          int      `json:"id" xml:"id"`
+	Name        string   `json:"name" xml:"name"`
+	OpeningTime string   `json:"opening_time" xml:"opening_time"`
+	ClosingTime string   `json:"closing_time" xml:"closing_time"`
+	Latitude    *float64 `json:"latitude,omitempty" xml:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty" xml:"longitude,omitempty"`
+}
+
+// timeLayouts are the opening and closing times accepted: a time of day, or a timestamp the
+// SQLite driver understands. The TIME columns store anything that looks like a number as one,
+// so "1.50" would be read back as "1.5".
+var timeLayouts = append([]string{"15:04", "15:04:05"}, sqlite3.SQLiteTimestampFormats...)
+
+func (s StationResource) validateTimes() error {
+	valid := func(value string) bool {
+		return value == "" || slices.ContainsFunc(timeLayouts, func(layout string) bool {
+			_, err := time.Parse(layout, value)
+			return err == nil
+		})
+	}
+	if !valid(s.OpeningTime) {
+		return fmt.Errorf("opening_time must be a time such as 08:00:00, not %q", s.OpeningTime)
+	}
+	if !valid(s.ClosingTime) {
+		return fmt.Errorf("closing_time must be a time such as 08:00:00, not %q", s.ClosingTime)
+	}
+	return nil
 }
 
 func GetStations(c *gin.Context) {
-	rows, err := DB.Query("SELECT ID, NAME, CAST(OPENING_TIME as CHAR), CAST(CLOSING_TIME as CHAR) FROM station")
+	rows, err := DB.QueryContext(c.Request.Context(), "SELECT ID, NAME, CAST(OPENING_TIME as CHAR), CAST(CLOSING_TIME as CHAR), LATITUDE, LONGITUDE FROM station")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error" : err.Error()})
+		errorJSON(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -39,13 +76,12 @@ func GetStations(c *gin.Context) {
 			&station.Name,
 			&station.OpeningTime,
 			&station.ClosingTime,
+			&station.Latitude,
+			&station.Longitude,
 		)
 
 		if err != nil {
-			c.JSON(
-				http.StatusInternalServerError,
-				gin.H{"error": "Error scanning station"},
-			)
+			errorJSON(c, http.StatusInternalServerError, "Error scanning station")
 			return
 		}
 
@@ -53,29 +89,48 @@ func GetStations(c *gin.Context) {
 	}
 
 	if err := rows.Err(); err != nil {
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "Row iteration error"},
-		)
+		errorJSON(c, http.StatusInternalServerError, "Row iteration error")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	negotiate.Gin(c, http.StatusOK, gin.H{
 		"stations": stations,
 	})
 }
+
+// errorJSON answers status with message and the request ID, which the client can quote when reporting the error.
+func errorJSON(c *gin.Context, status int, message string) {
+	c.JSON(status, gin.H{"error": message, "request_id": logging.RequestID(c.Request.Context())})
+}
+
+// stationID reads the :station_id path parameter, answering 400 itself when it is not a number.
+func stationID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("station_id"))
+	if err != nil {
+		errorJSON(c, http.StatusBadRequest, "invalid station ID")
+		return 0, false
+	}
+	return id, true
+}
+
 func GetStation(c *gin.Context) {
 	var station StationResource
 
-	id := c.Param("station_id")
-	err := DB.QueryRow("select ID, NAME, CAST(OPENING_TIME as CHAR), CAST(CLOSING_TIME as CHAR) from station where id=?", id).Scan(
-		&station.ID, &station.Name, &station.OpeningTime, &station.ClosingTime,
+	id, ok := stationID(c)
+	if !ok {
+		return
+	}
+	err := DB.QueryRowContext(c.Request.Context(), "select ID, NAME, CAST(OPENING_TIME as CHAR), CAST(CLOSING_TIME as CHAR), LATITUDE, LONGITUDE from station where id=?", id).Scan(
+		&station.ID, &station.Name, &station.OpeningTime, &station.ClosingTime, &station.Latitude, &station.Longitude,
 	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting data from the database"})
+	if err == sql.ErrNoRows {
+		errorJSON(c, http.StatusNotFound, "station not found")
+		return
+	} else if err != nil {
+		errorJSON(c, http.StatusInternalServerError, "Error getting data from the database")
 		return
 	} else {
-		c.JSON(http.StatusOK, gin.H{"result": station})
+		negotiate.Gin(c, http.StatusOK, gin.H{"result": station})
 		return
 	}
 }
@@ -84,32 +139,38 @@ func CreateStation(c *gin.Context) {
 	var station StationResource
 
 	if err := c.BindJSON(&station); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		errorJSON(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := station.validateLocation(); err != nil {
+		errorJSON(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := station.validateTimes(); err != nil {
+		errorJSON(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	statement, err := DB.Prepare("insert into station (NAME, OPENING_TIME, CLOSING_TIME) values (?, ?, ?)")
+	statement, err := DB.PrepareContext(c.Request.Context(), "insert into station (NAME, OPENING_TIME, CLOSING_TIME, LATITUDE, LONGITUDE) values (?, ?, ?, ?, ?)")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare statement"})
+		errorJSON(c, http.StatusInternalServerError, "Failed to prepare statement")
 		return
 	}
 
 	defer statement.Close()
 
-	result, err := statement.Exec(station.Name, station.OpeningTime, station.ClosingTime)
+	result, err := statement.ExecContext(c.Request.Context(), station.Name, station.OpeningTime, station.ClosingTime, station.Latitude, station.Longitude)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to insert station",
-		})
+		errorJSON(c, http.StatusInternalServerError, "Failed to insert station")
 		return
 	}
 
 	newID, err := result.LastInsertId()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get inserted ID",
-		})
+		errorJSON(c, http.StatusInternalServerError, "Failed to get inserted ID")
 		return
 	}
 
@@ -121,50 +182,101 @@ func CreateStation(c *gin.Context) {
 }
 
 func RemoveStation(c *gin.Context) {
-	id := c.Param("station_id")
-	statement, err := DB.Prepare("delete from station where id=?")
+	id, ok := stationID(c)
+	if !ok {
+		return
+	}
+	statement, err := DB.PrepareContext(c.Request.Context(), "delete from station where id=?")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		errorJSON(c, http.StatusInternalServerError, "Database error")
 		return
 	}
 
 	defer statement.Close()
 
-	result, err := statement.Exec(id)
+	result, err := statement.ExecContext(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting station"})
+		errorJSON(c, http.StatusInternalServerError, "Error deleting station")
 		return
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "databbase error"})
+		errorJSON(c, http.StatusInternalServerError, "databbase error")
 		return
 	}
 
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "station not found"})
+		errorJSON(c, http.StatusNotFound, "station not found")
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
-func RunGinAPI() {
+// Open connects to the SQLite database at dbPath and makes sure the station table exists.
+func Open(dbPath string) error {
 	var err error
-	DB, err = sql.Open("sqlite3", "./railapi.db")
+	DB, err = tracing.OpenDB("sqlite3", dbPath)
 	if err != nil {
-		log.Printf("Error Opening Database : %v", err)
+		return fmt.Errorf("opening database: %w", err)
+	}
+
+	if err = DB.Ping(); err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
 	}
 
 	dbutils.Initialize(DB)
+	metrics.RegisterDB("gin", DB)
+	return nil
+}
+
+// NewRouter returns the Gin engine serving the stations API.
+func NewRouter() *gin.Engine {
+	// gin.Default without its text logger, requests are logged by the structured one
+	router := gin.New()
+	router.Use(logging.Gin, gin.Recovery())
+	router.Use(metrics.GinRoute, ratelimit.ForService("gin").Gin)
+
+	// stations change a few times a day, reads are served from memory until a write succeeds
+	stations := router.Group("/v1/stations", cache.ForService("gin").Gin)
+	stations.GET("", GetStations)
+	stations.GET("/export", ExportStations)
+	stations.GET("/near", NearStations)
+	stations.GET("/geojson", StationsGeoJSON)
+	stations.GET("/:station_id", GetStation)
+	stations.POST("", CreateStation)
+	stations.POST("/import", ImportStations)
+	stations.DELETE("/:station_id", RemoveStation)
+
+	return router
+}
+
+// AddHealthChecks registers the stations database checks on a readiness checker.
+func AddHealthChecks(checker *health.Checker) {
+	checker.AddReadinessCheck("gin database", health.DBPing(DB))
+	checker.AddReadinessCheck("gin migrations", func(ctx context.Context) error {
+		return dbutils.Migrated(ctx, DB)
+	})
+}
+
+func RunGinAPI(cfg config.Gin, lc config.Lifecycle) error {
+	if err := Open(cfg.DBPath); err != nil {
+		return err
+	}
+
+	m := lifecycle.New(lc)
+
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
+	AddHealthChecks(checker)
 
-	router := gin.Default()
+	router := NewRouter()
+	checker.RegisterGin(router)
+	metrics.RegisterGin(router)
 
-	router.GET("/v1/stations", GetStations)
-	router.GET("/v1/stations/:station_id", GetStation)
-	router.POST("/v1/stations", CreateStation)
-	router.DELETE("/v1/stations/:station_id", RemoveStation)
+	m.AddServer("gin", &http.Server{Addr: cfg.Addr, Handler: alice.New(tracing.Middleware("gin"), metrics.Middleware("gin")).Then(router)})
+	m.AddCloser("gin database", DB)
 
-	router.Run(":8000")
+	return m.Run(context.Background())
 }
@@ -0,0 +1,203 @@
+package ginfundamentals
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Dav16Akin/go-dictionary/apitest"
+	"github.com/Dav16Akin/go-dictionary/geo"
+	"github.com/Dav16Akin/go-dictionary/railAPI/railtest"
+)
+
+func TestMain(m *testing.M) {
+	apitest.Setup()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newServer serves the stations API from an empty database in a temp file.
+func newServer(t *testing.T) *apitest.Server {
+	t.Helper()
+	if err := Open(apitest.TempDB(t)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { DB.Close() })
+	return apitest.NewServer(t, NewRouter())
+}
+
+func TestStations(t *testing.T) {
+	srv := newServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		status int
+		want   string
+		error  string
+	}{
+		{"list empty", "GET", "/v1/stations", nil, 200, `{"stations":[]}`, ""},
+		{"create", "POST", "/v1/stations", map[string]any{"name": "Ikeja", "opening_time": "05:00", "closing_time": "23:00", "latitude": 6.6, "longitude": 3.35}, 201,
+			`{"result":{"id":1,"name":"Ikeja","opening_time":"05:00","closing_time":"23:00","latitude":6.6,"longitude":3.35}}`, ""},
+		{"create without location", "POST", "/v1/stations", map[string]any{"name": "Kano", "opening_time": "06:00", "closing_time": "22:00"}, 201,
+			`{"result":{"id":2,"name":"Kano","opening_time":"06:00","closing_time":"22:00"}}`, ""},
+		{"create with half a location", "POST", "/v1/stations", map[string]any{"name": "X", "latitude": 1}, 400,
+			"", "latitude and longitude go together"},
+		{"get", "GET", "/v1/stations/1", nil, 200,
+			`{"result":{"id":1,"name":"Ikeja","opening_time":"05:00","closing_time":"23:00","latitude":6.6,"longitude":3.35}}`, ""},
+		{"get missing", "GET", "/v1/stations/9", nil, 404, "", "station not found"},
+		{"list", "GET", "/v1/stations", nil, 200, `{"stations":[
+			{"id":1,"name":"Ikeja","opening_time":"05:00","closing_time":"23:00","latitude":6.6,"longitude":3.35},
+			{"id":2,"name":"Kano","opening_time":"06:00","closing_time":"22:00"}]}`, ""},
+		{"delete", "DELETE", "/v1/stations/2", nil, 204, "", ""},
+		{"delete again", "DELETE", "/v1/stations/2", nil, 404, "", "station not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := srv.Do(t, tt.method, tt.path, tt.body).Status(t, tt.status)
+			if tt.want != "" {
+				resp.JSON(t, tt.want)
+			}
+			if tt.error != "" {
+				resp.Error(t, tt.error)
+			}
+		})
+	}
+
+	t.Run("invalid json", func(t *testing.T) {
+		srv.Do(t, "POST", "/v1/stations", `{"name":`, "Content-Type", "application/json").Status(t, 400)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		srv.Do(t, "GET", "/v1/stations", nil, "Accept", "image/png").Status(t, 406).Error(t, "Not Acceptable")
+	})
+}
+
+func TestStationsGeo(t *testing.T) {
+	srv := newServer(t)
+	for _, station := range []map[string]any{
+		{"name": "Ikeja", "latitude": 6.6, "longitude": 3.35},
+		{"name": "Yaba", "latitude": 6.51, "longitude": 3.38},
+		{"name": "Kano", "latitude": 12.0, "longitude": 8.52},
+		{"name": "Nowhere"},
+	} {
+		srv.Do(t, "POST", "/v1/stations", station).Status(t, 201)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string
+	}{
+		{"default radius", "?lat=6.6&lon=3.35", 200, []string{"Ikeja"}},
+		{"nearest first", "?lat=6.5&lon=3.38&radius_km=20", 200, []string{"Yaba", "Ikeja"}},
+		{"nothing around", "?lat=0&lon=0", 200, []string{}},
+		{"radius too large", "?lat=6.6&lon=3.35&radius_km=501", 400, nil},
+		{"not a point", "?lat=6.6", 400, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := srv.Do(t, "GET", "/v1/stations/near"+tt.query, nil).Status(t, tt.status)
+			if tt.want == nil {
+				return
+			}
+			var near struct {
+				Stations []NearStation `json:"stations"`
+			}
+			resp.Decode(t, &near)
+			names := []string{}
+			for _, station := range near.Stations {
+				names = append(names, station.Name)
+			}
+			if len(names) != len(tt.want) {
+				t.Fatalf("stations = %q, want %q", names, tt.want)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Fatalf("stations = %q, want %q", names, tt.want)
+				}
+			}
+		})
+	}
+
+	t.Run("geojson", func(t *testing.T) {
+		var collection geo.FeatureCollection
+		srv.Do(t, "GET", "/v1/stations/geojson", nil).Status(t, 200).Decode(t, &collection)
+		if len(collection.Features) != 4 {
+			t.Fatalf("%d features, want 4", len(collection.Features))
+		}
+		if collection.Features[3].Geometry != nil {
+			t.Errorf("station without a location has geometry %v", collection.Features[3].Geometry)
+		}
+
+		srv.Do(t, "GET", "/v1/stations/near?lat=6.6&lon=3.35&format=geojson", nil).Status(t, 200).Decode(t, &collection)
+		if len(collection.Features) != 1 || collection.Features[0].Properties["name"] != "Ikeja" {
+			t.Errorf("near features = %+v, want Ikeja", collection.Features)
+		}
+	})
+}
+
+// TestStationsGeoDatabaseError checks that a failing database answers 500 without telling the
+// client why; the error goes to the log.
+func TestStationsGeoDatabaseError(t *testing.T) {
+	srv := newServer(t)
+	DB.Close()
+
+	for _, path := range []string{"/v1/stations/near?lat=6.5&lon=3.4", "/v1/stations/geojson"} {
+		srv.Do(t, "GET", path, nil).Status(t, 500).Error(t, "Error getting data from the database")
+	}
+}
+
+func TestStationBulk(t *testing.T) {
+	srv := newServer(t)
+
+	srv.Do(t, "POST", "/v1/stations/import?mode=best-effort",
+		"name,opening_time,closing_time,latitude,longitude\nIkeja,05:00,23:00,6.6,3.35\n,06:00,22:00,,\nKano,06:00,22:00,north,8.5\n",
+		"Content-Type", "text/csv").Status(t, 207).
+		JSON(t, `{"result":{"mode":"best-effort","committed":true,"total":3,"succeeded":1,"failed":2,"rows":[
+			{"row":2,"id":1,"status":"created"},
+			{"row":3,"status":"failed","error":"name is required"},
+			{"row":4,"status":"failed","error":"latitude \"north\" is not a number"}]}}`)
+	srv.Do(t, "POST", "/v1/stations/import", `[{"name":"Yaba"},{"name":""}]`, "Content-Type", "application/json").Status(t, 422)
+	srv.Do(t, "POST", "/v1/stations/import", `name`, "Content-Type", "text/plain").Status(t, 415)
+
+	resp := srv.Do(t, "GET", "/v1/stations/export?format=csv", nil).Status(t, 200)
+	if want := "id,name,opening_time,closing_time,latitude,longitude\n1,Ikeja,05:00,23:00,6.6,3.35\n"; string(resp.Body) != want {
+		t.Errorf("export = %q, want %q", resp.Body, want)
+	}
+	srv.Do(t, "GET", "/v1/stations/export", nil, "Accept", "image/png").Status(t, 406)
+}
+
+func TestNetworkStations(t *testing.T) {
+	db := railtest.NewDB(t)
+	network := db.SeedNetwork()
+	if err := Open(db.Path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { DB.Close() })
+	srv := apitest.NewServer(t, NewRouter())
+
+	lagos := "?lat=" + strconv.FormatFloat(*network.Lagos.Latitude, 'f', -1, 64) + "&lon=" + strconv.FormatFloat(*network.Lagos.Longitude, 'f', -1, 64)
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"stations", "/v1/stations"},
+		{"station", "/v1/stations/" + strconv.Itoa(network.Kajola.ID)},
+		// just the station itself, a distance above zero could differ in its last digits between platforms
+		{"near-lagos", "/v1/stations/near" + lagos + "&radius_km=5"},
+		{"geojson", "/v1/stations/geojson"},
+		{"export-csv", "/v1/stations/export?format=csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := srv.Do(t, "GET", tt.path, nil).Status(t, 200)
+			railtest.Golden(t, tt.name, resp.Body)
+		})
+	}
+}
@@ -1,29 +1,41 @@
 package gorestfulfundamemtals
 
 import (
-	"fmt"
+	"context"
 	"io"
-	"log"
 	"net/http"
 	"time"
 
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/tracing"
 	"github.com/emicklei/go-restful"
+	"github.com/justinas/alice"
 )
 
 func pingTime(req *restful.Request, resp *restful.Response) {
 	io.WriteString(resp, time.Now().String())
 }
 
-func Run() {
+func Run(cfg config.Ping, lc config.Lifecycle) error {
 	webservice := new(restful.WebService)
 
+	webservice.Filter(metrics.RestfulRoute)
 	webservice.Route(webservice.GET("/ping").To(pingTime))
 
 	restful.Add(webservice)
 
-	fmt.Println("Server is running on Port 8000")
+	// restful.Add registers on http.DefaultServeMux, which a nil Handler serves
+	m := lifecycle.New(lc)
 
-	if err := http.ListenAndServe(":8000", nil); err != nil {
-		log.Fatalf("Error : %v", err)
-	}
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
+	checker.RegisterServeMux(http.DefaultServeMux)
+	metrics.RegisterServeMux(http.DefaultServeMux)
+
+	m.AddServer("ping", &http.Server{Addr: cfg.Addr, Handler: alice.New(tracing.Middleware("ping"), logging.Middleware, metrics.Middleware("ping")).Then(http.DefaultServeMux)})
+	return m.Run(context.Background())
 }
@@ -1,15 +1,23 @@
 package gorillarpcserver
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
 
 	"encoding/json"
 
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/tracing"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/rpc"
 	gjson "github.com/gorilla/rpc/json"
+	"github.com/justinas/alice"
 )
 
 type Args struct {
@@ -17,32 +25,35 @@ type Args struct {
 }
 
 type Book struct {
-	Id     string `json:"string,omitempty"`
+	Id     string `json:"id,omitempty"`
 	Name   string `json:"name,omitempty"`
 	Author string `json:"author,omitempty"`
 }
 
-type JSONServer struct{}
+type JSONServer struct {
+	// BooksFile is the JSON file the books are looked up in.
+	BooksFile string
+}
 
 func (t *JSONServer) GiveBookDetail(r *http.Request, args *Args, reply *Book) error {
 	var books []Book
 
-	data, err := os.ReadFile("./book.json")
+	data, err := os.ReadFile(t.BooksFile)
 	if err != nil {
-		log.Println("Error reading file: ", err)
+		slog.ErrorContext(r.Context(), "Error reading file", "err", err)
 		return err
 	}
 
 	marshalerr := json.Unmarshal(data, &books)
 	if marshalerr != nil {
-		log.Println("Error unmarshaling :", err)
+		slog.ErrorContext(r.Context(), "Error unmarshaling", "err", err)
 		return err
 	}
 
 	for _, book := range books {
 		if book.Id == args.Id {
 			*reply = book
-            log.Printf("Found book: %s", book.Name)
+            slog.InfoContext(r.Context(), "Found book", "name", book.Name)
 			break
 		}
 	}
@@ -50,14 +61,34 @@ func (t *JSONServer) GiveBookDetail(r *http.Request, args *Args, reply *Book) er
 	return nil
 }
 
-func Run() {
+// NewHandler returns a router serving the JSON-RPC endpoint on /rpc.
+func NewHandler(cfg config.JSONRPC) *mux.Router {
 	s := rpc.NewServer()
 	s.RegisterCodec(gjson.NewCodec(), "application/json")
 
-	s.RegisterService(new(JSONServer), "")
+	s.RegisterService(&JSONServer{BooksFile: cfg.BooksFile}, "")
+	metrics.InstrumentGorillaRPC("jsonrpc", s)
+
 	r := mux.NewRouter()
+	r.Use(metrics.MuxRoute)
 	r.Handle("/rpc", s)
+	return r
+}
+
+func Run(cfg config.JSONRPC, lc config.Lifecycle) error {
+	m := lifecycle.New(lc)
+
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
+	checker.AddReadinessCheck("books file", func(ctx context.Context) error {
+		_, err := os.Stat(cfg.BooksFile)
+		return err
+	})
+
+	r := NewHandler(cfg)
+	checker.RegisterMux(r)
+	metrics.RegisterMux(r)
 
-    log.Println("JSON-RPC Server listening on :1234")
-	http.ListenAndServe(":1234", r)
+	m.AddServer("jsonrpc", &http.Server{Addr: cfg.Addr, Handler: alice.New(tracing.Middleware("jsonrpc"), logging.Middleware, metrics.Middleware("jsonrpc")).Then(r)})
+	return m.Run(context.Background())
 }
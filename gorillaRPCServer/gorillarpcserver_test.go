@@ -0,0 +1,24 @@
+package gorillarpcserver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/contract"
+)
+
+func TestContracts(t *testing.T) {
+	books := filepath.Join(t.TempDir(), "book.json")
+	if err := os.WriteFile(books, []byte(`[{"id":"1","name":"The Go Programming Language","author":"Alan A. A. Donovan"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	contract.Verify(t, "../contracts", contract.Provider{
+		Name:      "jsonrpc",
+		Handler:   NewHandler(config.JSONRPC{BooksFile: books}),
+		Path:      "/rpc",
+		Receivers: []any{new(JSONServer)},
+	})
+}
@@ -0,0 +1,180 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Check returns nil when the dependency it probes is usable.
+type Check func(ctx context.Context) error
+
+// Checker serves /healthz, /readyz and /version.
+// Liveness only proves the process answers; readiness runs every registered check.
+type Checker struct {
+	mutex   sync.RWMutex
+	checks  map[string]Check
+	timeout time.Duration
+}
+
+func New() *Checker {
+	return &Checker{checks: map[string]Check{}, timeout: 2 * time.Second}
+}
+
+// AddReadinessCheck registers a named check run on every /readyz request.
+func (c *Checker) AddReadinessCheck(name string, check Check) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.checks[name] = check
+}
+
+type status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Liveness answers 200 as long as the process can serve HTTP.
+func (c *Checker) Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, status{Status: "ok"})
+	})
+}
+
+// Readiness runs all checks concurrently and answers 503 when any of them fails.
+func (c *Checker) Readiness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+		defer cancel()
+
+		c.mutex.RLock()
+		names := make([]string, 0, len(c.checks))
+		for name := range c.checks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		checks := make([]Check, len(names))
+		for i, name := range names {
+			checks[i] = c.checks[name]
+		}
+		c.mutex.RUnlock()
+
+		results := make([]error, len(checks))
+		var wg sync.WaitGroup
+		for i, check := range checks {
+			wg.Add(1)
+			go func(i int, check Check) {
+				defer wg.Done()
+				results[i] = check(ctx)
+			}(i, check)
+		}
+		wg.Wait()
+
+		result := status{Status: "ok", Checks: map[string]string{}}
+		code := http.StatusOK
+		for i, name := range names {
+			if results[i] != nil {
+				result.Checks[name] = results[i].Error()
+				result.Status = "unavailable"
+				code = http.StatusServiceUnavailable
+				continue
+			}
+			result.Checks[name] = "ok"
+		}
+
+		writeJSON(w, code, result)
+	})
+}
+
+// BuildInfo is what /version reports, read from the module build information.
+type BuildInfo struct {
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"revision_time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// ReadBuildInfo collects the module version and VCS stamp of the running binary.
+// `go run` and test binaries carry no VCS settings, so Revision is empty there.
+func ReadBuildInfo() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{Version: "unknown"}
+	}
+
+	build := BuildInfo{
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.Time = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
+
+// Version serves the build information as JSON.
+func Version() http.Handler {
+	build := ReadBuildInfo()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, build)
+	})
+}
+
+// DBPing checks that the database answers.
+func DBPing(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// Ready adapts anything reporting readiness, such as a lifecycle.Manager, to a Check.
+func Ready(r interface{ Ready() bool }) Check {
+	return func(ctx context.Context) error {
+		if !r.Ready() {
+			return errors.New("not ready")
+		}
+		return nil
+	}
+}
+
+// Dial checks that something accepts TCP connections on the address returned by addr.
+// addr is a function because listeners usually get their address after the checker is built.
+func Dial(addr func() string) Check {
+	return func(ctx context.Context) error {
+		target := addr()
+		if target == "" {
+			return errors.New("not listening")
+		}
+
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", target)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,52 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+)
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+	VersionPath   = "/version"
+)
+
+func (c *Checker) handlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		LivenessPath:  c.Liveness(),
+		ReadinessPath: c.Readiness(),
+		VersionPath:   Version(),
+	}
+}
+
+// RegisterServeMux mounts the endpoints on a standard library mux.
+func (c *Checker) RegisterServeMux(m *http.ServeMux) {
+	for path, h := range c.handlers() {
+		m.Handle("GET "+path, h)
+	}
+}
+
+// RegisterMux mounts the endpoints on a gorilla/mux router.
+func (c *Checker) RegisterMux(r *mux.Router) {
+	for path, h := range c.handlers() {
+		r.Handle(path, h).Methods(http.MethodGet, http.MethodHead)
+	}
+}
+
+// RegisterGin mounts the endpoints on a Gin router.
+func (c *Checker) RegisterGin(r gin.IRoutes) {
+	for path, h := range c.handlers() {
+		r.GET(path, gin.WrapH(h))
+		r.HEAD(path, gin.WrapH(h))
+	}
+}
+
+// RegisterRestful mounts the endpoints on a go-restful container, next to its web services.
+func (c *Checker) RegisterRestful(container *restful.Container) {
+	for path, h := range c.handlers() {
+		container.Handle(path, h)
+	}
+}
@@ -0,0 +1,255 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/internal/recorder"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/problem"
+	"github.com/emicklei/go-restful"
+)
+
+const (
+	// HeaderKey is sent by clients, a new random value per logical request and the same one on its retries.
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed marks a response replayed from the store.
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+var requests = metrics.NewCounterVec("idempotency_requests_total",
+	"Requests carrying an Idempotency-Key, by service and result (first, replayed, in_progress or mismatch).",
+	"service", "result")
+
+// Guard replays the first response to POST and PATCH requests retried with the same key.
+// A nil *Guard lets everything through, like the nil *ratelimit.Limiter.
+type Guard struct {
+	service string
+	store   Store
+	window  time.Duration
+	maxBody int64
+}
+
+// New returns a guard remembering keys in store for window and reading request bodies up to maxBody bytes.
+func New(service string, store Store, window time.Duration, maxBody int64) *Guard {
+	return &Guard{service: service, store: store, window: window, maxBody: maxBody}
+}
+
+var (
+	policyMutex sync.RWMutex
+	policy      = config.Default().Idempotency
+)
+
+// Setup sets the policy the guards returned by ForService follow.
+func Setup(cfg config.Idempotency) {
+	policyMutex.Lock()
+	defer policyMutex.Unlock()
+	policy = cfg
+}
+
+// ForService returns a guard following the configured policy, or nil when it is disabled.
+func ForService(service string, store Store) *Guard {
+	policyMutex.RLock()
+	cfg := policy
+	policyMutex.RUnlock()
+
+	if !cfg.Enabled {
+		return nil
+	}
+	return New(service, store, time.Duration(cfg.Window), cfg.MaxBody)
+}
+
+// validKey accepts up to 255 visible ASCII characters, enough for a UUID or any random token.
+func validKey(key string) bool {
+	if key == "" || len(key) > 255 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// credentials identifies who sent r by its Authorization and Cookie headers, hashed so the store
+// keeps no session token. Requests without either share the empty credentials.
+func credentials(r *http.Request) string {
+	h := sha256.New()
+	for _, name := range []string{"Authorization", "Cookie"} {
+		for _, value := range r.Header.Values(name) {
+			io.WriteString(h, name+": "+value+"\n")
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// begin claims the key of r. When the request has to be answered without running the handler,
+// because it is a retry or invalid, begin answers it and returns done. Otherwise the request
+// body has been restored and storeKey is the key to complete or release.
+func (g *Guard) begin(w http.ResponseWriter, r *http.Request) (storeKey string, done bool) {
+	key := r.Header.Get(HeaderKey)
+	if !validKey(key) {
+		problem.Write(w, r, http.StatusBadRequest, HeaderKey+" must be 1 to 255 visible ASCII characters")
+		return "", true
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, g.maxBody))
+	if err != nil {
+		problem.Write(w, r, http.StatusRequestEntityTooLarge, "request body too large to be checked against its "+HeaderKey)
+		return "", true
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	sum := sha256.Sum256(body)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	// the same key sent to two endpoints, or by two clients, names two requests: a response is
+	// only replayed to a request with the credentials of the one it answered
+	storeKey = r.Method + " " + r.URL.Path + " " + key + " " + credentials(r)
+
+	record, claimed, err := g.store.Claim(r.Context(), storeKey, fingerprint, time.Now().Add(-g.window))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error claiming idempotency key", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, "could not check the "+HeaderKey)
+		return "", true
+	}
+	if claimed {
+		requests.Inc(g.service, "first")
+		return storeKey, false
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		requests.Inc(g.service, "mismatch")
+		problem.Write(w, r, http.StatusUnprocessableEntity, HeaderKey+" was already used for a request with a different body")
+	case record.Response == nil:
+		requests.Inc(g.service, "in_progress")
+		w.Header().Set("Retry-After", "1")
+		problem.Write(w, r, http.StatusConflict, "the first request with this "+HeaderKey+" is still being processed")
+	default:
+		requests.Inc(g.service, "replayed")
+		header := w.Header()
+		for name, values := range record.Response.Header {
+			header[name] = slices.Clone(values)
+		}
+		header.Set(HeaderReplayed, "true")
+		w.WriteHeader(record.Response.Status)
+		w.Write(record.Response.Body)
+	}
+	return "", true
+}
+
+// finish stores the response rec recorded under key. Server errors release the key instead,
+// since the request may not have had any effect and a retry deserves a new attempt. So do
+// responses setting a cookie, which would leave a session in the store for anyone to replay.
+func (g *Guard) finish(r *http.Request, key string, rec *bodyRecorder) {
+	// the client may be gone, the response still has to be stored for its retry
+	ctx := context.WithoutCancel(r.Context())
+
+	var err error
+	if rec.Status >= 500 || len(rec.Header().Values("Set-Cookie")) > 0 {
+		err = g.store.Release(ctx, key)
+	} else {
+		stored := http.Header{}
+		for name, values := range rec.Header() {
+			if !slices.Equal(rec.before[name], values) {
+				stored[name] = slices.Clone(values)
+			}
+		}
+		err = g.store.Complete(ctx, key, Response{Status: rec.Status, Header: stored, Body: rec.body.Bytes()})
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error storing idempotent response", "err", err)
+	}
+}
+
+// serve runs the handler writing to rec and stores its response. A handler that panics
+// releases the key, its request may or may not have had an effect.
+func (g *Guard) serve(r *http.Request, key string, rec *bodyRecorder, handle func()) {
+	finished := false
+	defer func() {
+		if !finished {
+			g.store.Release(context.WithoutCancel(r.Context()), key)
+		}
+	}()
+
+	handle()
+	g.finish(r, key, rec)
+	finished = true
+}
+
+// applies reports whether r is a request the guard handles: a POST or PATCH carrying a key.
+func applies(r *http.Request) bool {
+	if r.Method != http.MethodPost && r.Method != http.MethodPatch {
+		return false
+	}
+	_, ok := r.Header[http.CanonicalHeaderKey(HeaderKey)]
+	return ok
+}
+
+// Middleware guards the requests reaching next. It fits an alice chain as g.Middleware.
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	if g == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !applies(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, done := g.begin(w, r)
+		if done {
+			return
+		}
+
+		rec := newBodyRecorder(w)
+		g.serve(r, key, rec, func() { next.ServeHTTP(rec, r) })
+	})
+}
+
+// Restful does what Middleware does, as a go-restful filter.
+func (g *Guard) Restful(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	if g == nil || !applies(req.Request) {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+
+	key, done := g.begin(resp, req.Request)
+	if done {
+		return
+	}
+
+	// the handler writes through resp, swap the writer under it for the recording one
+	rec := newBodyRecorder(resp.ResponseWriter)
+	original := resp.ResponseWriter
+	resp.ResponseWriter = rec
+	g.serve(req.Request, key, rec, func() { chain.ProcessFilter(req, resp) })
+	resp.ResponseWriter = original
+}
+
+// bodyRecorder passes a response through while keeping a copy of it.
+type bodyRecorder struct {
+	*recorder.Recorder
+	before http.Header
+	body   bytes.Buffer
+}
+
+func newBodyRecorder(w http.ResponseWriter) *bodyRecorder {
+	return &bodyRecorder{Recorder: recorder.New(w), before: w.Header().Clone()}
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.Recorder.Write(b)
+}
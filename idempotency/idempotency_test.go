@@ -0,0 +1,239 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// forEachStore runs test against an empty memory store and an empty SQLite store, which must
+// answer alike.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		store, err := NewSQLiteStore(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, store)
+	})
+}
+
+func post(handler http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/items", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(HeaderKey, key)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestReplay(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		runs := 0
+		handler := New("test", store, time.Hour, 1<<10).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			runs++
+			w.Header().Set("Location", "/items/1")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":1}`))
+		}))
+
+		first := post(handler, "k1", `{"name":"a"}`)
+		if first.Code != http.StatusCreated || first.Header().Get(HeaderReplayed) != "" {
+			t.Fatalf("first request: status %d, replayed %q", first.Code, first.Header().Get(HeaderReplayed))
+		}
+
+		retry := post(handler, "k1", `{"name":"a"}`)
+		if runs != 1 {
+			t.Errorf("handler ran %d times, want once", runs)
+		}
+		if retry.Code != http.StatusCreated || retry.Body.String() != `{"id":1}` {
+			t.Errorf("retry: status %d body %q, want the first response", retry.Code, retry.Body)
+		}
+		if retry.Header().Get(HeaderReplayed) != "true" || retry.Header().Get("Location") != "/items/1" {
+			t.Errorf("retry headers %v, want the first response's and %s", retry.Header(), HeaderReplayed)
+		}
+
+		if w := post(handler, "k1", `{"name":"b"}`); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("same key, other body: status %d, want 422", w.Code)
+		}
+		if w := post(handler, "k2", `{"name":"a"}`); w.Code != http.StatusCreated || runs != 2 {
+			t.Errorf("new key: status %d after %d runs, want a second run", w.Code, runs)
+		}
+	})
+}
+
+func TestServerErrorReleasesKey(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		status := http.StatusInternalServerError
+		runs := 0
+		handler := New("test", store, time.Hour, 1<<10).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			runs++
+			w.WriteHeader(status)
+		}))
+
+		if w := post(handler, "k", "{}"); w.Code != http.StatusInternalServerError {
+			t.Fatalf("first request: status %d", w.Code)
+		}
+		status = http.StatusCreated
+		if w := post(handler, "k", "{}"); w.Code != http.StatusCreated || runs != 2 {
+			t.Errorf("retry after a 500: status %d after %d runs, want the handler to run again", w.Code, runs)
+		}
+
+		// once the handler succeeds its response is the one replayed
+		if w := post(handler, "k", "{}"); w.Code != http.StatusCreated || runs != 2 {
+			t.Errorf("retry after a 201: status %d after %d runs, want a replay", w.Code, runs)
+		}
+	})
+}
+
+func TestOtherCredentials(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		runs := 0
+		handler := New("test", store, time.Hour, 1<<10).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			runs++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(r.Header.Get("Authorization")))
+		}))
+
+		send := func(header, value string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest("POST", "/items", strings.NewReader("{}"))
+			r.Header.Set(HeaderKey, "k")
+			if header != "" {
+				r.Header.Set(header, value)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			return w
+		}
+
+		send("Authorization", "Bearer ann")
+		if w := send("Authorization", "Bearer ann"); runs != 1 || w.Body.String() != "Bearer ann" {
+			t.Errorf("retry with the same token: body %q after %d runs, want a replay", w.Body, runs)
+		}
+		// another client guessing or reusing the key gets its own response, not ann's
+		for _, other := range [][2]string{{"Authorization", "Bearer bob"}, {"Cookie", "session=bob"}, {"", ""}} {
+			before := runs
+			if w := send(other[0], other[1]); runs != before+1 || w.Header().Get(HeaderReplayed) != "" {
+				t.Errorf("%s %q: replayed %q, want the handler to run", other[0], other[1], w.Body)
+			}
+		}
+	})
+}
+
+func TestSetCookieNotStored(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		runs := 0
+		handler := New("test", store, time.Hour, 1<<10).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			runs++
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+			w.Write([]byte(`{"token":"secret"}`))
+		}))
+
+		post(handler, "k", "{}")
+		if w := post(handler, "k", "{}"); runs != 2 || w.Header().Get(HeaderReplayed) != "" {
+			t.Errorf("retry of a response setting a cookie: %d runs, replayed %q, want the handler to run again", runs, w.Header().Get(HeaderReplayed))
+		}
+	})
+}
+
+func TestInProgress(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		handler := New("test", store, time.Hour, 1<<10).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("handler ran for a key still in progress")
+		}))
+
+		// the key of a request that has not finished yet
+		sum := sha256.Sum256([]byte("{}"))
+		fingerprint := hex.EncodeToString(sum[:])
+		storeKey := "POST /items k " + credentials(httptest.NewRequest("POST", "/items", nil))
+		if _, claimed, err := store.Claim(t.Context(), storeKey, fingerprint, time.Time{}); err != nil || !claimed {
+			t.Fatalf("Claim = %v, %v", claimed, err)
+		}
+
+		w := post(handler, "k", "{}")
+		if w.Code != http.StatusConflict {
+			t.Errorf("status %d, want 409", w.Code)
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Error("409 without Retry-After")
+		}
+	})
+}
+
+func TestWindow(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if _, claimed, err := store.Claim(t.Context(), "k", "a", time.Now().Add(-time.Hour)); err != nil || !claimed {
+			t.Fatalf("first Claim = %v, %v", claimed, err)
+		}
+		if err := store.Complete(t.Context(), "k", Response{Status: http.StatusOK}); err != nil {
+			t.Fatal(err)
+		}
+
+		record, claimed, err := store.Claim(t.Context(), "k", "b", time.Now().Add(-time.Hour))
+		if err != nil || claimed || record.Fingerprint != "a" || record.Response == nil {
+			t.Errorf("Claim within the window = %+v, %v, %v, want the first record", record, claimed, err)
+		}
+
+		// a key claimed before the window is forgotten
+		if _, claimed, err := store.Claim(t.Context(), "k", "b", time.Now().Add(time.Second)); err != nil || !claimed {
+			t.Errorf("Claim after the window = %v, %v, want claimed", claimed, err)
+		}
+	})
+}
+
+func TestInvalidKey(t *testing.T) {
+	handler := New("test", NewMemoryStore(), time.Hour, 4).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, key := range []string{"", "has space", strings.Repeat("k", 256)} {
+		r := httptest.NewRequest("POST", "/items", strings.NewReader("{}"))
+		r.Header.Set(HeaderKey, key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("key %q: status %d, want 400", key, w.Code)
+		}
+	}
+
+	if w := post(handler, "k", "too long"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("body over the limit: status %d, want 413", w.Code)
+	}
+
+	// requests without a key, and methods that are idempotent already, pass through
+	if w := post(handler, "", "{}"); w.Code != http.StatusOK {
+		t.Errorf("no key: status %d", w.Code)
+	}
+	r := httptest.NewRequest("PUT", "/items/1", strings.NewReader("too long"))
+	r.Header.Set(HeaderKey, "has space")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("PUT: status %d", w.Code)
+	}
+}
+
+func TestNilGuard(t *testing.T) {
+	var g *Guard
+	called := false
+	g.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+	if !called {
+		t.Error("a nil guard must let requests through")
+	}
+}
@@ -0,0 +1,89 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const schema = `CREATE TABLE IF NOT EXISTS idempotency_key (
+	KEY TEXT PRIMARY KEY,
+	FINGERPRINT TEXT NOT NULL,
+	STATUS INTEGER,
+	HEADER TEXT,
+	BODY BLOB,
+	CLAIMED_AT INTEGER NOT NULL
+)`
+
+// SQLiteStore keeps keys in a table of the service's own database, so a retry is still
+// recognized after a restart, like the rows the first request created.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore creates the idempotency_key table in db if it does not exist yet.
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("creating idempotency_key table: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Claim(ctx context.Context, key, fingerprint string, notBefore time.Time) (*Record, bool, error) {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_key WHERE CLAIMED_AT < ?", notBefore.UnixNano()); err != nil {
+		return nil, false, err
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO idempotency_key (KEY, FINGERPRINT, CLAIMED_AT) VALUES (?, ?, ?) ON CONFLICT (KEY) DO NOTHING",
+		key, fingerprint, time.Now().UnixNano())
+	if err != nil {
+		return nil, false, err
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return nil, false, err
+	} else if inserted == 1 {
+		return nil, true, nil
+	}
+
+	var record Record
+	var status sql.NullInt64
+	var header sql.NullString
+	var body []byte
+	err = s.db.QueryRowContext(ctx, "SELECT FINGERPRINT, STATUS, HEADER, BODY FROM idempotency_key WHERE KEY = ?", key).
+		Scan(&record.Fingerprint, &status, &header, &body)
+	if err == sql.ErrNoRows {
+		// released between the insert and the select, let the caller try again
+		return nil, false, fmt.Errorf("idempotency key %q was released concurrently", key)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if status.Valid {
+		response := &Response{Status: int(status.Int64), Header: http.Header{}, Body: body}
+		if err := json.Unmarshal([]byte(header.String), &response.Header); err != nil {
+			return nil, false, fmt.Errorf("decoding stored headers: %w", err)
+		}
+		record.Response = response
+	}
+	return &record, false, nil
+}
+
+func (s *SQLiteStore) Complete(ctx context.Context, key string, response Response) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "UPDATE idempotency_key SET STATUS = ?, HEADER = ?, BODY = ? WHERE KEY = ?",
+		response.Status, string(header), response.Body, key)
+	return err
+}
+
+func (s *SQLiteStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_key WHERE KEY = ?", key)
+	return err
+}
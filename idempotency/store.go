@@ -0,0 +1,93 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is what the first request under a key was answered with.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is what a store knows about a key.
+type Record struct {
+	Fingerprint string
+	// Response is nil while the first request is still being served.
+	Response *Response
+}
+
+// Store remembers keys and the responses of their first request.
+type Store interface {
+	// Claim takes key for a request whose body has fingerprint, forgetting keys claimed before notBefore.
+	// When the key is already taken it returns the record of the first request and claimed is false.
+	Claim(ctx context.Context, key, fingerprint string, notBefore time.Time) (record *Record, claimed bool, err error)
+	// Complete stores the response to the request that claimed key.
+	Complete(ctx context.Context, key string, response Response) error
+	// Release forgets a key whose request failed, so that a retry runs it again.
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore keeps keys in a map, for services whose data does not outlive the process either.
+type MemoryStore struct {
+	mutex   sync.Mutex
+	records map[string]*memoryRecord
+	swept   time.Time
+}
+
+type memoryRecord struct {
+	Record
+	claimed time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*memoryRecord)}
+}
+
+// sweepEvery limits how often Claim walks the whole map for expired keys.
+const sweepEvery = time.Minute
+
+func (s *MemoryStore) Claim(ctx context.Context, key, fingerprint string, notBefore time.Time) (*Record, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.swept) > sweepEvery {
+		for k, r := range s.records {
+			if r.claimed.Before(notBefore) {
+				delete(s.records, k)
+			}
+		}
+		s.swept = now
+	}
+
+	if r, ok := s.records[key]; ok && !r.claimed.Before(notBefore) {
+		record := r.Record
+		return &record, false, nil
+	}
+
+	s.records[key] = &memoryRecord{Record: Record{Fingerprint: fingerprint}, claimed: now}
+	return nil, true, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, response Response) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if r, ok := s.records[key]; ok {
+		r.Response = &response
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package recorder wraps the http.ResponseWriter handed to a handler, so the middleware around
+// it can see what was written.
+package recorder
+
+import "net/http"
+
+// Recorder passes a response through while remembering its status code and body size.
+// Middleware that needs more, such as a copy of the body, embeds it and overrides Write.
+type Recorder struct {
+	http.ResponseWriter
+	// Status is the first status code written, http.StatusOK when the handler only wrote a
+	// body or nothing at all.
+	Status int
+	// Size counts the body bytes written.
+	Size        int
+	wroteHeader bool
+}
+
+func New(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.Status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.Size += n
+	return n, err
+}
+
+func (r *Recorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, such as the hijacker of the
+// net/rpc CONNECT handshake.
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,55 @@
+package recorder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter)
+		status  int
+		size    int
+	}{
+		{"nothing written", func(w http.ResponseWriter) {}, http.StatusOK, 0},
+		{"body only", func(w http.ResponseWriter) { w.Write([]byte("hello")) }, http.StatusOK, 5},
+		{"status and body", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("hi"))
+			w.Write([]byte("!"))
+		}, http.StatusCreated, 3},
+		{"first status wins", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusNotFound, 0},
+		{"status after body is ignored", func(w http.ResponseWriter) {
+			w.Write([]byte("x"))
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := New(httptest.NewRecorder())
+			tt.handler(rec)
+			if rec.Status != tt.status || rec.Size != tt.size {
+				t.Errorf("status %d size %d, want %d and %d", rec.Status, rec.Size, tt.status, tt.size)
+			}
+		})
+	}
+}
+
+func TestRecorderUnwrap(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := New(w)
+	if err := http.NewResponseController(rec).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Flushed {
+		t.Error("Flush did not reach the underlying writer")
+	}
+	if rec.Unwrap() != w {
+		t.Error("Unwrap does not return the underlying writer")
+	}
+}
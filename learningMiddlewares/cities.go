@@ -0,0 +1,299 @@
+package learningmiddlewares
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Dav16Akin/go-dictionary/geo"
+)
+
+const (
+	defaultCityLimit = 20
+	maxCityLimit     = 100
+	// boundaryCellSize is the grid cell of the boundary index in degrees, a little larger than most cities
+	boundaryCellSize = 0.5
+	// maxBoundarySpan bounds the box around a boundary in degrees, so that indexing one touches
+	// at most a few hundred cells; the largest cities span about two.
+	maxBoundarySpan = 5.0
+)
+
+// cityStore keeps the cities in memory, like the users API keeps its users.
+// boundaries indexes the cities that have a boundary, to find the one containing a point.
+type cityStore struct {
+	mutex      sync.Mutex
+	cities     map[int]City
+	boundaries *geo.Index
+	nextID     int
+}
+
+func newCityStore() *cityStore {
+	return &cityStore{cities: make(map[int]City), boundaries: geo.NewIndex(boundaryCellSize), nextID: 1}
+}
+
+// cityPatch is a JSON merge patch (RFC 7396) of a City: fields left out stay as they are and
+// fields set to null are cleared.
+type cityPatch struct {
+	Name      patchField[string]      `json:"name"`
+	Area      patchField[uint64]      `json:"area"`
+	Latitude  patchField[float64]     `json:"latitude"`
+	Longitude patchField[float64]     `json:"longitude"`
+	Boundary  patchField[geo.Polygon] `json:"boundary"`
+}
+
+// patchField is one member of a merge patch. Set tells a member left out from one set to null,
+// which a plain pointer can't, Value is nil for null.
+type patchField[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (f *patchField[T]) UnmarshalJSON(b []byte) error {
+	f.Set = true
+	if string(b) == "null" {
+		f.Value = nil
+		return nil
+	}
+	f.Value = new(T)
+	return json.Unmarshal(b, f.Value)
+}
+
+// apply sets *dst to the patched value, the zero value when the member was null.
+func (f patchField[T]) apply(dst *T) {
+	if !f.Set {
+		return
+	}
+	var zero T
+	*dst = zero
+	if f.Value != nil {
+		*dst = *f.Value
+	}
+}
+
+// applyPtr is apply for optional fields, which null sets to nil.
+func (f patchField[T]) applyPtr(dst **T) {
+	if f.Set {
+		*dst = f.Value
+	}
+}
+
+// cityQuery filters, sorts and pages the city list.
+type cityQuery struct {
+	NamePrefix string
+	MinArea    uint64
+	// MaxArea is nil without an upper bound, so max_area=0 still means an area of at most 0.
+	MaxArea    *uint64
+	Sort       string
+	Descending bool
+	Limit      int
+	Offset     int
+}
+
+// cityPage is one page of the city list, with the number of cities that matched.
+type cityPage struct {
+	Cities []City `json:"cities"`
+	Total  int    `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// cityStats sums up the store.
+type cityStats struct {
+	Count       int     `json:"count"`
+	TotalArea   uint64  `json:"total_area"`
+	AverageArea float64 `json:"average_area"`
+}
+
+// parseCityQuery reads name, min_area, max_area, sort, limit and offset. sort is id, name or
+// area, with a leading "-" for descending order.
+func parseCityQuery(values url.Values) (cityQuery, error) {
+	q := cityQuery{NamePrefix: values.Get("name"), Sort: "id", Limit: defaultCityLimit}
+
+	var err error
+	if v := values.Get("min_area"); v != "" {
+		if q.MinArea, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return q, fmt.Errorf("min_area must be a non-negative integer, got %q", v)
+		}
+	}
+	if v := values.Get("max_area"); v != "" {
+		maxArea, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("max_area must be a non-negative integer, got %q", v)
+		}
+		if maxArea < q.MinArea {
+			return q, fmt.Errorf("max_area %d is below min_area %d", maxArea, q.MinArea)
+		}
+		q.MaxArea = &maxArea
+	}
+
+	if v := values.Get("sort"); v != "" {
+		q.Sort, q.Descending = strings.CutPrefix(v, "-")
+		if q.Sort != "id" && q.Sort != "name" && q.Sort != "area" {
+			return q, fmt.Errorf("sort must be id, name or area, optionally prefixed with -, got %q", v)
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > maxCityLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d, got %q", maxCityLimit, v)
+		}
+	}
+	if v := values.Get("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+			return q, fmt.Errorf("offset must be a non-negative integer, got %q", v)
+		}
+	}
+	return q, nil
+}
+
+func (q cityQuery) matches(city City) bool {
+	if q.NamePrefix != "" && !strings.HasPrefix(strings.ToLower(city.Name), strings.ToLower(q.NamePrefix)) {
+		return false
+	}
+	if city.Area < q.MinArea {
+		return false
+	}
+	return q.MaxArea == nil || city.Area <= *q.MaxArea
+}
+
+func (q cityQuery) compare(a, b City) int {
+	var c int
+	switch q.Sort {
+	case "name":
+		c = cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	case "area":
+		c = cmp.Compare(a.Area, b.Area)
+	}
+	// ties keep ID order, so pages don't shuffle between requests
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+	if q.Descending {
+		return -c
+	}
+	return c
+}
+
+func (s *cityStore) list(q cityQuery) cityPage {
+	s.mutex.Lock()
+	matched := make([]City, 0, len(s.cities))
+	for _, city := range s.cities {
+		if q.matches(city) {
+			matched = append(matched, city)
+		}
+	}
+	s.mutex.Unlock()
+
+	slices.SortFunc(matched, q.compare)
+
+	page := cityPage{Cities: []City{}, Total: len(matched), Limit: q.Limit, Offset: q.Offset}
+	if q.Offset < len(matched) {
+		page.Cities = matched[q.Offset:min(q.Offset+q.Limit, len(matched))]
+	}
+	return page
+}
+
+func (s *cityStore) get(id int) (City, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	city, ok := s.cities[id]
+	return city, ok
+}
+
+func (s *cityStore) create(city City) City {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	city.ID = s.nextID
+	s.nextID++
+	s.store(city)
+	return city
+}
+
+// store saves city and keeps the boundary index in step, the caller holds the mutex.
+func (s *cityStore) store(city City) {
+	s.cities[city.ID] = city
+	if city.Boundary != nil {
+		s.boundaries.Insert(city.ID, city.Boundary.Bounds())
+	} else {
+		s.boundaries.Remove(city.ID)
+	}
+}
+
+// replace stores city under id, it reports false when there is no such city.
+func (s *cityStore) replace(id int, city City) (City, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.cities[id]; !ok {
+		return City{}, false
+	}
+	city.ID = id
+	s.store(city)
+	return city, true
+}
+
+// patch applies p to the city under id, check validates the result before it is stored.
+func (s *cityStore) patch(id int, p cityPatch, check func(City) error) (City, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	city, ok := s.cities[id]
+	if !ok {
+		return City{}, false, nil
+	}
+	p.Name.apply(&city.Name)
+	p.Area.apply(&city.Area)
+	p.Latitude.applyPtr(&city.Latitude)
+	p.Longitude.applyPtr(&city.Longitude)
+	p.Boundary.apply(&city.Boundary)
+	if err := check(city); err != nil {
+		return City{}, true, err
+	}
+	s.store(city)
+	return city, true, nil
+}
+
+func (s *cityStore) remove(id int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.cities[id]; !ok {
+		return false
+	}
+	delete(s.cities, id)
+	s.boundaries.Remove(id)
+	return true
+}
+
+// containing returns the city whose boundary contains p, the oldest one if boundaries overlap.
+func (s *cityStore) containing(p geo.Point) (City, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, id := range s.boundaries.Search(p) {
+		if city := s.cities[id]; city.Boundary.Contains(p) {
+			return city, true
+		}
+	}
+	return City{}, false
+}
+
+func (s *cityStore) stats() cityStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats := cityStats{Count: len(s.cities)}
+	for _, city := range s.cities {
+		stats.TotalArea += city.Area
+	}
+	if stats.Count > 0 {
+		stats.AverageArea = float64(stats.TotalArea) / float64(stats.Count)
+	}
+	return stats
+}
+
+// largest returns the n cities with the largest area, largest first.
+func (s *cityStore) largest(n int) []City {
+	page := s.list(cityQuery{Sort: "area", Descending: true, Limit: n})
+	return page.Cities
+}
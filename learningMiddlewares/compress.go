@@ -0,0 +1,156 @@
+package learningmiddlewares
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+var (
+	gzipWriters   = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	brotliWriters = sync.Pool{New: func() any { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) }}
+)
+
+// acceptEncoding picks br or gzip out of an Accept-Encoding header, "" when neither is acceptable.
+// A coding the header does not name takes the q of "*", and q=0 rules a coding out (RFC 9110
+// section 12.5.3). Brotli wins a tie, it compresses JSON noticeably better.
+func acceptEncoding(header string) string {
+	qs := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "br" && name != "gzip" && name != "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qs[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range []string{"br", "gzip"} {
+		q, ok := qs[name]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// CompressMiddleware encodes responses with brotli or gzip when the client accepts it.
+// Responses that are already encoded, or have no body, are left alone.
+func CompressMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := acceptEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter decides whether to compress when the status is written, and compresses the body if so.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	wroteHeader bool
+	writer      io.WriteCloser
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.wroteHeader || code < 200 {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	c.wroteHeader = true
+
+	header := c.Header()
+	if code != http.StatusNoContent && code != http.StatusNotModified && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", c.encoding)
+		// the length of the encoded body is not known up front
+		header.Del("Content-Length")
+
+		switch c.encoding {
+		case "br":
+			writer := brotliWriters.Get().(*brotli.Writer)
+			writer.Reset(c.ResponseWriter)
+			c.writer = writer
+		case "gzip":
+			writer := gzipWriters.Get().(*gzip.Writer)
+			writer.Reset(c.ResponseWriter)
+			c.writer = writer
+		}
+	}
+
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		// net/http would sniff the compressed bytes, sniff the plain ones instead
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.writer == nil {
+		return c.ResponseWriter.Write(b)
+	}
+	return c.writer.Write(b)
+}
+
+// Flush pushes what has been compressed so far to the client, for streamed responses such as exports.
+func (c *compressWriter) Flush() {
+	switch writer := c.writer.(type) {
+	case *gzip.Writer:
+		writer.Flush()
+	case *brotli.Writer:
+		writer.Flush()
+	}
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the compressed stream and returns the encoder to its pool.
+func (c *compressWriter) Close() error {
+	if c.writer == nil {
+		return nil
+	}
+	err := c.writer.Close()
+
+	switch writer := c.writer.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(writer)
+	case *brotli.Writer:
+		brotliWriters.Put(writer)
+	}
+	c.writer = nil
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,34 @@
+package learningmiddlewares
+
+import "testing"
+
+func TestAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"br", "br"},
+		{"gzip, br", "br"},
+		{"gzip, br;q=0.5", "gzip"},
+		{"GZIP", "gzip"},
+		{"identity", ""},
+		{"identity, gzip;q=0.1", "gzip"},
+		{"gzip;q=0", ""},
+		{"br;q=0", ""},
+		{"gzip;q=0, br;q=0", ""},
+		{"br;q=0, gzip", "gzip"},
+		{"*", "br"},
+		{"*;q=0", ""},
+		{"gzip, *;q=0", "gzip"},
+		{"*, br;q=0", "gzip"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"gzip;q=abc", ""},
+	}
+	for _, tt := range tests {
+		if got := acceptEncoding(tt.header); got != tt.want {
+			t.Errorf("acceptEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
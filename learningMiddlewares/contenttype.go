@@ -0,0 +1,120 @@
+package learningmiddlewares
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Dav16Akin/go-dictionary/problem"
+)
+
+// ContentTypes maps a method to the media types its request body may have. Entries are
+// "type/subtype", "type/*", or a structured syntax suffix such as "+json", which accepts
+// application/merge-patch+json, application/vnd.api+json and the like.
+// Methods that are not listed are not checked.
+type ContentTypes map[string][]string
+
+// JSONBodies accepts JSON in every method that carries a body.
+var JSONBodies = ContentTypes{
+	http.MethodPost:  {"application/json", "+json"},
+	http.MethodPut:   {"application/json", "+json"},
+	http.MethodPatch: {"application/json", "+json"},
+}
+
+// accepts reports whether mediaType, already lower-cased and without parameters, matches one of allowed.
+func accepts(allowed []string, mediaType string) bool {
+	for _, a := range allowed {
+		switch {
+		case strings.HasPrefix(a, "+"):
+			if strings.HasSuffix(mediaType, a) {
+				return true
+			}
+		case strings.HasSuffix(a, "/*"):
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*")) {
+				return true
+			}
+		case a == mediaType:
+			return true
+		}
+	}
+	return false
+}
+
+// hint renders allowed for Accept-Post and Accept-Patch, which only take concrete media ranges.
+func hint(allowed []string) string {
+	var ranges []string
+	for _, a := range allowed {
+		if !strings.HasPrefix(a, "+") {
+			ranges = append(ranges, a)
+		}
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// hasBody reports whether r carries a body, known from its Content-Length or chunked encoding.
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || (r.ContentLength == -1 && r.Body != nil && r.Body != http.NoBody)
+}
+
+// RequireContentTypes refuses request bodies whose Content-Type is not allowed for the method
+// with 415 Unsupported Media Type. Parameters are parsed rather than compared, so
+// "application/json; charset=utf-8" is JSON; a charset other than UTF-8 is refused for JSON types.
+// Requests without a body, such as most GETs, pass. Every response advertises the accepted
+// types in Accept-Post and Accept-Patch.
+func RequireContentTypes(rules ContentTypes) func(http.Handler) http.Handler {
+	allowed := make(ContentTypes, len(rules))
+	for method, types := range rules {
+		for _, t := range types {
+			allowed[method] = append(allowed[method], strings.ToLower(t))
+		}
+	}
+	acceptPost := hint(allowed[http.MethodPost])
+	acceptPatch := hint(allowed[http.MethodPatch])
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if acceptPost != "" {
+				w.Header().Set("Accept-Post", acceptPost)
+			}
+			if acceptPatch != "" {
+				w.Header().Set("Accept-Patch", acceptPatch)
+			}
+
+			types, checked := allowed[r.Method]
+			if !checked || !hasBody(r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			contentType := r.Header.Get("Content-Type")
+			if contentType == "" {
+				problem.Write(w, r, http.StatusUnsupportedMediaType, "Content-Type is required, one of: "+strings.Join(types, ", "))
+				return
+			}
+
+			mediaType, params, err := mime.ParseMediaType(contentType)
+			if err != nil {
+				problem.Write(w, r, http.StatusUnsupportedMediaType, "Content-Type "+contentType+" cannot be parsed")
+				return
+			}
+			if !accepts(types, mediaType) {
+				problem.Write(w, r, http.StatusUnsupportedMediaType, "Content-Type "+mediaType+" is not one of: "+strings.Join(types, ", "))
+				return
+			}
+
+			// JSON is UTF-8 (RFC 8259), a charset parameter may only confirm it
+			isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+			if charset, ok := params["charset"]; ok && isJSON && !strings.EqualFold(charset, "utf-8") && !strings.EqualFold(charset, "utf8") {
+				problem.Write(w, r, http.StatusUnsupportedMediaType, "JSON must be encoded as UTF-8, not "+charset)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ContentTypeMiddleware requires JSON bodies, with RequireContentTypes(JSONBodies).
+func ContentTypeMiddleware(h http.Handler) http.Handler {
+	return RequireContentTypes(JSONBodies)(h)
+}
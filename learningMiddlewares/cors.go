@@ -0,0 +1,83 @@
+package learningmiddlewares
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+)
+
+// CORSMiddleware lets browsers call the API from the configured origins. Preflight requests
+// are answered here; other requests get the CORS headers and go on. Requests from origins
+// that are not allowed go on without them, so the browser refuses to hand over the response.
+func CORSMiddleware(cfg config.CORS) func(http.Handler) http.Handler {
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(time.Duration(cfg.MaxAge).Seconds()))
+
+	allowed := func(origin string) bool {
+		if anyOrigin {
+			return true
+		}
+		for _, o := range cfg.AllowedOrigins {
+			if strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(h http.Handler) http.Handler {
+		if len(cfg.AllowedOrigins) == 0 {
+			return h
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			// the answer depends on the origin, shared caches must not hand it to another one
+			header.Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			if origin == "" || !allowed(origin) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			// "*" cannot be used with credentials, echoing the origin works in both cases
+			if anyOrigin && !cfg.AllowCredentials {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			requested := r.Header.Get("Access-Control-Request-Method")
+			if r.Method != http.MethodOptions || requested == "" {
+				if exposed != "" {
+					header.Set("Access-Control-Expose-Headers", exposed)
+				}
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			// preflight
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			if slices.Contains(cfg.AllowedMethods, requested) {
+				header.Set("Access-Control-Allow-Methods", methods)
+				if headers != "" {
+					header.Set("Access-Control-Allow-Headers", headers)
+				}
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package learningmiddlewares
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// FuzzCreateCity posts arbitrary bodies to mainLogic. Malformed ones must be refused with a 4xx,
+// and an accepted city must read back exactly as it was created.
+func FuzzCreateCity(f *testing.F) {
+	for _, seed := range []string{
+		`{"name":"Lagos","area":1171}`,
+		`{"name":"Abuja","area":1769,"latitude":9.07,"longitude":7.49}`,
+		`{"name":"Lagos","boundary":{"type":"Polygon","coordinates":[[[3,6.3],[3.6,6.3],[3.6,6.8],[3,6.8],[3,6.3]]]}}`,
+		`{"name":"Open","boundary":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1]]]}}`,
+		`{"name":"Half","latitude":1}`,
+		`{"name":"Far","latitude":91,"longitude":0}`,
+		`{"name":"Negative","area":-1}`,
+		`{"name":"Huge","area":18446744073709551615}`,
+		`{"name":""}`,
+		`{"name":"é🏙` + "\x00\xff" + `"}`,
+		`{"name":"Lagos"} trailing`,
+		`[]`,
+		`null`,
+		``,
+	} {
+		f.Add([]byte(seed))
+	}
+	cities = newCityStore()
+	f.Cleanup(func() { cities = newCityStore() })
+	handler := NewHandler()
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest("POST", "/city", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := serve(handler, req)
+
+		switch {
+		case rec.Code >= 500:
+			t.Fatalf("POST %q = %d: %s", body, rec.Code, rec.Body)
+		case rec.Code != http.StatusCreated:
+			return
+		}
+
+		location := rec.Header().Get("Location")
+		got := serve(handler, httptest.NewRequest("GET", location, nil))
+		if got.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d: %s", location, got.Code, got.Body)
+		}
+		if !bytes.Equal(got.Body.Bytes(), rec.Body.Bytes()) {
+			t.Fatalf("city read back as %s, created as %s", got.Body, rec.Body)
+		}
+	})
+}
+
+// FuzzCityRequests sends arbitrary city IDs and list queries, which must never fail the server.
+func FuzzCityRequests(f *testing.F) {
+	for _, seed := range []struct{ id, query string }{
+		{"1", ""},
+		{"0", "sort=-area&limit=1"},
+		{"-1", "name=L&min_area=10&max_area=5"},
+		{"01", "offset=-1"},
+		{"abc", "limit=101"},
+		{"9223372036854775808", "min_area=1e3"},
+		{"1.5", "sort=size"},
+		{"", "n=0"},
+		{"%", "%zz"},
+	} {
+		f.Add(seed.id, seed.query)
+	}
+	cities = newCityStore()
+	f.Cleanup(func() { cities = newCityStore() })
+	cities.create(City{Name: "Lagos", Area: 1171})
+	handler := NewHandler()
+
+	f.Fuzz(func(t *testing.T, id, query string) {
+		for _, target := range []string{
+			"/city?" + query,
+			"/city/" + url.PathEscape(id),
+			"/city/largest?" + query,
+			"/city/locate?" + query,
+		} {
+			u, err := url.Parse(target)
+			if err != nil {
+				// control characters never make it through a request line
+				continue
+			}
+			req := httptest.NewRequest("GET", "/", nil)
+			req.URL = u
+			if rec := serve(handler, req); rec.Code >= 500 {
+				t.Fatalf("GET %s = %d: %s", target, rec.Code, rec.Body)
+			}
+		}
+		if _, err := strconv.Atoi(id); err != nil {
+			return
+		}
+		req := httptest.NewRequest("PATCH", "/city/"+id, bytes.NewReader([]byte(`{"area":1}`)))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		if rec := serve(handler, req); rec.Code >= 500 {
+			t.Fatalf("PATCH /city/%s = %d: %s", id, rec.Code, rec.Body)
+		}
+	})
+}
@@ -1,44 +1,48 @@
 package learningmiddlewares
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"math"
 	"net/http"
-	"os"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
-	"github.com/gorilla/handlers"
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/geo"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/idempotency"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/problem"
+	"github.com/Dav16Akin/go-dictionary/ratelimit"
+	"github.com/Dav16Akin/go-dictionary/tracing"
 	"github.com/justinas/alice"
 )
 
 type City struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
-	Area uint64 `json:"area"`
+	ID        int         `json:"id"`
+	Name      string      `json:"name"`
+	Area      uint64      `json:"area"`
+	Latitude  *float64    `json:"latitude,omitempty"`
+	Longitude *float64    `json:"longitude,omitempty"`
+	Boundary  geo.Polygon `json:"boundary,omitempty"`
 }
 
-var (
-	cities = make(map[int]City)
-	index  = 1
-	mutex  = &sync.Mutex{}
-)
-
-func ContentTypeMiddleware(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		contentType := r.Header.Get("Content-Type")
-
-		if contentType == "" || contentType != "application/json" {
-			http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
-			return
-		}
-
-		h.ServeHTTP(w, r)
-	})
+// Location returns where the city is, false when it has no coordinates.
+func (c City) Location() (geo.Point, bool) {
+	if c.Latitude == nil || c.Longitude == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: *c.Latitude, Lon: *c.Longitude}, true
 }
 
+var cities = newCityStore()
+
 func ServerTimeMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -51,50 +55,238 @@ func ServerTimeMiddleware(h http.Handler) http.Handler {
 	})
 }
 
-func mainLogic(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-type", "application/json")
+// validateCity checks a city before it is stored.
+func validateCity(city City) error {
+	if strings.TrimSpace(city.Name) == "" {
+		return errors.New("name is required")
+	}
+	if (city.Latitude == nil) != (city.Longitude == nil) {
+		return errors.New("latitude and longitude go together")
+	}
+	if location, ok := city.Location(); ok {
+		if err := location.Validate(); err != nil {
+			return err
+		}
+	}
+	if city.Boundary != nil {
+		if err := city.Boundary.Validate(); err != nil {
+			return fmt.Errorf("boundary: %w", err)
+		}
+		if b := city.Boundary.Bounds(); b.MaxLat-b.MinLat > maxBoundarySpan || b.MaxLon-b.MinLon > maxBoundarySpan {
+			return fmt.Errorf("boundary: spans more than %g degrees of latitude or longitude", maxBoundarySpan)
+		}
+	}
+	return nil
+}
 
+// writeJSON writes v as the JSON response body with status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// decodeCity reads a city from the request body, answering the request itself when it can't.
+func decodeCity(w http.ResponseWriter, r *http.Request, city *City) bool {
+	if err := json.NewDecoder(r.Body).Decode(city); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid Json: "+err.Error())
+		return false
+	}
+	if err := validateCity(*city); err != nil {
+		problem.Write(w, r, http.StatusUnprocessableEntity, err.Error())
+		return false
+	}
+	return true
+}
+
+// mainLogic lists the cities and creates new ones.
+func mainLogic(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
-		json.NewEncoder(w).Encode(cities)
+		query, err := parseCityQuery(r.URL.Query())
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, cities.list(query))
 
 	case "POST":
-		var citiesData City
+		var city City
+		if !decodeCity(w, r, &city) {
+			return
+		}
+
+		city = cities.create(city)
+		w.Header().Set("Location", "/city/"+strconv.Itoa(city.ID))
+		writeJSON(w, http.StatusCreated, city)
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, "Method not Allowed")
+	}
+}
+
+// cityLogic reads, replaces, patches and deletes a single city.
+func cityLogic(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid City ID")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		city, ok := cities.get(id)
+		if !ok {
+			problem.Write(w, r, http.StatusNotFound, "City Not Found")
+			return
+		}
+		writeJSON(w, http.StatusOK, city)
 
-		if err := json.NewDecoder(r.Body).Decode(&citiesData); err != nil {
-			http.Error(w, "Invalid Json", http.StatusBadRequest)
+	case "PUT":
+		var city City
+		if !decodeCity(w, r, &city) {
 			return
 		}
 
-		mutex.Lock()
+		city, ok := cities.replace(id, city)
+		if !ok {
+			problem.Write(w, r, http.StatusNotFound, "City Not Found")
+			return
+		}
+		writeJSON(w, http.StatusOK, city)
 
-		citiesData.ID = index
-		cities[index] = citiesData
-		index++
+	case "PATCH":
+		var patch cityPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "Invalid Json: "+err.Error())
+			return
+		}
 
-		mutex.Unlock()
+		city, ok, err := cities.patch(id, patch, validateCity)
+		if !ok {
+			problem.Write(w, r, http.StatusNotFound, "City Not Found")
+			return
+		}
+		if err != nil {
+			problem.Write(w, r, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, city)
 
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(citiesData)
+	case "DELETE":
+		if !cities.remove(id) {
+			problem.Write(w, r, http.StatusNotFound, "City Not Found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
 	default:
-		http.Error(w, "Method not Allowed", http.StatusMethodNotAllowed)
+		problem.Write(w, r, http.StatusMethodNotAllowed, "Method not Allowed")
+	}
+}
+
+// statsLogic sums up the area of all cities.
+func statsLogic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		problem.Write(w, r, http.StatusMethodNotAllowed, "Method not Allowed")
+		return
 	}
+	writeJSON(w, http.StatusOK, cities.stats())
 }
 
-func Run(port string) {
-	mainHandleLogic := http.HandlerFunc(mainLogic)
+// largestLogic lists the n largest cities by area, n defaults to 5.
+func largestLogic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		problem.Write(w, r, http.StatusMethodNotAllowed, "Method not Allowed")
+		return
+	}
 
+	n := 5
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 || n > maxCityLimit {
+			problem.Write(w, r, http.StatusBadRequest, fmt.Sprintf("n must be between 1 and %d, got %q", maxCityLimit, v))
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string][]City{"cities": cities.largest(n)})
+}
+
+// locateLogic finds the city whose boundary contains the point given by lat and lon.
+func locateLogic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		problem.Write(w, r, http.StatusMethodNotAllowed, "Method not Allowed")
+		return
+	}
+
+	point, err := geo.ParsePoint(r.URL.Query().Get("lat"), r.URL.Query().Get("lon"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	city, ok := cities.containing(point)
+	if !ok {
+		problem.Write(w, r, http.StatusNotFound, "No city contains this point")
+		return
+	}
+	writeJSON(w, http.StatusOK, city)
+}
+
+// geoJSONLogic returns every city as a GeoJSON feature, drawn by its boundary where it has one.
+func geoJSONLogic(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		problem.Write(w, r, http.StatusMethodNotAllowed, "Method not Allowed")
+		return
+	}
+
+	page := cities.list(cityQuery{Sort: "id", Limit: math.MaxInt})
+	features := make([]geo.Feature, 0, len(page.Cities))
+	for _, city := range page.Cities {
+		var geometry *geo.Geometry
+		if city.Boundary != nil {
+			geometry = geo.PolygonGeometry(city.Boundary)
+		} else if location, ok := city.Location(); ok {
+			geometry = geo.PointGeometry(location)
+		}
+		features = append(features, geo.NewFeature(city.ID, geometry, map[string]any{"name": city.Name, "area": city.Area}))
+	}
+	geo.NewFeatureCollection(features).Write(w)
+}
+
+// NewHandler returns the cities API wrapped in its middleware chain and the request logger.
+func NewHandler() http.Handler {
 	// here we are chaining middlewares together without a library
 	// http.Handle("/city", ContentTypeMiddleware(ServerTimeMiddleware(mainHandleLogic)))
 
-	// using alice for middleware chaining
-	chain := alice.New(ContentTypeMiddleware, ServerTimeMiddleware).Then(mainHandleLogic)
+	// using alice for middleware chaining, throttled first so refused requests cost nothing
+	// retried POSTs with an Idempotency-Key get the first response instead of creating the city again
+	limiter := ratelimit.ForService("cities")
+	guard := idempotency.ForService("cities", idempotency.NewMemoryStore())
+	chain := alice.New(limiter.Middleware, ContentTypeMiddleware, guard.Middleware, ServerTimeMiddleware)
+
+	mux := http.NewServeMux()
+	mux.Handle("/city", chain.ThenFunc(mainLogic))
+	mux.Handle("/city/{id}", chain.ThenFunc(cityLogic))
+	mux.Handle("/city/stats", chain.ThenFunc(statsLogic))
+	mux.Handle("/city/largest", chain.ThenFunc(largestLogic))
+	mux.Handle("/city/locate", chain.ThenFunc(locateLogic))
+	mux.Handle("/city/geojson", chain.ThenFunc(geoJSONLogic))
+
+	// structured access log with a request ID, replacing the Apache-style gorilla logger,
+	// around the hardening every public API gets
+	return logging.Middleware(Production().Then(mux))
+}
+
+func Run(cfg config.Cities, lc config.Lifecycle) error {
+	m := lifecycle.New(lc)
 
-	http.Handle("/city", chain)
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
 
-	//using the gorilla logger 
-	loggedRouter := handlers.LoggingHandler(os.Stdout, chain)
+	mux := http.NewServeMux()
+	mux.Handle("/", alice.New(tracing.Middleware("cities"), metrics.Middleware("cities")).Then(NewHandler()))
+	checker.RegisterServeMux(mux)
+	metrics.RegisterServeMux(mux)
 
-	fmt.Println("Server running on PORT 8080....")
-	log.Fatal(http.ListenAndServe(port, loggedRouter))
+	m.AddServer("cities", &http.Server{Addr: cfg.Addr, Handler: mux})
+	return m.Run(context.Background())
 }
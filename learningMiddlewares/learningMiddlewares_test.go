@@ -0,0 +1,219 @@
+package learningmiddlewares
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/Dav16Akin/go-dictionary/apitest"
+	"github.com/Dav16Akin/go-dictionary/geo"
+)
+
+func TestMain(m *testing.M) {
+	apitest.Setup()
+	os.Exit(m.Run())
+}
+
+// newServer serves the cities API with no cities yet.
+func newServer(t *testing.T) *apitest.Server {
+	cities = newCityStore()
+	t.Cleanup(func() { cities = newCityStore() })
+	return apitest.NewServer(t, NewHandler())
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// square is a boundary of side 2 degrees around lat, lon.
+func square(lat, lon float64) geo.Polygon {
+	return geo.Polygon{{{lon - 1, lat - 1}, {lon + 1, lat - 1}, {lon + 1, lat + 1}, {lon - 1, lat + 1}, {lon - 1, lat - 1}}}
+}
+
+func TestCities(t *testing.T) {
+	srv := newServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		status int
+		want   string
+		detail string
+	}{
+		{"create", "POST", "/city", City{Name: "Lagos", Area: 1171}, 201, `{"id":1,"name":"Lagos","area":1171}`, ""},
+		{"create with location", "POST", "/city", City{Name: "Abuja", Area: 1769, Latitude: ptr(9.07), Longitude: ptr(7.49)}, 201,
+			`{"id":2,"name":"Abuja","area":1769,"latitude":9.07,"longitude":7.49}`, ""},
+		{"create without name", "POST", "/city", City{Area: 5}, 422, "", "name is required"},
+		{"create with half a location", "POST", "/city", City{Name: "X", Latitude: ptr(1.0)}, 422, "", "latitude and longitude go together"},
+		{"get", "GET", "/city/1", nil, 200, `{"id":1,"name":"Lagos","area":1171}`, ""},
+		{"get missing", "GET", "/city/9", nil, 404, "", "City Not Found"},
+		{"get bad id", "GET", "/city/lagos", nil, 400, "", "Invalid City ID"},
+		{"replace", "PUT", "/city/1", City{Name: "Lagos", Area: 1200}, 200, `{"id":1,"name":"Lagos","area":1200}`, ""},
+		{"replace missing", "PUT", "/city/9", City{Name: "Nowhere"}, 404, "", "City Not Found"},
+		{"patch", "PATCH", "/city/2", map[string]any{"area": 1800}, 200, `{"id":2,"name":"Abuja","area":1800,"latitude":9.07,"longitude":7.49}`, ""},
+		{"patch invalid", "PATCH", "/city/2", map[string]any{"name": ""}, 422, "", "name is required"},
+		{"patch missing", "PATCH", "/city/9", map[string]any{"area": 1}, 404, "", "City Not Found"},
+		{"patch null clears", "PATCH", "/city/2", map[string]any{"latitude": nil, "longitude": nil}, 200, `{"id":2,"name":"Abuja","area":1800}`, ""},
+		{"patch null required", "PATCH", "/city/2", map[string]any{"name": nil}, 422, "", "name is required"},
+		{"delete", "DELETE", "/city/2", nil, 204, "", ""},
+		{"delete again", "DELETE", "/city/2", nil, 404, "", "City Not Found"},
+		{"method not allowed", "POST", "/city/1", City{Name: "X"}, 405, "", "Method not Allowed"},
+		{"list", "GET", "/city", nil, 200, `{"cities":[{"id":1,"name":"Lagos","area":1200}],"total":1,"limit":20,"offset":0}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := srv.Do(t, tt.method, tt.path, tt.body).Status(t, tt.status)
+			if tt.want != "" {
+				resp.JSON(t, tt.want)
+			}
+			if tt.detail != "" {
+				resp.Problem(t, tt.detail)
+			}
+			if resp.StatusCode == http.StatusCreated && resp.Header.Get("Location") == "" {
+				t.Error("201 without Location")
+			}
+		})
+	}
+
+	srv.Do(t, "POST", "/city", `{"name":`, "Content-Type", "application/json").Status(t, 400)
+}
+
+func TestCityList(t *testing.T) {
+	srv := newServer(t)
+	for _, city := range []City{{Name: "lagos", Area: 1171}, {Name: "Abuja", Area: 1769}, {Name: "Kano", Area: 499}, {Name: "Lokoja", Area: 63}} {
+		srv.Do(t, "POST", "/city", city).Status(t, 201)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"by name", "?sort=name", `["Abuja","Kano","lagos","Lokoja"]`},
+		{"by area descending", "?sort=-area", `["Abuja","lagos","Kano","Lokoja"]`},
+		{"name prefix ignoring case", "?name=L", `["lagos","Lokoja"]`},
+		{"area range", "?min_area=100&max_area=1200", `["lagos","Kano"]`},
+		{"zero max area", "?max_area=0", `[]`},
+		{"page", "?sort=name&limit=2&offset=1", `["Kano","lagos"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var page cityPage
+			srv.Do(t, "GET", "/city"+tt.query, nil).Status(t, 200).Decode(t, &page)
+			names := make([]string, 0, len(page.Cities))
+			for _, city := range page.Cities {
+				names = append(names, fmt.Sprintf("%q", city.Name))
+			}
+			apitest.EqualJSON(t, fmt.Appendf(nil, "[%s]", joinComma(names)), tt.want)
+		})
+	}
+
+	for _, query := range []string{"?sort=size", "?limit=0", "?limit=101", "?offset=-1", "?min_area=x", "?min_area=10&max_area=5"} {
+		t.Run("rejects "+query, func(t *testing.T) {
+			srv.Do(t, "GET", "/city"+query, nil).Status(t, 400)
+		})
+	}
+
+	srv.Do(t, "GET", "/city/stats", nil).Status(t, 200).JSON(t, `{"count":4,"total_area":3502,"average_area":875.5}`)
+	srv.Do(t, "GET", "/city/largest?n=2", nil).Status(t, 200).
+		JSON(t, `{"cities":[{"id":2,"name":"Abuja","area":1769},{"id":1,"name":"lagos","area":1171}]}`)
+	srv.Do(t, "GET", "/city/largest?n=0", nil).Status(t, 400)
+	srv.Do(t, "POST", "/city/stats", map[string]any{}).Status(t, 405)
+}
+
+func joinComma(items []string) string {
+	joined := ""
+	for i, item := range items {
+		if i > 0 {
+			joined += ","
+		}
+		joined += item
+	}
+	return joined
+}
+
+func TestCityGeo(t *testing.T) {
+	srv := newServer(t)
+	srv.Do(t, "POST", "/city", City{Name: "Lagos", Latitude: ptr(6.5), Longitude: ptr(3.4), Boundary: square(6.5, 3.4)}).Status(t, 201)
+	srv.Do(t, "POST", "/city", City{Name: "Kano", Latitude: ptr(12.0), Longitude: ptr(8.5)}).Status(t, 201)
+	srv.Do(t, "POST", "/city", City{Name: "Open", Boundary: geo.Polygon{{{0, 0}, {1, 0}, {1, 1}}}}).Status(t, 422)
+
+	// a boundary around the whole world would put the city in about 259,000 index cells
+	world := geo.Polygon{{{-180, -90}, {180, -90}, {180, 90}, {-180, 90}, {-180, -90}}}
+	srv.Do(t, "POST", "/city", City{Name: "World", Boundary: world}).Status(t, 422).Problem(t, "boundary: spans more than 5 degrees of latitude or longitude")
+	srv.Do(t, "PATCH", "/city/2", map[string]any{"boundary": world}).Status(t, 422)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		city   string
+	}{
+		{"inside", "?lat=6.9&lon=3.0", 200, "Lagos"},
+		{"outside every boundary", "?lat=12.0&lon=8.5", 404, ""},
+		{"not a point", "?lat=north&lon=3", 400, ""},
+		{"out of range", "?lat=91&lon=3", 400, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := srv.Do(t, "GET", "/city/locate"+tt.query, nil).Status(t, tt.status)
+			if tt.city != "" {
+				var city City
+				resp.Decode(t, &city)
+				if city.Name != tt.city {
+					t.Errorf("city = %q, want %q", city.Name, tt.city)
+				}
+			}
+		})
+	}
+
+	// a patched boundary replaces the old one in the index
+	srv.Do(t, "PATCH", "/city/1", map[string]any{"boundary": square(20, 20)}).Status(t, 200)
+	srv.Do(t, "GET", "/city/locate?lat=6.9&lon=3.0", nil).Status(t, 404)
+	srv.Do(t, "GET", "/city/locate?lat=20.5&lon=19.5", nil).Status(t, 200)
+
+	resp := srv.Do(t, "GET", "/city/geojson", nil).Status(t, 200)
+	if ct := resp.Header.Get("Content-Type"); ct != geo.MIME {
+		t.Errorf("Content-Type = %q, want %s", ct, geo.MIME)
+	}
+	resp.JSON(t, `{"type":"FeatureCollection","features":[
+		{"type":"Feature","id":1,"geometry":{"type":"Polygon","coordinates":[[[19,19],[21,19],[21,21],[19,21],[19,19]]]},"properties":{"name":"Lagos","area":0}},
+		{"type":"Feature","id":2,"geometry":{"type":"Point","coordinates":[8.5,12]},"properties":{"name":"Kano","area":0}}]}`)
+
+	// a boundary patched to null leaves the index
+	srv.Do(t, "PATCH", "/city/1", map[string]any{"boundary": nil}).Status(t, 200)
+	srv.Do(t, "GET", "/city/locate?lat=20.5&lon=19.5", nil).Status(t, 404)
+}
+
+// TestCityStoreConcurrent hammers the store's map and boundary index from many goroutines, for go test -race.
+func TestCityStoreConcurrent(t *testing.T) {
+	s := newCityStore()
+	const workers, perWorker = 8, 25
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Go(func() {
+			for i := range perWorker {
+				lat, lon := float64(w), float64(i)
+				city := s.create(City{Name: fmt.Sprintf("city %d-%d", w, i), Area: uint64(i), Boundary: square(lat, lon)})
+				s.containing(geo.Point{Lat: lat, Lon: lon})
+				s.list(cityQuery{Sort: "area", Limit: 10})
+				s.stats()
+				s.largest(3)
+				if _, ok, err := s.patch(city.ID, cityPatch{Area: patchField[uint64]{Set: true, Value: ptr(uint64(i + 1))}}, validateCity); !ok || err != nil {
+					t.Errorf("patch %d: %v, %v", city.ID, ok, err)
+				}
+				if i%2 == 0 && !s.remove(city.ID) {
+					t.Errorf("remove %d failed", city.ID)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	if stats := s.stats(); stats.Count != workers*(perWorker/2) {
+		t.Errorf("Count = %d, want %d", stats.Count, workers*(perWorker/2))
+	}
+}
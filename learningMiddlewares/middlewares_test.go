@@ -0,0 +1,277 @@
+package learningmiddlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/apitest"
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/andybalholm/brotli"
+)
+
+// echo answers 200 with the request body, or 204 for requests without one.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(body) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+})
+
+func TestContentTypeMiddleware(t *testing.T) {
+	srv := apitest.NewServer(t, ContentTypeMiddleware(echo))
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		detail      string
+	}{
+		{"json", "POST", "application/json", "{}", 200, ""},
+		{"json with charset", "PUT", "application/json; charset=UTF-8", "{}", 200, ""},
+		{"merge patch", "PATCH", "application/merge-patch+json", "{}", 200, ""},
+		{"no body", "POST", "", "", 204, ""},
+		{"unchecked method", "DELETE", "text/plain", "x", 200, ""},
+		{"missing", "POST", "", "{}", 415, "Content-Type is required, one of: application/json, +json"},
+		{"form", "POST", "application/x-www-form-urlencoded", "a=b", 415, "Content-Type application/x-www-form-urlencoded is not one of: application/json, +json"},
+		{"unparsable", "POST", "application/json; charset", "{}", 415, "Content-Type application/json; charset cannot be parsed"},
+		{"latin-1 json", "POST", "application/json; charset=iso-8859-1", "{}", 415, "JSON must be encoded as UTF-8, not iso-8859-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header []string
+			if tt.contentType != "" {
+				header = []string{"Content-Type", tt.contentType}
+			}
+			resp := srv.Do(t, tt.method, "/", tt.body, header...).Status(t, tt.status)
+			if tt.detail != "" {
+				resp.Problem(t, tt.detail)
+			}
+			if got := resp.Header.Get("Accept-Post"); got != "application/json" {
+				t.Errorf("Accept-Post = %q", got)
+			}
+		})
+	}
+
+	t.Run("wildcard", func(t *testing.T) {
+		images := apitest.NewServer(t, RequireContentTypes(ContentTypes{"PUT": {"image/*"}})(echo))
+		images.Do(t, "PUT", "/", "png", "Content-Type", "IMAGE/PNG").Status(t, 200)
+		images.Do(t, "PUT", "/", "{}", "Content-Type", "application/json").Status(t, 415)
+	})
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	cors := config.Default().CORS
+	cors.AllowedOrigins = []string{"https://app.example"}
+	srv := apitest.NewServer(t, CORSMiddleware(cors)(echo))
+
+	tests := []struct {
+		name    string
+		method  string
+		header  []string
+		status  int
+		allowed string
+		methods string
+	}{
+		{"same origin", "GET", nil, 204, "", ""},
+		{"allowed origin", "GET", []string{"Origin", "https://app.example"}, 204, "https://app.example", ""},
+		{"origin ignoring case", "GET", []string{"Origin", "HTTPS://APP.EXAMPLE"}, 204, "HTTPS://APP.EXAMPLE", ""},
+		{"other origin", "GET", []string{"Origin", "https://evil.example"}, 204, "", ""},
+		{"preflight", "OPTIONS", []string{"Origin", "https://app.example", "Access-Control-Request-Method", "PATCH"}, 204,
+			"https://app.example", "GET, POST, PUT, PATCH, DELETE"},
+		{"preflight for another method", "OPTIONS", []string{"Origin", "https://app.example", "Access-Control-Request-Method", "TRACE"}, 204,
+			"https://app.example", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := srv.Do(t, tt.method, "/", nil, tt.header...).Status(t, tt.status)
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tt.allowed {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allowed)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Methods"); got != tt.methods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.methods)
+			}
+			if got := resp.Header.Values("Vary"); len(got) == 0 || got[0] != "Origin" {
+				t.Errorf("Vary = %q, want Origin first", got)
+			}
+		})
+	}
+
+	t.Run("any origin", func(t *testing.T) {
+		cors.AllowedOrigins = []string{"*"}
+		anyOrigin := apitest.NewServer(t, CORSMiddleware(cors)(echo))
+		resp := anyOrigin.Do(t, "GET", "/", nil, "Origin", "https://app.example")
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+		}
+
+		// credentials rule out "*"
+		cors.AllowCredentials = true
+		withCredentials := apitest.NewServer(t, CORSMiddleware(cors)(echo))
+		resp = withCredentials.Do(t, "GET", "/", nil, "Origin", "https://app.example")
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want the origin", got)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Credentials"); got != "true" {
+			t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+		}
+	})
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	sec := config.Default().Security
+	sec.HSTS = config.Duration(24 * time.Hour)
+	resp := apitest.NewServer(t, SecurityHeadersMiddleware(sec)(echo)).Do(t, "GET", "/", nil)
+
+	want := map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "no-referrer",
+		"X-Frame-Options":           sec.FrameOptions,
+		"Content-Security-Policy":   sec.ContentSecurityPolicy,
+		"Strict-Transport-Security": "max-age=86400; includeSubDomains",
+	}
+	for name, value := range want {
+		if got := resp.Header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestMaxBodyMiddleware(t *testing.T) {
+	srv := apitest.NewServer(t, MaxBodyMiddleware(8)(echo))
+
+	srv.Do(t, "POST", "/", "12345678").Status(t, 200)
+	srv.Do(t, "POST", "/", "123456789").Status(t, 413).Problem(t, "request body is larger than 8 bytes")
+
+	// without a Content-Length the limit is hit while reading
+	req, err := http.NewRequest("POST", srv.URL, io.MultiReader(strings.NewReader("1234"), strings.NewReader("56789")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("chunked body over the limit = %d, want 413", resp.StatusCode)
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	srv := apitest.NewServer(t, RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/late" {
+			w.Write([]byte("partial"))
+		}
+		panic("boom")
+	})))
+
+	srv.Do(t, "GET", "/", nil).Status(t, 500).Problem(t, "the server hit an unexpected error")
+
+	// a started response is cut off rather than finished
+	resp, err := srv.Client().Get(srv.URL + "/late")
+	if err == nil {
+		_, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if err == nil {
+		t.Error("response after a late panic was read to the end")
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	body := strings.Repeat(`{"name":"Lagos","area":1171},`, 50)
+	srv := apitest.NewServer(t, CompressMiddleware(echo))
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		status         int
+		encoding       string
+	}{
+		{"identity", "", body, 200, ""},
+		{"gzip", "gzip", body, 200, "gzip"},
+		{"brotli wins a tie", "gzip, br", body, 200, "br"},
+		{"weights", "br;q=0.5, gzip;q=0.8", body, 200, "gzip"},
+		{"refused", "br;q=0, gzip;q=0", body, 200, ""},
+		{"unsupported", "deflate", body, 200, ""},
+		{"no content", "gzip", "", 204, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header []string
+			if tt.acceptEncoding != "" {
+				header = []string{"Accept-Encoding", tt.acceptEncoding}
+			}
+			resp := srv.Do(t, "POST", "/", tt.body, header...).Status(t, tt.status)
+			if got := resp.Header.Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if got := resp.Header.Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+
+			var decoded io.Reader = bytes.NewReader(resp.Body)
+			switch tt.encoding {
+			case "gzip":
+				zr, err := gzip.NewReader(decoded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded = zr
+			case "br":
+				decoded = brotli.NewReader(decoded)
+			}
+			got, err := io.ReadAll(decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.body {
+				t.Errorf("decoded body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+// TestProduction checks the chain in front of the cities API as a whole.
+func TestProduction(t *testing.T) {
+	sec := config.Default().Security
+	sec.Compression = true
+	sec.MaxBodyBytes = 64
+	cors := config.Default().CORS
+	cors.AllowedOrigins = []string{"*"}
+	Setup(cors, sec)
+	t.Cleanup(func() { Setup(config.Default().CORS, config.Default().Security) })
+
+	srv := newServer(t)
+	srv.Do(t, "POST", "/city", City{Name: strings.Repeat("x", 100)}).Status(t, 413)
+
+	resp := srv.Do(t, "GET", "/city", nil, "Origin", "https://app.example", "Accept-Encoding", "gzip")
+	resp.Status(t, 200)
+	for name, value := range map[string]string{
+		"Content-Encoding":            "gzip",
+		"Access-Control-Allow-Origin": "*",
+		"X-Content-Type-Options":      "nosniff",
+	} {
+		if got := resp.Header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if resp.Header.Get("Date") == "" {
+		t.Error("no Date header")
+	}
+}
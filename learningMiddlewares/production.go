@@ -0,0 +1,36 @@
+package learningmiddlewares
+
+import (
+	"sync"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/justinas/alice"
+)
+
+var (
+	policyMutex sync.RWMutex
+	corsPolicy  = config.Default().CORS
+	security    = config.Default().Security
+)
+
+// Setup sets the CORS and security settings Production builds its chain from.
+func Setup(cors config.CORS, sec config.Security) {
+	policyMutex.Lock()
+	defer policyMutex.Unlock()
+	corsPolicy, security = cors, sec
+}
+
+// Production returns the middlewares every public API should sit behind, outermost first:
+// compression, panic recovery, security headers, CORS and the request body limit.
+// Recovery runs inside compression so its problem response gets encoded like any other.
+func Production() alice.Chain {
+	policyMutex.RLock()
+	cors, sec := corsPolicy, security
+	policyMutex.RUnlock()
+
+	chain := alice.New()
+	if sec.Compression {
+		chain = chain.Append(CompressMiddleware)
+	}
+	return chain.Append(RecoveryMiddleware, SecurityHeadersMiddleware(sec), CORSMiddleware(cors), MaxBodyMiddleware(sec.MaxBodyBytes))
+}
@@ -0,0 +1,67 @@
+package learningmiddlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/Dav16Akin/go-dictionary/problem"
+)
+
+// RecoveryMiddleware turns a panic into a logged error and a 500 problem response.
+// When the handler had already started its response, the connection is cut instead,
+// so the client does not take a truncated body for a complete one.
+func RecoveryMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracker := &writeTracker{ResponseWriter: w}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			slog.ErrorContext(r.Context(), "Panic serving request", "panic", recovered, "stack", string(debug.Stack()))
+			if tracker.wrote {
+				panic(http.ErrAbortHandler)
+			}
+			problem.Write(w, r, http.StatusInternalServerError, "the server hit an unexpected error")
+		}()
+
+		h.ServeHTTP(tracker, r)
+	})
+}
+
+// writeTracker remembers whether the response has been started.
+type writeTracker struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (t *writeTracker) WriteHeader(code int) {
+	// informational responses do not start the final one
+	if code >= 200 {
+		t.wrote = true
+	}
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *writeTracker) Write(b []byte) (int, error) {
+	t.wrote = true
+	return t.ResponseWriter.Write(b)
+}
+
+func (t *writeTracker) Flush() {
+	t.wrote = true
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (t *writeTracker) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
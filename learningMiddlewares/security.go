@@ -0,0 +1,55 @@
+package learningmiddlewares
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/problem"
+)
+
+// SecurityHeadersMiddleware sets the headers that keep browsers from sniffing, framing or
+// running anything out of the API's responses.
+func SecurityHeadersMiddleware(cfg config.Security) func(http.Handler) http.Handler {
+	hsts := ""
+	if cfg.HSTS > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(time.Duration(cfg.HSTS).Seconds())) + "; includeSubDomains"
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			header.Set("X-Content-Type-Options", "nosniff")
+			header.Set("Referrer-Policy", "no-referrer")
+			if cfg.FrameOptions != "" {
+				header.Set("X-Frame-Options", cfg.FrameOptions)
+			}
+			if cfg.ContentSecurityPolicy != "" {
+				header.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+			}
+			if hsts != "" {
+				header.Set("Strict-Transport-Security", hsts)
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxBodyMiddleware refuses request bodies over limit bytes. A declared Content-Length over
+// the limit is refused right away; otherwise reading past the limit fails in the handler.
+func MaxBodyMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				problem.Write(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", limit))
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
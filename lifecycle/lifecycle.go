@@ -0,0 +1,211 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+)
+
+type server struct {
+	name     string
+	srv      *http.Server
+	listener net.Listener
+}
+
+type closer struct {
+	name string
+	c    io.Closer
+}
+
+// Manager starts a set of HTTP servers, waits for SIGINT/SIGTERM and shuts them down gracefully.
+// Servers are drained first, then the registered closers (database handles) run in reverse order.
+type Manager struct {
+	cfg config.Lifecycle
+
+	mutex   sync.Mutex
+	servers []*server
+	closers []closer
+
+	ready    atomic.Bool
+	draining atomic.Bool
+}
+
+func New(cfg config.Lifecycle) *Manager {
+	return &Manager{cfg: cfg}
+}
+
+// AddServer registers a server that will listen on srv.Addr.
+func (m *Manager) AddServer(name string, srv *http.Server) {
+	m.AddListener(name, srv, nil)
+}
+
+// AddListener registers a server that serves on an already opened listener, such as the RPC listener.
+// A nil listener makes Run listen on srv.Addr.
+func (m *Manager) AddListener(name string, srv *http.Server, l net.Listener) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.servers = append(m.servers, &server{name: name, srv: srv, listener: l})
+}
+
+// AddCloser registers a resource to close once every server has drained.
+func (m *Manager) AddCloser(name string, c io.Closer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.closers = append(m.closers, closer{name: name, c: c})
+}
+
+// Ready reports whether every server is listening and no shutdown has started.
+func (m *Manager) Ready() bool {
+	return m.ready.Load()
+}
+
+// Draining reports whether a shutdown is in progress.
+func (m *Manager) Draining() bool {
+	return m.draining.Load()
+}
+
+// Addr returns the address the named server is listening on, or "" before Run opened it.
+func (m *Manager) Addr(name string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, s := range m.servers {
+		if s.name == name && s.listener != nil {
+			return s.listener.Addr().String()
+		}
+	}
+	return ""
+}
+
+// Run serves until ctx is cancelled, a signal arrives or a server fails, then shuts everything down.
+// It returns the first server error, or nil after a clean shutdown.
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := m.listen(); err != nil {
+		m.closeAll()
+		return err
+	}
+
+	m.mutex.Lock()
+	servers := append([]*server(nil), m.servers...)
+	m.mutex.Unlock()
+
+	errCh := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s *server) {
+			log.Printf("%s listening on %s", s.name, s.listener.Addr())
+			if err := s.srv.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- fmt.Errorf("%s: %w", s.name, err)
+			}
+		}(s)
+	}
+
+	m.ready.Store(true)
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutdown signal received, draining connections...")
+	case runErr = <-errCh:
+		log.Printf("Server failed, shutting down: %v", runErr)
+	}
+
+	if err := m.shutdown(servers); err != nil && runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+
+func (m *Manager) listen() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, s := range m.servers {
+		if s.listener != nil {
+			continue
+		}
+
+		l, err := net.Listen("tcp", s.srv.Addr)
+		if err != nil {
+			for _, opened := range m.servers {
+				if opened.listener != nil {
+					opened.listener.Close()
+				}
+			}
+			return fmt.Errorf("%s: listen on %s: %w", s.name, s.srv.Addr, err)
+		}
+		s.listener = l
+	}
+	return nil
+}
+
+func (m *Manager) shutdown(servers []*server) error {
+	m.ready.Store(false)
+	m.draining.Store(true)
+
+	if delay := time.Duration(m.cfg.ShutdownDelay); delay > 0 {
+		log.Printf("Reporting not ready for %s before draining", delay)
+		time.Sleep(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cfg.DrainTimeout))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(servers))
+	for i, s := range servers {
+		wg.Add(1)
+		go func(i int, s *server) {
+			defer wg.Done()
+
+			if err := s.srv.Shutdown(ctx); err != nil {
+				// drain timeout hit, cut whatever is left
+				s.srv.Close()
+				errs[i] = fmt.Errorf("%s: %w", s.name, err)
+				return
+			}
+			log.Printf("%s drained", s.name)
+		}(i, s)
+	}
+	wg.Wait()
+
+	return errors.Join(append(errs, m.closeAll())...)
+}
+
+// Close runs the registered closers without serving, for setups that fail before Run.
+func (m *Manager) Close() error {
+	return m.closeAll()
+}
+
+func (m *Manager) closeAll() error {
+	m.mutex.Lock()
+	closers := m.closers
+	m.closers = nil
+	m.mutex.Unlock()
+
+	var errs []error
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing %s: %w", closers[i].name, err))
+			continue
+		}
+		log.Printf("Closed %s", closers[i].name)
+	}
+	return errors.Join(errs...)
+}
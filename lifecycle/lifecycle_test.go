@@ -0,0 +1,167 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+)
+
+// events records what happened, in order, across goroutines.
+type events struct {
+	mutex sync.Mutex
+	list  []string
+}
+
+func (e *events) add(event string) {
+	e.mutex.Lock()
+	e.list = append(e.list, event)
+	e.mutex.Unlock()
+}
+
+func (e *events) get() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return slices.Clone(e.list)
+}
+
+type closeFunc func() error
+
+func (f closeFunc) Close() error { return f() }
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRunDrainsBeforeClosing(t *testing.T) {
+	var history events
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		history.add("request done")
+	})
+
+	m := New(config.Lifecycle{DrainTimeout: config.Duration(5 * time.Second), ShutdownDelay: config.Duration(50 * time.Millisecond)})
+	m.AddServer("test", &http.Server{Addr: "127.0.0.1:0", Handler: handler})
+	m.AddCloser("first", closeFunc(func() error { history.add("closed first"); return nil }))
+	m.AddCloser("second", closeFunc(func() error { history.add("closed second"); return nil }))
+
+	if m.Ready() {
+		t.Error("ready before Run")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+	waitFor(t, "readiness", m.Ready)
+
+	got := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + m.Addr("test"))
+		if err == nil {
+			resp.Body.Close()
+			history.add("response")
+		}
+		got <- err
+	}()
+	<-started
+
+	cancel()
+	waitFor(t, "draining", m.Draining)
+	if m.Ready() {
+		t.Error("still ready while draining")
+	}
+	// the shutdown delay has not passed yet, nothing may be closed
+	time.Sleep(20 * time.Millisecond)
+	if events := history.get(); len(events) != 0 {
+		t.Errorf("events during the shutdown delay: %q", events)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := <-got; err != nil {
+		t.Fatalf("in-flight request: %v", err)
+	}
+
+	events := history.get()
+	if i := slices.Index(events, "request done"); i < 0 || i > slices.Index(events, "closed second") {
+		t.Errorf("events %q: the request must finish before anything is closed", events)
+	}
+	if slices.Index(events, "closed second") > slices.Index(events, "closed first") {
+		t.Errorf("events %q: closers must run in reverse order", events)
+	}
+}
+
+func TestRunDrainTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	m := New(config.Lifecycle{DrainTimeout: config.Duration(50 * time.Millisecond)})
+	m.AddServer("test", &http.Server{Addr: "127.0.0.1:0", Handler: handler})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+	waitFor(t, "readiness", m.Ready)
+
+	go func() {
+		if resp, err := http.Get("http://" + m.Addr("test")); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Run = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not give up on the stuck request")
+	}
+}
+
+func TestRunListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	closed := false
+	m := New(config.Lifecycle{DrainTimeout: config.Duration(time.Second)})
+	m.AddServer("taken", &http.Server{Addr: l.Addr().String()})
+	m.AddCloser("db", closeFunc(func() error { closed = true; return nil }))
+
+	if err := m.Run(t.Context()); err == nil {
+		t.Fatal("Run on a taken port succeeded")
+	}
+	if !closed {
+		t.Error("closers did not run after the listen error")
+	}
+	if m.Ready() {
+		t.Error("ready after the listen error")
+	}
+}
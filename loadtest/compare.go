@@ -0,0 +1,177 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/emicklei/go-restful"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+	"github.com/julienschmidt/httprouter"
+)
+
+// items is the endpoint every router serves for -compare. The handlers are shared and no
+// middleware is in the way, so the routers are all that differs between the runs.
+var items = resource{path: "/v1/items", body: func(name string) any {
+	return map[string]any{"name": name}
+}}
+
+type item struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type itemStore struct {
+	mutex  sync.RWMutex
+	nextID int
+	items  map[int]item
+}
+
+func newItemStore() *itemStore {
+	return &itemStore{items: map[int]item{}}
+}
+
+func (s *itemStore) get(w http.ResponseWriter, id string) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		http.Error(w, "invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.RLock()
+	it, ok := s.items[n]
+	s.mutex.RUnlock()
+	if !ok {
+		http.Error(w, "item not found", http.StatusNotFound)
+		return
+	}
+	writeItem(w, http.StatusOK, it)
+}
+
+func (s *itemStore) create(w http.ResponseWriter, r *http.Request) {
+	var it item
+	if err := json.NewDecoder(r.Body).Decode(&it); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	s.nextID++
+	it.ID = s.nextID
+	s.items[it.ID] = it
+	s.mutex.Unlock()
+	writeItem(w, http.StatusCreated, it)
+}
+
+func (s *itemStore) remove(w http.ResponseWriter, id string) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		http.Error(w, "invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	_, ok := s.items[n]
+	delete(s.items, n)
+	s.mutex.Unlock()
+	if !ok {
+		http.Error(w, "item not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeItem(w http.ResponseWriter, status int, it item) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(it)
+}
+
+// routers builds the items endpoint on each router, in the order they are compared.
+var routers = []struct {
+	name string
+	new  func(s *itemStore) http.Handler
+}{
+	{"stdlib", func(s *itemStore) http.Handler {
+		m := http.NewServeMux()
+		m.HandleFunc("GET /v1/items/{id}", func(w http.ResponseWriter, r *http.Request) { s.get(w, r.PathValue("id")) })
+		m.HandleFunc("POST /v1/items", s.create)
+		m.HandleFunc("DELETE /v1/items/{id}", func(w http.ResponseWriter, r *http.Request) { s.remove(w, r.PathValue("id")) })
+		return m
+	}},
+	{"gorilla", func(s *itemStore) http.Handler {
+		r := mux.NewRouter()
+		r.HandleFunc("/v1/items/{id}", func(w http.ResponseWriter, r *http.Request) { s.get(w, mux.Vars(r)["id"]) }).Methods(http.MethodGet)
+		r.HandleFunc("/v1/items", s.create).Methods(http.MethodPost)
+		r.HandleFunc("/v1/items/{id}", func(w http.ResponseWriter, r *http.Request) { s.remove(w, mux.Vars(r)["id"]) }).Methods(http.MethodDelete)
+		return r
+	}},
+	{"httprouter", func(s *itemStore) http.Handler {
+		router := httprouter.New()
+		router.GET("/v1/items/:id", func(w http.ResponseWriter, _ *http.Request, p httprouter.Params) { s.get(w, p.ByName("id")) })
+		router.POST("/v1/items", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) { s.create(w, r) })
+		router.DELETE("/v1/items/:id", func(w http.ResponseWriter, _ *http.Request, p httprouter.Params) { s.remove(w, p.ByName("id")) })
+		return router
+	}},
+	{"gin", func(s *itemStore) http.Handler {
+		// debug mode prints every route, which would end up in the middle of the report
+		gin.SetMode(gin.ReleaseMode)
+		router := gin.New()
+		router.GET("/v1/items/:id", func(c *gin.Context) { s.get(c.Writer, c.Param("id")) })
+		router.POST("/v1/items", func(c *gin.Context) { s.create(c.Writer, c.Request) })
+		router.DELETE("/v1/items/:id", func(c *gin.Context) { s.remove(c.Writer, c.Param("id")) })
+		return router
+	}},
+	{"go-restful", func(s *itemStore) http.Handler {
+		container := restful.NewContainer()
+		ws := new(restful.WebService)
+		ws.Path("/v1/items").Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+		ws.Route(ws.GET("/{id}").To(func(req *restful.Request, resp *restful.Response) { s.get(resp, req.PathParameter("id")) }))
+		ws.Route(ws.POST("").To(func(req *restful.Request, resp *restful.Response) { s.create(resp, req.Request) }))
+		ws.Route(ws.DELETE("/{id}").To(func(req *restful.Request, resp *restful.Response) { s.remove(resp, req.PathParameter("id")) }))
+		container.Add(ws)
+		return container
+	}},
+}
+
+// compareRouters serves the items endpoint with each router on a loopback port in turn and
+// drives the same mix against every one of them.
+func compareRouters(ctx context.Context, cfg config.LoadTest) ([]*Report, error) {
+	var reports []*Report
+	for _, router := range routers {
+		if ctx.Err() != nil {
+			break
+		}
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("listen error: %w", err)
+		}
+		server := &http.Server{Handler: router.new(newItemStore())}
+		served := make(chan error, 1)
+		go func() { served <- server.Serve(l) }()
+
+		run := cfg
+		run.Target = "http://" + l.Addr().String()
+		run.Scenario = "items"
+		report, err := measure(ctx, restScenario(run, items), run)
+
+		server.Close()
+		if serveErr := <-served; !errors.Is(serveErr, http.ErrServerClosed) {
+			return nil, fmt.Errorf("%s: %w", router.name, serveErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", router.name, err)
+		}
+		report.Name = router.name
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
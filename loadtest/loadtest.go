@@ -0,0 +1,308 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+)
+
+// maxFailures is how many distinct error messages a report keeps.
+const maxFailures = 5
+
+// worker sends the requests of one simulated client. Each worker keeps its own state, so a read
+// never races the delete of another worker.
+type worker interface {
+	// read and write send one request and return the HTTP status, 0 when no response came back.
+	read(ctx context.Context) (int, error)
+	write(ctx context.Context) (int, error)
+	// close deletes what the worker created, outside of the measured time.
+	close(ctx context.Context)
+}
+
+// scenario creates the workers of a run.
+type scenario struct {
+	name string
+	// readOnly scenarios send every request through read, whatever the mix.
+	readOnly  bool
+	newWorker func(ctx context.Context, n int) (worker, error)
+}
+
+// Run drives the configured scenario against cfg.Target, or compares several targets given
+// as name=target arguments. SIGINT/SIGTERM stops early and still reports what was measured.
+func Run(cfg config.LoadTest, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.Compare {
+		if len(args) > 0 {
+			return fmt.Errorf("-compare serves its own targets, drop the name=target arguments")
+		}
+		reports, err := compareRouters(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		return writeComparison(os.Stdout, cfg.Format, reports)
+	}
+
+	if len(args) == 0 {
+		report, err := drive(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		return writeReport(os.Stdout, cfg.Format, report)
+	}
+
+	targets := make([][2]string, 0, len(args))
+	for _, arg := range args {
+		name, target, ok := strings.Cut(arg, "=")
+		if !ok || name == "" || target == "" {
+			return fmt.Errorf("argument %q is not name=target", arg)
+		}
+		targets = append(targets, [2]string{name, target})
+	}
+
+	var reports []*Report
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		run := cfg
+		run.Target = t[1]
+		report, err := drive(ctx, run)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t[0], err)
+		}
+		report.Name = t[0]
+		reports = append(reports, report)
+	}
+	return writeComparison(os.Stdout, cfg.Format, reports)
+}
+
+// drive runs cfg.Scenario against cfg.Target.
+func drive(ctx context.Context, cfg config.LoadTest) (*Report, error) {
+	s, err := newScenario(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return measure(ctx, s, cfg)
+}
+
+func newScenario(cfg config.LoadTest) (scenario, error) {
+	if cfg.Scenario == "rpc" {
+		return rpcScenario(cfg), nil
+	}
+	if cfg.Scenario == "health" {
+		return healthScenario(cfg), nil
+	}
+	res, ok := resources[cfg.Scenario]
+	if !ok {
+		return scenario{}, fmt.Errorf("unknown scenario %q", cfg.Scenario)
+	}
+	return restScenario(cfg, res), nil
+}
+
+// sample is the outcome of one request.
+type sample struct {
+	write   bool
+	latency time.Duration
+	status  int
+	err     error
+}
+
+// measure starts cfg.Concurrency workers and lets them send requests for cfg.Duration.
+func measure(ctx context.Context, s scenario, cfg config.LoadTest) (*Report, error) {
+	workers := make([]worker, cfg.Concurrency)
+	defer func() {
+		cleanup, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout)*time.Duration(cfg.Concurrency))
+		defer cancel()
+		for _, w := range workers {
+			if w != nil {
+				w.close(cleanup)
+			}
+		}
+	}()
+	for i := range workers {
+		w, err := s.newWorker(ctx, i)
+		if err != nil {
+			return nil, fmt.Errorf("preparing worker %d: %w", i, err)
+		}
+		workers[i] = w
+	}
+
+	slog.Info("Load test started", "target", cfg.Target, "scenario", s.name, "concurrency", cfg.Concurrency,
+		"duration", time.Duration(cfg.Duration), "reads", cfg.Reads)
+
+	// requests still in flight when the run ends are waited for, dropping them would hide the slowest
+	deadline := time.Now().Add(time.Duration(cfg.Duration))
+
+	samples := make([][]sample, len(workers))
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, w := range workers {
+		wg.Go(func() {
+			for time.Now().Before(deadline) {
+				sample := send(ctx, w, !s.readOnly && rand.Float64() >= cfg.Reads, time.Duration(cfg.Timeout))
+				if ctx.Err() != nil {
+					// cut short by SIGINT, it says nothing about the server
+					return
+				}
+				samples[i] = append(samples[i], sample)
+			}
+		})
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	report := newReport(slices.Concat(samples...), elapsed)
+	report.Target = cfg.Target
+	report.Scenario = s.name
+	report.Concurrency = cfg.Concurrency
+	return report, nil
+}
+
+func send(ctx context.Context, w worker, write bool, timeout time.Duration) sample {
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var status int
+	var err error
+	if write {
+		status, err = w.write(reqCtx)
+	} else {
+		status, err = w.read(reqCtx)
+	}
+	s := sample{write: write, latency: time.Since(start), status: status, err: err}
+	if err == nil && status >= 400 {
+		s.err = fmt.Errorf("status %d %s", status, http.StatusText(status))
+	}
+	return s
+}
+
+// Latency holds the percentiles of the request durations, in milliseconds.
+type Latency struct {
+	Min  float64 `json:"min_ms"`
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// Operation sums up the reads or the writes of a run.
+type Operation struct {
+	Name       string  `json:"name"`
+	Requests   int     `json:"requests"`
+	Errors     int     `json:"errors"`
+	Throughput float64 `json:"requests_per_second"`
+	Latency    Latency `json:"latency"`
+}
+
+// Report is the outcome of one run. Statuses counts the responses by HTTP status, requests
+// that got none are counted under "error".
+type Report struct {
+	Name        string         `json:"name,omitempty"`
+	Target      string         `json:"target"`
+	Scenario    string         `json:"scenario"`
+	Concurrency int            `json:"concurrency"`
+	Seconds     float64        `json:"duration_seconds"`
+	Total       Operation      `json:"total"`
+	ErrorRate   float64        `json:"error_rate"`
+	Operations  []Operation    `json:"operations"`
+	Statuses    map[string]int `json:"statuses"`
+	Failures    []string       `json:"failures,omitempty"`
+}
+
+func newReport(samples []sample, elapsed time.Duration) *Report {
+	r := &Report{Seconds: elapsed.Seconds(), Statuses: map[string]int{}}
+	var reads, writes []sample
+	for _, s := range samples {
+		if s.write {
+			writes = append(writes, s)
+		} else {
+			reads = append(reads, s)
+		}
+
+		if s.status == 0 {
+			r.Statuses["error"]++
+		} else {
+			r.Statuses[fmt.Sprint(s.status)]++
+		}
+		if s.err != nil && len(r.Failures) < maxFailures && !slices.Contains(r.Failures, s.err.Error()) {
+			r.Failures = append(r.Failures, s.err.Error())
+		}
+	}
+
+	r.Total = operation("total", samples, elapsed)
+	if r.Total.Requests > 0 {
+		r.ErrorRate = float64(r.Total.Errors) / float64(r.Total.Requests)
+	}
+	for _, op := range []struct {
+		name    string
+		samples []sample
+	}{{"read", reads}, {"write", writes}} {
+		if len(op.samples) > 0 {
+			r.Operations = append(r.Operations, operation(op.name, op.samples, elapsed))
+		}
+	}
+	return r
+}
+
+func operation(name string, samples []sample, elapsed time.Duration) Operation {
+	op := Operation{Name: name, Requests: len(samples)}
+	if len(samples) == 0 {
+		return op
+	}
+
+	latencies := make([]time.Duration, len(samples))
+	var sum time.Duration
+	for i, s := range samples {
+		latencies[i] = s.latency
+		sum += s.latency
+		if s.err != nil {
+			op.Errors++
+		}
+	}
+	slices.Sort(latencies)
+
+	// nearest-rank percentile: the smallest latency at least p of the samples don't exceed
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p*float64(len(latencies)))) - 1
+		return millis(latencies[max(0, min(rank, len(latencies)-1))])
+	}
+	op.Throughput = float64(len(samples)) / elapsed.Seconds()
+	op.Latency = Latency{
+		Min:  millis(latencies[0]),
+		Mean: millis(sum / time.Duration(len(latencies))),
+		P50:  percentile(0.50),
+		P90:  percentile(0.90),
+		P95:  percentile(0.95),
+		P99:  percentile(0.99),
+		Max:  millis(latencies[len(latencies)-1]),
+	}
+	return op
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// drain reads what is left of a response body, so the connection goes back to the pool.
+func drain(body io.ReadCloser) {
+	io.Copy(io.Discard, body)
+	body.Close()
+}
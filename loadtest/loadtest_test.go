@@ -0,0 +1,142 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+)
+
+// samples returns a read for each latency in milliseconds, in the order given.
+func samples(latencies ...int) []sample {
+	s := make([]sample, len(latencies))
+	for i, ms := range latencies {
+		s[i] = sample{latency: time.Duration(ms) * time.Millisecond, status: 200}
+	}
+	return s
+}
+
+func TestOperation(t *testing.T) {
+	// the order samples come in doesn't matter
+	tenShuffled := samples(7, 3, 10, 1, 5, 9, 2, 8, 6, 4)
+	for _, i := range []int{0, 4, 7} {
+		tenShuffled[i].err = errors.New("status 500 Internal Server Error")
+	}
+	hundred := make([]int, 100)
+	for i := range hundred {
+		hundred[i] = 100 - i
+	}
+
+	tests := []struct {
+		name    string
+		samples []sample
+		elapsed time.Duration
+		want    Operation
+	}{
+		{"ten", tenShuffled, 2 * time.Second, Operation{Name: "ten", Requests: 10, Errors: 3, Throughput: 5,
+			Latency: Latency{Min: 1, Mean: 5.5, P50: 5, P90: 9, P95: 10, P99: 10, Max: 10}}},
+		{"hundred", samples(hundred...), 4 * time.Second, Operation{Name: "hundred", Requests: 100, Throughput: 25,
+			Latency: Latency{Min: 1, Mean: 50.5, P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}}},
+		// the rank is rounded up: the 90th percentile of 7 samples is the 7th (6.3 rounded up), not the 6th
+		{"seven", samples(1, 2, 3, 4, 5, 6, 7), time.Second, Operation{Name: "seven", Requests: 7, Throughput: 7,
+			Latency: Latency{Min: 1, Mean: 4, P50: 4, P90: 7, P95: 7, P99: 7, Max: 7}}},
+		{"one", samples(3), 500 * time.Millisecond, Operation{Name: "one", Requests: 1, Throughput: 2,
+			Latency: Latency{Min: 3, Mean: 3, P50: 3, P90: 3, P95: 3, P99: 3, Max: 3}}},
+		{"none", nil, time.Second, Operation{Name: "none"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := operation(tt.name, tt.samples, tt.elapsed); got != tt.want {
+				t.Errorf("operation = %+v\nwant        %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	refused := errors.New("connection refused")
+	var all []sample
+	for i := range 8 {
+		all = append(all, sample{latency: time.Duration(i+1) * time.Millisecond, status: 200})
+	}
+	all = append(all,
+		sample{write: true, latency: 10 * time.Millisecond, status: 201},
+		sample{write: true, latency: 20 * time.Millisecond, status: 500, err: errors.New("status 500 Internal Server Error")},
+		sample{write: true, latency: 30 * time.Millisecond, err: refused},
+		sample{latency: 40 * time.Millisecond, err: refused},
+	)
+	// more distinct errors than a report keeps
+	for i := range maxFailures {
+		all = append(all, sample{latency: time.Millisecond, status: 404, err: fmt.Errorf("status 404 for item %d", i)})
+	}
+
+	r := newReport(all, 2*time.Second)
+	if r.Seconds != 2 {
+		t.Errorf("Seconds = %v, want 2", r.Seconds)
+	}
+	if r.Total.Requests != 17 || r.Total.Errors != 8 || r.Total.Throughput != 8.5 {
+		t.Errorf("Total = %+v, want 17 requests, 8 errors and 8.5/s", r.Total)
+	}
+	if r.ErrorRate != 8.0/17 {
+		t.Errorf("ErrorRate = %v, want 8/17", r.ErrorRate)
+	}
+
+	wantStatuses := map[string]int{"200": 8, "201": 1, "404": 5, "500": 1, "error": 2}
+	if !maps.Equal(r.Statuses, wantStatuses) {
+		t.Errorf("Statuses = %v, want %v", r.Statuses, wantStatuses)
+	}
+	// each distinct message once, in the order first seen, up to maxFailures
+	wantFailures := []string{"status 500 Internal Server Error", "connection refused", "status 404 for item 0", "status 404 for item 1", "status 404 for item 2"}
+	if !slices.Equal(r.Failures, wantFailures) {
+		t.Errorf("Failures = %q, want %q", r.Failures, wantFailures)
+	}
+
+	if len(r.Operations) != 2 {
+		t.Fatalf("%d operations, want read and write", len(r.Operations))
+	}
+	read, write := r.Operations[0], r.Operations[1]
+	if read.Name != "read" || read.Requests != 14 || read.Errors != 6 || read.Latency.Max != 40 {
+		t.Errorf("read = %+v, want 14 requests, 6 errors and 40ms max", read)
+	}
+	if write.Name != "write" || write.Requests != 3 || write.Errors != 2 || write.Throughput != 1.5 ||
+		write.Latency.Min != 10 || write.Latency.Mean != 20 || write.Latency.Max != 30 {
+		t.Errorf("write = %+v, want 3 requests, 2 errors, 1.5/s and 10/20/30ms", write)
+	}
+
+	// a run without writes reports no write operation, and one without requests no error rate
+	if ops := newReport(samples(1, 2), time.Second).Operations; len(ops) != 1 || ops[0].Name != "read" {
+		t.Errorf("operations of a read-only run = %+v, want only read", ops)
+	}
+	if r := newReport(nil, time.Second); r.ErrorRate != 0 || r.Total.Requests != 0 || len(r.Operations) != 0 {
+		t.Errorf("empty report = %+v", r)
+	}
+}
+
+func TestCompareRouters(t *testing.T) {
+	cfg := config.LoadTest{
+		Reads:       0.5,
+		Concurrency: 2,
+		Duration:    config.Duration(100 * time.Millisecond),
+		Timeout:     config.Duration(time.Second),
+	}
+	reports, err := compareRouters(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != len(routers) {
+		t.Fatalf("%d reports for %d routers", len(reports), len(routers))
+	}
+	for i, r := range reports {
+		if r.Name != routers[i].name {
+			t.Errorf("report %d is %q, want %q", i, r.Name, routers[i].name)
+		}
+		if r.Total.Requests == 0 || r.Total.Errors != 0 {
+			t.Errorf("%s: %d requests with %d errors %q, want some and no errors", r.Name, r.Total.Requests, r.Total.Errors, r.Failures)
+		}
+	}
+}
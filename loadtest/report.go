@@ -0,0 +1,77 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// writeReport prints one run, as JSON or as a table of the reads, writes and their total.
+func writeReport(w io.Writer, format string, r *Report) error {
+	if format == "json" {
+		return writeJSON(w, r)
+	}
+
+	fmt.Fprintf(w, "Target:    %s (%s)\n", r.Target, r.Scenario)
+	fmt.Fprintf(w, "Duration:  %.1fs with %d workers\n", r.Seconds, r.Concurrency)
+	fmt.Fprintf(w, "Requests:  %d (%.1f/s)\n", r.Total.Requests, r.Total.Throughput)
+	fmt.Fprintf(w, "Errors:    %d (%.2f%%)\n", r.Total.Errors, 100*r.ErrorRate)
+	fmt.Fprintf(w, "Statuses:  %s\n", statuses(r))
+	for _, failure := range r.Failures {
+		fmt.Fprintf(w, "  %s\n", failure)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\trequests\terrors\treq/s\tmean\tp50\tp90\tp95\tp99\tmax\t")
+	for _, op := range append(r.Operations, r.Total) {
+		l := op.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\t%s\t\n", op.Name, op.Requests, op.Errors, op.Throughput,
+			ms(l.Mean), ms(l.P50), ms(l.P90), ms(l.P95), ms(l.P99), ms(l.Max))
+	}
+	return tw.Flush()
+}
+
+// writeComparison prints one line per run, so the targets can be compared side by side.
+func writeComparison(w io.Writer, format string, reports []*Report) error {
+	if format == "json" {
+		return writeJSON(w, reports)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\trequests\terrors\treq/s\tmean\tp50\tp90\tp95\tp99\tmax\t")
+	for _, r := range reports {
+		t, l := r.Total, r.Total.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%.2f%%\t%.1f\t%s\t%s\t%s\t%s\t%s\t%s\t\n", r.Name, t.Requests, 100*r.ErrorRate, t.Throughput,
+			ms(l.Mean), ms(l.P50), ms(l.P90), ms(l.P95), ms(l.P99), ms(l.Max))
+	}
+	return tw.Flush()
+}
+
+func ms(v float64) string {
+	return fmt.Sprintf("%.2fms", v)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// statuses lists the counts of a report ordered by status, "error" last.
+func statuses(r *Report) string {
+	keys := make([]string, 0, len(r.Statuses))
+	for key := range r.Statuses {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", key, r.Statuses[key])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,301 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/rpc"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	rpcserver "github.com/Dav16Akin/go-dictionary/rpcServer"
+)
+
+// resource is a collection of one of the APIs: reads get an item, writes create or delete one.
+type resource struct {
+	// path of the collection below the target URL
+	path string
+	// body of a new item; name is unique within the run
+	body func(name string) any
+	// account makes each worker register and log in a user of its own first, whose ID replaces
+	// {id} in path
+	account bool
+}
+
+var resources = map[string]resource{
+	"stations": {path: "/v1/stations", body: func(name string) any {
+		return map[string]any{"name": name, "opening_time": "05:00:00", "closing_time": "23:00:00"}
+	}},
+	"trains": {path: "/v1/trains", body: func(name string) any {
+		return map[string]any{"driver_name": name, "operating_status": true}
+	}},
+	// users are only created by registering, so the users scenario loads a user's addresses
+	"users": {path: "/users/{id}/addresses", body: func(name string) any {
+		return map[string]any{"label": name, "street": "1 Main St", "city": "Springfield", "country": "US"}
+	}, account: true},
+	"cities": {path: "/city", body: func(name string) any {
+		return map[string]any{"name": name, "area": 100}
+	}},
+}
+
+func newClient(cfg config.LoadTest) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// one idle connection per worker, otherwise most requests would open a new one
+	transport.MaxIdleConnsPerHost = cfg.Concurrency
+	return &http.Client{Transport: transport}
+}
+
+// restScenario creates, reads and deletes items of res. Every worker starts with one item of
+// its own, so there is always something to read, and the items left are deleted afterwards.
+func restScenario(cfg config.LoadTest, res resource) scenario {
+	client := newClient(cfg)
+	target := strings.TrimSuffix(cfg.Target, "/")
+	base := target + res.path
+	// keeps the names of this run apart from those of earlier ones, users need unique emails
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	return scenario{
+		name: cfg.Scenario,
+		newWorker: func(ctx context.Context, n int) (worker, error) {
+			w := &restWorker{client: client, base: base, res: res, prefix: fmt.Sprintf("loadtest %s %d", run, n)}
+			if res.account {
+				if err := w.logIn(ctx, target); err != nil {
+					return nil, err
+				}
+			}
+			status, err := w.create(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if status != http.StatusCreated {
+				return nil, fmt.Errorf("POST %s answered %d", base, status)
+			}
+			return w, nil
+		},
+	}
+}
+
+type restWorker struct {
+	client *http.Client
+	base   string
+	res    resource
+	prefix string
+	// created counts the items made so far, ids are those not deleted yet
+	created    int
+	ids        []int
+	deleteNext bool
+	// user is the URL of the worker's user and token its session, when res.account is set
+	user  string
+	token string
+}
+
+// logIn registers a user for the worker and logs it in, so its requests carry the session.
+func (w *restWorker) logIn(ctx context.Context, target string) error {
+	// login reads the email and password and ignores the name
+	account, err := json.Marshal(map[string]string{
+		"name":     w.prefix,
+		"email":    strings.ReplaceAll(w.prefix, " ", "-") + "@example.com",
+		"password": "loadtest password",
+	})
+	if err != nil {
+		return err
+	}
+
+	status, err := w.do(ctx, http.MethodPost, target+"/accounts/register", account, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusAccepted {
+		return fmt.Errorf("POST %s/accounts/register answered %d", target, status)
+	}
+
+	var session struct {
+		Token  string `json:"token"`
+		UserID int    `json:"user_id"`
+	}
+	if status, err = w.do(ctx, http.MethodPost, target+"/accounts/login", account, &session); err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("POST %s/accounts/login answered %d", target, status)
+	}
+	id := strconv.Itoa(session.UserID)
+	w.user, w.token = target+"/users/"+id, session.Token
+	w.base = strings.Replace(w.base, "{id}", id, 1)
+	return nil
+}
+
+func (w *restWorker) read(ctx context.Context) (int, error) {
+	id := w.ids[rand.IntN(len(w.ids))]
+	return w.do(ctx, http.MethodGet, w.base+"/"+strconv.Itoa(id), nil, nil)
+}
+
+// write creates and deletes items in turn, so a worker holds one or two of them.
+func (w *restWorker) write(ctx context.Context) (int, error) {
+	w.deleteNext = !w.deleteNext
+	if !w.deleteNext && len(w.ids) > 1 {
+		id := w.ids[len(w.ids)-1]
+		w.ids = w.ids[:len(w.ids)-1]
+		return w.do(ctx, http.MethodDelete, w.base+"/"+strconv.Itoa(id), nil, nil)
+	}
+	return w.create(ctx)
+}
+
+func (w *restWorker) create(ctx context.Context) (int, error) {
+	w.created++
+	body, err := json.Marshal(w.res.body(fmt.Sprintf("%s-%d", w.prefix, w.created)))
+	if err != nil {
+		return 0, err
+	}
+
+	var created struct {
+		ID int `json:"id"`
+		// the stations API wraps the item in result
+		Result struct {
+			ID int `json:"id"`
+		} `json:"result"`
+	}
+	status, err := w.do(ctx, http.MethodPost, w.base, body, &created)
+	if err != nil || status != http.StatusCreated {
+		return status, err
+	}
+
+	id := max(created.ID, created.Result.ID)
+	if id == 0 {
+		return status, fmt.Errorf("POST %s answered without an id", w.base)
+	}
+	w.ids = append(w.ids, id)
+	return status, nil
+}
+
+func (w *restWorker) close(ctx context.Context) {
+	for _, id := range w.ids {
+		w.do(ctx, http.MethodDelete, w.base+"/"+strconv.Itoa(id), nil, nil)
+	}
+	w.ids = nil
+	// deleting the user deletes its account
+	if w.user != "" {
+		w.do(ctx, http.MethodDelete, w.user, nil, nil)
+		w.user = ""
+	}
+}
+
+// do sends one request and decodes a successful JSON answer into v when it is not nil.
+func (w *restWorker) do(ctx context.Context, method, url string, body []byte, v any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer drain(resp.Body)
+
+	if v != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return resp.StatusCode, fmt.Errorf("decoding %s %s: %w", method, url, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// healthScenario only asks for /healthz, which every server has.
+func healthScenario(cfg config.LoadTest) scenario {
+	client := newClient(cfg)
+	url := strings.TrimSuffix(cfg.Target, "/") + "/healthz"
+
+	return scenario{
+		name:     cfg.Scenario,
+		readOnly: true,
+		newWorker: func(context.Context, int) (worker, error) {
+			return healthWorker{client: client, url: url}, nil
+		},
+	}
+}
+
+type healthWorker struct {
+	client *http.Client
+	url    string
+}
+
+func (w healthWorker) read(ctx context.Context) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	drain(resp.Body)
+	return resp.StatusCode, nil
+}
+
+func (w healthWorker) write(ctx context.Context) (int, error) {
+	return w.read(ctx)
+}
+
+func (healthWorker) close(context.Context) {}
+
+// rpcScenario calls TimeServer.GiveServerTime. Each worker dials its own connection, net/rpc
+// would otherwise serialize the calls of all workers on one.
+func rpcScenario(cfg config.LoadTest) scenario {
+	addr, path, hasPath := strings.Cut(cfg.Target, "/")
+	path = "/" + path
+	if !hasPath {
+		path = rpc.DefaultRPCPath
+	}
+
+	return scenario{
+		name:     cfg.Scenario,
+		readOnly: true,
+		newWorker: func(context.Context, int) (worker, error) {
+			client, err := rpc.DialHTTPPath("tcp", addr, path)
+			if err != nil {
+				return nil, err
+			}
+			return rpcWorker{client}, nil
+		},
+	}
+}
+
+type rpcWorker struct {
+	client *rpc.Client
+}
+
+// read reports 200 for a successful call, so RPC runs report alike HTTP ones.
+func (w rpcWorker) read(ctx context.Context) (int, error) {
+	var reply int64
+	call := w.client.Go("TimeServer.GiveServerTime", &rpcserver.Args{}, &reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		if call.Error != nil {
+			return 0, call.Error
+		}
+		return http.StatusOK, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (w rpcWorker) write(ctx context.Context) (int, error) {
+	return w.read(ctx)
+}
+
+func (w rpcWorker) close(context.Context) {
+	w.client.Close()
+}
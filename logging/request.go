@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/internal/recorder"
+	"github.com/emicklei/go-restful"
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderRequestID carries the request ID in both directions.
+const HeaderRequestID = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// NewRequestID returns 16 random bytes, hex encoded.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validID accepts what callers reasonably send as an ID, so a client cannot
+// inject newlines or arbitrarily large values into the logs.
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// begin propagates the incoming X-Request-ID or generates one, and echoes it on the response
+// so clients can quote it when reporting an error.
+func begin(w http.ResponseWriter, r *http.Request) (context.Context, string) {
+	id := r.Header.Get(HeaderRequestID)
+	if !validID(id) {
+		id = NewRequestID()
+	}
+	w.Header().Set(HeaderRequestID, id)
+	return WithRequestID(r.Context(), id), id
+}
+
+// access writes the one line logged per request. Server errors are logged as errors
+// and client errors as warnings, so a warn level keeps only what needs a look.
+func access(ctx context.Context, r *http.Request, route string, status, size int, start time.Time) {
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case status >= 400:
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Int("bytes", size),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("remote_addr", r.RemoteAddr),
+	}
+	if route != "" {
+		attrs = append(attrs, slog.String("route", route))
+	}
+
+	slog.LogAttrs(ctx, level, "request", attrs...)
+}
+
+// Middleware tags each request with an ID and logs it once it has been served.
+// Requests that already carry an ID, because an outer Middleware or adapter tagged them, pass through.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if RequestID(r.Context()) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		ctx, _ := begin(w, r)
+		r = r.WithContext(ctx)
+
+		// a pattern set before next ran belongs to an outer mux, not to the route that served the request
+		outer := r.Pattern
+
+		rec := recorder.New(w)
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == outer {
+			route = ""
+		}
+		access(ctx, r, route, rec.Status, rec.Size, start)
+	})
+}
+
+// RestfulFilter does what Middleware does, as a go-restful container filter.
+func RestfulFilter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	if RequestID(req.Request.Context()) != "" {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+
+	start := time.Now()
+	ctx, _ := begin(resp, req.Request)
+	req.Request = req.Request.WithContext(ctx)
+
+	chain.ProcessFilter(req, resp)
+
+	status := resp.StatusCode()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	access(ctx, req.Request, "", status, resp.ContentLength(), start)
+}
+
+// Gin does what Middleware does, as Gin middleware.
+func Gin(c *gin.Context) {
+	if RequestID(c.Request.Context()) != "" {
+		c.Next()
+		return
+	}
+
+	start := time.Now()
+	ctx, _ := begin(c.Writer, c.Request)
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+
+	access(ctx, c.Request, c.FullPath(), c.Writer.Status(), max(c.Writer.Size(), 0), start)
+}
@@ -0,0 +1,261 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/tracing"
+	"github.com/emicklei/go-restful"
+	"github.com/gin-gonic/gin"
+)
+
+// capture makes a JSON logger writing to the returned buffer the default one until the test ends.
+func capture(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := New(config.Logging{Level: "debug", Format: "json"}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// lines decodes the JSON log lines in buf.
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// adapters serve GET /items/7 through each of Middleware, RestfulFilter and Gin with a handler
+// answering status. The handler reports the request ID it saw on seen.
+func adapters(status int, seen *string) map[string]http.Handler {
+	gin.SetMode(gin.TestMode)
+
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		*seen = RequestID(r.Context())
+		w.WriteHeader(status)
+	})
+
+	container := restful.NewContainer()
+	container.Filter(RestfulFilter)
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/items/{id}").To(func(req *restful.Request, resp *restful.Response) {
+		*seen = RequestID(req.Request.Context())
+		resp.WriteHeader(status)
+	}))
+	container.Add(ws)
+
+	router := gin.New()
+	router.Use(Gin)
+	router.GET("/items/:id", func(c *gin.Context) {
+		*seen = RequestID(c.Request.Context())
+		c.Status(status)
+	})
+
+	return map[string]http.Handler{
+		"middleware": Middleware(serveMux),
+		"restful":    container,
+		"gin":        router,
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	long := strings.Repeat("a", 128)
+	tests := []struct {
+		name     string
+		incoming string
+		// propagated is whether the incoming ID is kept, a new one is generated otherwise
+		propagated bool
+	}{
+		{"none", "", false},
+		{"valid", "abc-123_x.y:z", true},
+		{"longest", long, true},
+		{"too long", long + "a", false},
+		{"space", "has space", false},
+		{"newline", "two\nlines", false},
+		{"unicode", "idé", false},
+	}
+
+	var seen string
+	for name, handler := range adapters(http.StatusOK, &seen) {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				logs := capture(t)
+				seen = ""
+				r := httptest.NewRequest("GET", "/items/7", nil)
+				if tt.incoming != "" {
+					r.Header[http.CanonicalHeaderKey(HeaderRequestID)] = []string{tt.incoming}
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				id := w.Header().Get(HeaderRequestID)
+				if tt.propagated && id != tt.incoming {
+					t.Errorf("%s = %q, want the incoming %q", HeaderRequestID, id, tt.incoming)
+				}
+				if !tt.propagated && (id == tt.incoming || len(id) != 32 || !validID(id)) {
+					t.Errorf("%s = %q, want a new 32 character ID", HeaderRequestID, id)
+				}
+				if seen != id {
+					t.Errorf("handler saw request ID %q, response has %q", seen, id)
+				}
+
+				records := lines(t, logs)
+				if len(records) != 1 {
+					t.Fatalf("logged %d lines, want one: %s", len(records), logs)
+				}
+				if records[0]["request_id"] != id {
+					t.Errorf("logged request_id %v, want %q", records[0]["request_id"], id)
+				}
+			})
+		}
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	a, b := NewRequestID(), NewRequestID()
+	if a == b || !validID(a) || len(a) != 32 {
+		t.Errorf("NewRequestID = %q then %q, want two distinct 32 character IDs", a, b)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		status int
+		level  string
+	}{
+		{http.StatusOK, "INFO"},
+		{http.StatusNotFound, "WARN"},
+		{http.StatusServiceUnavailable, "ERROR"},
+	}
+
+	var seen string
+	for _, tt := range tests {
+		for name, handler := range adapters(tt.status, &seen) {
+			t.Run(name+"/"+http.StatusText(tt.status), func(t *testing.T) {
+				logs := capture(t)
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/7", nil))
+
+				records := lines(t, logs)
+				if len(records) != 1 {
+					t.Fatalf("logged %d lines, want one: %s", len(records), logs)
+				}
+				record := records[0]
+				if record["msg"] != "request" || record["level"] != tt.level || record["status"] != float64(tt.status) {
+					t.Errorf("logged %v, want a %s request line with status %d", record, tt.level, tt.status)
+				}
+				if record["method"] != "GET" || record["path"] != "/items/7" {
+					t.Errorf("logged %v, want GET /items/7", record)
+				}
+			})
+		}
+	}
+}
+
+func TestAccessLogRoute(t *testing.T) {
+	var seen string
+	handlers := adapters(http.StatusOK, &seen)
+	for name, route := range map[string]any{
+		"middleware": "GET /items/{id}",
+		"gin":        "/items/:id",
+		// go-restful does not tell the filter which route it matched
+		"restful": nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			logs := capture(t)
+			handlers[name].ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/7", nil))
+			if records := lines(t, logs); len(records) != 1 || records[0]["route"] != route {
+				t.Errorf("logged %v, want route %v", records, route)
+			}
+		})
+	}
+}
+
+func TestTraceOnLogLines(t *testing.T) {
+	logs := capture(t)
+	handler := tracing.Middleware("test")(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "handling")
+	})))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(tracing.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set(HeaderRequestID, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	records := lines(t, logs)
+	if len(records) != 2 {
+		t.Fatalf("logged %d lines, want the handler's and the request line: %s", len(records), logs)
+	}
+	for _, record := range records {
+		if record["request_id"] != "req-1" || record["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("logged %v, want request_id req-1 and the incoming trace_id", record)
+		}
+		if span, _ := record["span_id"].(string); len(span) != 16 || span == "00f067aa0ba902b7" {
+			t.Errorf("logged span_id %v, want the server span's", record["span_id"])
+		}
+	}
+
+	// outside a request neither is added
+	logs.Reset()
+	slog.Info("idle")
+	if records := lines(t, logs); records[0]["request_id"] != nil || records[0]["trace_id"] != nil {
+		t.Errorf("logged %v outside a request", records[0])
+	}
+}
+
+func TestNestedMiddleware(t *testing.T) {
+	logs := capture(t)
+	var inner string
+	handler := Middleware(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = RequestID(r.Context())
+	})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if inner == "" || inner != w.Header().Get(HeaderRequestID) {
+		t.Errorf("inner handler saw %q, response has %q", inner, w.Header().Get(HeaderRequestID))
+	}
+	if records := lines(t, logs); len(records) != 1 {
+		t.Errorf("logged %d lines, want the request logged once", len(records))
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []config.Logging{
+		{Level: "loud", Format: "json"},
+		{Level: "info", Format: "xml"},
+	} {
+		if _, err := New(cfg, &bytes.Buffer{}); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+
+	var buf bytes.Buffer
+	logger, err := New(config.Logging{Level: "warn", Format: "text"}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.InfoContext(WithRequestID(t.Context(), "req-1"), "dropped")
+	logger.WarnContext(WithRequestID(t.Context(), "req-1"), "kept")
+	if out := buf.String(); strings.Contains(out, "dropped") || !strings.Contains(out, "msg=kept request_id=req-1") {
+		t.Errorf("text log = %q, want the warning with its request_id only", out)
+	}
+}
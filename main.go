@@ -1,5 +1,195 @@
 package main
 
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/cache"
+	"github.com/Dav16Akin/go-dictionary/config"
+	ginfundamentals "github.com/Dav16Akin/go-dictionary/ginFundamentals"
+	gorestfulfundamemtals "github.com/Dav16Akin/go-dictionary/goRestfulFundamemtals"
+	gorillarpcserver "github.com/Dav16Akin/go-dictionary/gorillaRPCServer"
+	"github.com/Dav16Akin/go-dictionary/idempotency"
+	learningmiddlewares "github.com/Dav16Akin/go-dictionary/learningMiddlewares"
+	"github.com/Dav16Akin/go-dictionary/loadtest"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	othermux "github.com/Dav16Akin/go-dictionary/otherMux"
+	railapi "github.com/Dav16Akin/go-dictionary/railAPI"
+	"github.com/Dav16Akin/go-dictionary/ratelimit"
+	"github.com/Dav16Akin/go-dictionary/rpcClient"
+	rpcserver "github.com/Dav16Akin/go-dictionary/rpcServer"
+	sqlitefundamentals "github.com/Dav16Akin/go-dictionary/sqliteFundamentals"
+	testingstatefulapi "github.com/Dav16Akin/go-dictionary/testingStatefulApi"
+	"github.com/Dav16Akin/go-dictionary/tracing"
+)
+
+type command struct {
+	summary string
+	// bind registers the command's flags on the config section it reads
+	bind func(fs *flag.FlagSet, cfg *config.Config)
+	run  func(cfg *config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"rail": {
+		"go-restful rail API (trains)",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.Rail.BindFlags(fs) },
+		func(cfg *config.Config, _ []string) error {
+			return railapi.RunRailGoRestfulAPI(cfg.Rail, cfg.Lifecycle)
+		},
+	},
+	"gin": {
+		"Gin stations API",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.Gin.BindFlags(fs) },
+		func(cfg *config.Config, _ []string) error { return ginfundamentals.RunGinAPI(cfg.Gin, cfg.Lifecycle) },
+	},
+	"users": {
+		"in-memory users API",
+		func(fs *flag.FlagSet, cfg *config.Config) {
+			cfg.Users.BindFlags(fs)
+			cfg.Accounts.BindFlags(fs)
+		},
+		func(cfg *config.Config, _ []string) error { return testingstatefulapi.Run(cfg.Users, cfg.Lifecycle) },
+	},
+	"cities": {
+		"cities API behind the alice middleware chain",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.Cities.BindFlags(fs) },
+		func(cfg *config.Config, _ []string) error { return learningmiddlewares.Run(cfg.Cities, cfg.Lifecycle) },
+	},
+	"rpc-server": {
+		"net/rpc TimeServer",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.RPCServer.BindFlags(fs) },
+		func(cfg *config.Config, _ []string) error { return rpcserver.Run(cfg.RPCServer, cfg.Lifecycle) },
+	},
+	"rpc-client": {
+		"call TimeServer.GiveServerTime once",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.RPCClient.BindFlags(fs) },
+		func(cfg *config.Config, _ []string) error { return rpcClient.Run(cfg.RPCClient) },
+	},
+	"jsonrpc": {
+		"gorilla JSON-RPC book server",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.JSONRPC.BindFlags(fs) },
+		func(cfg *config.Config, _ []string) error { return gorillarpcserver.Run(cfg.JSONRPC, cfg.Lifecycle) },
+	},
+	"mux": {
+		"gorilla/mux or httprouter examples",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.Mux.BindFlags(fs) },
+		runMux,
+	},
+	"ping": {
+		"go-restful ping service",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.Ping.BindFlags(fs) },
+		func(cfg *config.Config, _ []string) error { return gorestfulfundamemtals.Run(cfg.Ping, cfg.Lifecycle) },
+	},
+	"books": {
+		"SQLite CRUD walkthrough on the books table",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.Books.BindFlags(fs) },
+		func(cfg *config.Config, _ []string) error { sqlitefundamentals.Run(cfg.Books); return nil },
+	},
+	"trace-collector": {
+		"local stand-in for an OTLP/HTTP trace collector",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.TraceCollector.BindFlags(fs) },
+		func(cfg *config.Config, _ []string) error {
+			return tracing.RunCollector(cfg.TraceCollector, cfg.Lifecycle)
+		},
+	},
+	"loadtest": {
+		"send a request mix to a server and report latency and errors",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.LoadTest.BindFlags(fs) },
+		func(cfg *config.Config, args []string) error { return loadtest.Run(cfg.LoadTest, args) },
+	},
+	"serve": {
+		"mount several services on one listener",
+		func(fs *flag.FlagSet, cfg *config.Config) { cfg.BindServeFlags(fs) },
+		runServe,
+	},
+}
+
 func main() {
-	//test any mux or api here
-}
\ No newline at end of file
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, args, err := config.Load(name, os.Args[2:], cmd.bind)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(2)
+	}
+
+	if err := logging.Setup(cfg.Logging); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(2)
+	}
+	ratelimit.Setup(cfg.RateLimit)
+	cache.Setup(cfg.Cache)
+	idempotency.Setup(cfg.Idempotency)
+	learningmiddlewares.Setup(cfg.CORS, cfg.Security)
+	testingstatefulapi.Setup(cfg.Accounts)
+
+	shutdownTracing, err := tracing.Setup(cfg.Tracing, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(2)
+	}
+
+	runErr := cmd.run(cfg, args)
+
+	// flush the spans still buffered by the exporter
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: flushing traces: %v\n", name, err)
+	}
+	cancel()
+
+	if runErr != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, runErr)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: go-dictionary <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'go-dictionary <command> -h' for the flags of a command.")
+	fmt.Fprintln(os.Stderr, "Every command accepts -config <file.yaml|file.toml|file.json> and GODICT_* environment variables.")
+}
+
+func runMux(cfg *config.Config, _ []string) error {
+	if cfg.Mux.Router == "httprouter" {
+		return othermux.RunHttpRouter(cfg.Mux, cfg.Lifecycle)
+	}
+	return othermux.RunGorilla(cfg.Mux, cfg.Lifecycle)
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"database/sql"
+)
+
+var (
+	dbOpen        = NewGaugeFunc("db_open_connections", "Established connections, in use and idle.", "db")
+	dbInUse       = NewGaugeFunc("db_in_use_connections", "Connections currently in use.", "db")
+	dbIdle        = NewGaugeFunc("db_idle_connections", "Idle connections in the pool.", "db")
+	dbMaxOpen     = NewGaugeFunc("db_max_open_connections", "Maximum number of open connections, 0 means unlimited.", "db")
+	dbWaitCount   = NewCounterFunc("db_wait_count_total", "Times a caller waited for a free connection.", "db")
+	dbWaitSeconds = NewCounterFunc("db_wait_duration_seconds_total", "Time spent waiting for a free connection.", "db")
+	dbIdleClosed  = NewCounterFunc("db_max_idle_closed_total", "Connections closed because of SetMaxIdleConns.", "db")
+	dbTimeClosed  = NewCounterFunc("db_max_idle_time_closed_total", "Connections closed because of SetConnMaxIdleTime.", "db")
+	dbLifeClosed  = NewCounterFunc("db_max_lifetime_closed_total", "Connections closed because of SetConnMaxLifetime.", "db")
+)
+
+// RegisterDB exposes the sql.DBStats pool statistics of db under the given name.
+// Registering a name again, after the database was reopened, replaces the old handle.
+func RegisterDB(name string, db *sql.DB) {
+	stat := func(read func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return read(db.Stats()) }
+	}
+
+	dbOpen.Set(stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }), name)
+	dbInUse.Set(stat(func(s sql.DBStats) float64 { return float64(s.InUse) }), name)
+	dbIdle.Set(stat(func(s sql.DBStats) float64 { return float64(s.Idle) }), name)
+	dbMaxOpen.Set(stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }), name)
+	dbWaitCount.Set(stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }), name)
+	dbWaitSeconds.Set(stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }), name)
+	dbIdleClosed.Set(stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }), name)
+	dbTimeClosed.Set(stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }), name)
+	dbLifeClosed.Set(stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }), name)
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/internal/recorder"
+	"github.com/emicklei/go-restful"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+)
+
+// Path is where every server exposes its metrics.
+const Path = "/metrics"
+
+var (
+	httpRequests = NewCounterVec("http_requests_total",
+		"HTTP requests served, by service, method, route template and status code.",
+		"service", "method", "route", "status")
+	httpDuration = NewHistogramVec("http_request_duration_seconds",
+		"Time spent serving HTTP requests, by service, method, route template and status code.",
+		nil, "service", "method", "route", "status")
+)
+
+// unmatched labels requests no route claimed, so unknown paths cannot blow up the number of series.
+const unmatched = "unmatched"
+
+type routeKey struct{}
+
+// SetRoute records the route template that matched r, for routers Middleware cannot inspect itself.
+// It is a no-op outside Middleware.
+func SetRoute(r *http.Request, route string) {
+	if holder, ok := r.Context().Value(routeKey{}).(*string); ok {
+		*holder = route
+	}
+}
+
+// Middleware counts and times the requests of one service. The route label is the template set
+// through SetRoute by the router adapters (MuxRoute, GinRoute, RestfulRoute), or else the
+// pattern http.ServeMux matched.
+func Middleware(service string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			route := new(string)
+			r = r.WithContext(context.WithValue(r.Context(), routeKey{}, route))
+
+			// a pattern already set belongs to an outer mux, such as the mount point of serve
+			outer := r.Pattern
+
+			rec := recorder.New(w)
+			next.ServeHTTP(rec, r)
+
+			label := *route
+			if label == "" && r.Pattern != outer {
+				label = r.Pattern
+			}
+			if label == "" {
+				label = unmatched
+			}
+
+			status := strconv.Itoa(rec.Status)
+			httpRequests.Inc(service, r.Method, label, status)
+			httpDuration.Observe(time.Since(start).Seconds(), service, r.Method, label, status)
+		})
+	}
+}
+
+// MuxRoute is a gorilla/mux middleware reporting the path template of the matched route.
+func MuxRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				SetRoute(r, template)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GinRoute is a Gin middleware reporting the full path of the matched route.
+func GinRoute(c *gin.Context) {
+	SetRoute(c.Request, c.FullPath())
+	c.Next()
+}
+
+// RestfulRoute is a go-restful web service filter reporting the path of the selected route.
+// It has to be added with WebService.Filter: container filters also run for requests no route matched.
+func RestfulRoute(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	SetRoute(req.Request, req.SelectedRoutePath())
+	chain.ProcessFilter(req, resp)
+}
+
+// RegisterServeMux mounts the metrics endpoint on a standard library mux.
+func RegisterServeMux(m *http.ServeMux) {
+	m.Handle("GET "+Path, Handler())
+}
+
+// RegisterMux mounts the metrics endpoint on a gorilla/mux router.
+func RegisterMux(r *mux.Router) {
+	r.Handle(Path, Handler()).Methods(http.MethodGet)
+}
+
+// RegisterGin mounts the metrics endpoint on a Gin router.
+func RegisterGin(r gin.IRoutes) {
+	r.GET(Path, gin.WrapH(Handler()))
+}
+
+// RegisterRestful mounts the metrics endpoint on a go-restful container.
+func RegisterRestful(container *restful.Container) {
+	container.Handle(Path, Handler())
+}
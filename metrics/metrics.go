@@ -0,0 +1,374 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the latency buckets in seconds used when a histogram is created without its own.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// family is one metric name with its HELP and TYPE lines.
+type family interface {
+	describe() (name, help, kind string)
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and renders them in the Prometheus text exposition format.
+type Registry struct {
+	mutex    sync.Mutex
+	families map[string]family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]family{}}
+}
+
+// Default is the registry the package level helpers and Handler use.
+var Default = NewRegistry()
+
+func (r *Registry) register(f family) {
+	name, _, _ := f.describe()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.families[name]; exists {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.families[name] = f
+}
+
+// WriteTo renders every family sorted by name.
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mutex.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	families := make([]family, len(names))
+	for i, name := range names {
+		families[i] = r.families[name]
+	}
+	r.mutex.Unlock()
+
+	counter := &countingWriter{w: out}
+	w := bufio.NewWriter(counter)
+	for _, f := range families {
+		name, help, kind := f.describe()
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+		f.write(w)
+	}
+	err := w.Flush()
+	return counter.n, err
+}
+
+// Handler serves the registry on GET /metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		r.WriteTo(w)
+	})
+}
+
+// Handler serves the default registry.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// series is the label values of one time series, keyed by their joined form.
+type series struct {
+	labels []string
+}
+
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func checkLabels(name string, names, values []string) {
+	if len(names) != len(values) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", name, len(names), len(values)))
+	}
+}
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	name, help string
+	labelNames []string
+
+	mutex  sync.Mutex
+	values map[string]*counterSeries
+}
+
+type counterSeries struct {
+	series
+	value float64
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labelNames...)
+}
+
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labelNames: labelNames, values: map[string]*counterSeries{}}
+	r.register(c)
+	return c
+}
+
+// Add increases the series identified by labelValues by delta, which must not be negative.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	checkLabels(c.name, c.labelNames, labelValues)
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.name))
+	}
+
+	key := seriesKey(labelValues)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s, ok := c.values[key]
+	if !ok {
+		s = &counterSeries{series: series{labels: append([]string(nil), labelValues...)}}
+		c.values[key] = s
+	}
+	s.value += delta
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) describe() (string, string, string) {
+	return c.name, c.help, "counter"
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		writeSample(w, c.name, c.labelNames, s.labels, "", "", s.value)
+	}
+}
+
+// HistogramVec counts observations into cumulative buckets, partitioned by label values.
+type HistogramVec struct {
+	name, help string
+	labelNames []string
+	buckets    []float64
+
+	mutex  sync.Mutex
+	values map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	series
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates a histogram on the default registry; nil buckets means DefBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labelNames...)
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{name: name, help: help, labelNames: labelNames, buckets: buckets, values: map[string]*histogramSeries{}}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	checkLabels(h.name, h.labelNames, labelValues)
+
+	key := seriesKey(labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSeries{series: series{labels: append([]string(nil), labelValues...)}, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+
+	// counts are stored per bucket and summed up when written
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) describe() (string, string, string) {
+	return h.name, h.help, "histogram"
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", h.labelNames, s.labels, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labelNames, s.labels, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labelNames, s.labels, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labelNames, s.labels, "", "", float64(s.count))
+	}
+}
+
+// GaugeFunc is a gauge whose series are read when the registry is scraped.
+type GaugeFunc struct {
+	name, help string
+	labelNames []string
+	kind       string
+
+	mutex   sync.Mutex
+	sources map[string]gaugeSource
+}
+
+type gaugeSource struct {
+	series
+	read func() float64
+}
+
+func NewGaugeFunc(name, help string, labelNames ...string) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, labelNames...)
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, labelNames ...string) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labelNames: labelNames, kind: "gauge", sources: map[string]gaugeSource{}}
+	r.register(g)
+	return g
+}
+
+// NewCounterFunc is a GaugeFunc exposed as a counter, for totals kept elsewhere such as sql.DBStats.
+func NewCounterFunc(name, help string, labelNames ...string) *GaugeFunc {
+	return Default.NewCounterFunc(name, help, labelNames...)
+}
+
+func (r *Registry) NewCounterFunc(name, help string, labelNames ...string) *GaugeFunc {
+	g := r.NewGaugeFunc(name, help, labelNames...)
+	g.kind = "counter"
+	return g
+}
+
+// Set registers read as the source of the series identified by labelValues, replacing any previous one.
+func (g *GaugeFunc) Set(read func() float64, labelValues ...string) {
+	checkLabels(g.name, g.labelNames, labelValues)
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.sources[seriesKey(labelValues)] = gaugeSource{series: series{labels: append([]string(nil), labelValues...)}, read: read}
+}
+
+func (g *GaugeFunc) describe() (string, string, string) {
+	return g.name, g.help, g.kind
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for _, key := range sortedKeys(g.sources) {
+		s := g.sources[key]
+		writeSample(w, g.name, g.labelNames, s.labels, "", "", s.read())
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeSample writes one line; extraName/extraValue add the le label of histogram buckets.
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+
+	if len(labelNames) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabel(labelValues[i]))
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
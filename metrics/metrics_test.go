@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// scrape serves h and returns its samples by series, with the HELP and TYPE lines
+// under "# HELP name" and "# TYPE name". It fails on a line that isn't either.
+func scrape(t *testing.T, h http.Handler) map[string]string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", Path, nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	samples := map[string]string{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if comment, ok := strings.CutPrefix(line, "# "); ok {
+			kind, rest, _ := strings.Cut(comment, " ")
+			name, text, _ := strings.Cut(rest, " ")
+			samples["# "+kind+" "+name] = text
+			continue
+		}
+		// the value follows the last space, label values may hold spaces
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("malformed line %q", line)
+		}
+		series, value := line[:i], line[i+1:]
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			t.Fatalf("line %q: value %q is not a number", line, value)
+		}
+		samples[series] = value
+	}
+	return samples
+}
+
+// want checks samples holds every series of expected with its value.
+func want(t *testing.T, samples map[string]string, expected map[string]string) {
+	t.Helper()
+	for series, value := range expected {
+		if got, ok := samples[series]; !ok {
+			t.Errorf("no %s in %v", series, samples)
+		} else if got != value {
+			t.Errorf("%s = %s, want %s", series, got, value)
+		}
+	}
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("jobs_total", "Jobs done.\nBy \\queue.", "queue")
+	c.Inc("mail")
+	c.Add(2.5, "mail")
+	c.Inc(`say "hi"`)
+	c.Inc(`back\slash`)
+	c.Inc("two\nlines")
+
+	want(t, scrape(t, r.Handler()), map[string]string{
+		"# HELP jobs_total":               `Jobs done.\nBy \\queue.`,
+		"# TYPE jobs_total":               "counter",
+		`jobs_total{queue="mail"}`:        "3.5",
+		`jobs_total{queue="say \"hi\""}`:  "1",
+		`jobs_total{queue="back\\slash"}`: "1",
+		`jobs_total{queue="two\nlines"}`:  "1",
+	})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("a counter going down did not panic")
+		}
+	}()
+	c.Add(-1, "mail")
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1, 0.5}, "route")
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		h.Observe(v, "/a")
+	}
+
+	// buckets are sorted and cumulative, a value on a bound counts in its bucket
+	want(t, scrape(t, r.Handler()), map[string]string{
+		"# TYPE latency_seconds":                       "histogram",
+		`latency_seconds_bucket{route="/a",le="0.1"}`:  "2",
+		`latency_seconds_bucket{route="/a",le="0.5"}`:  "3",
+		`latency_seconds_bucket{route="/a",le="1"}`:    "4",
+		`latency_seconds_bucket{route="/a",le="+Inf"}`: "5",
+		`latency_seconds_sum{route="/a"}`:              "3.15",
+		`latency_seconds_count{route="/a"}`:            "5",
+	})
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	open := 3.0
+	g := r.NewGaugeFunc("open_connections", "Open connections.", "db")
+	g.Set(func() float64 { return open }, "users")
+	r.NewCounterFunc("waits_total", "Waits.").Set(func() float64 { return math.Inf(1) })
+	unlabeled := r.NewGaugeFunc("up", "Up.")
+	unlabeled.Set(func() float64 { return 1 })
+
+	want(t, scrape(t, r.Handler()), map[string]string{
+		"# TYPE open_connections":      "gauge",
+		`open_connections{db="users"}`: "3",
+		"# TYPE waits_total":           "counter",
+		"waits_total":                  "+Inf",
+		"up":                           "1",
+	})
+
+	// read at every scrape, and replaced by a later Set
+	open = 5
+	want(t, scrape(t, r.Handler()), map[string]string{`open_connections{db="users"}`: "5"})
+	g.Set(func() float64 { return 7 }, "users")
+	want(t, scrape(t, r.Handler()), map[string]string{`open_connections{db="users"}`: "7"})
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("dup_total", "Dup.")
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	r.NewGaugeFunc("dup_total", "Dup.")
+}
+
+func TestMiddlewareRoute(t *testing.T) {
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	router := mux.NewRouter()
+	router.Use(MuxRoute)
+	router.HandleFunc("/things/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	tests := []struct {
+		service string
+		handler http.Handler
+		path    string
+		series  string
+	}{
+		{"test-servemux", serveMux, "/items/7", `http_requests_total{service="test-servemux",method="GET",route="GET /items/{id}",status="418"}`},
+		{"test-servemux", serveMux, "/nothing", `http_requests_total{service="test-servemux",method="GET",route="unmatched",status="404"}`},
+		{"test-gorilla", router, "/things/7", `http_requests_total{service="test-gorilla",method="GET",route="/things/{id}",status="200"}`},
+		{"test-gorilla", router, "/nothing", `http_requests_total{service="test-gorilla",method="GET",route="unmatched",status="404"}`},
+	}
+	for _, tt := range tests {
+		Middleware(tt.service)(tt.handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+	}
+
+	samples := scrape(t, Handler())
+	for _, tt := range tests {
+		want(t, samples, map[string]string{tt.series: "1"})
+	}
+	duration := `http_request_duration_seconds_count{service="test-gorilla",method="GET",route="/things/{id}",status="200"}`
+	want(t, samples, map[string]string{duration: "1"})
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"time"
+
+	gorillarpc "github.com/gorilla/rpc"
+)
+
+var (
+	rpcCalls = NewCounterVec("rpc_calls_total",
+		"RPC method calls, by server, method and outcome (ok or error).",
+		"server", "method", "status")
+	rpcDuration = NewHistogramVec("rpc_call_duration_seconds",
+		"Time spent in RPC method calls, by server and method.",
+		nil, "server", "method")
+)
+
+// ObserveRPC records one finished call of method on server.
+func ObserveRPC(server, method string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	rpcCalls.Inc(server, method, status)
+	rpcDuration.Observe(time.Since(start).Seconds(), server, method)
+}
+
+// ServerCodec wraps a net/rpc codec so every call it carries is counted and timed under server.
+// net/rpc has no hooks of its own; the codec sees each request header and its matching response.
+func ServerCodec(server string, codec rpc.ServerCodec) rpc.ServerCodec {
+	return &serverCodec{ServerCodec: codec, server: server, started: map[uint64]time.Time{}}
+}
+
+type serverCodec struct {
+	rpc.ServerCodec
+	server string
+
+	mutex   sync.Mutex
+	started map[uint64]time.Time
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.ServerCodec.ReadRequestHeader(r)
+	if err == nil {
+		c.mutex.Lock()
+		c.started[r.Seq] = time.Now()
+		c.mutex.Unlock()
+	}
+	return err
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.mutex.Lock()
+	start, ok := c.started[r.Seq]
+	delete(c.started, r.Seq)
+	c.mutex.Unlock()
+
+	if ok {
+		var callErr error
+		if r.Error != "" {
+			callErr = rpc.ServerError(r.Error)
+		}
+		ObserveRPC(c.server, r.ServiceMethod, start, callErr)
+	}
+	return c.ServerCodec.WriteResponse(r, body)
+}
+
+type rpcStartKey struct{}
+
+// InstrumentGorillaRPC counts and times the calls served by a gorilla/rpc server.
+// It takes over the server's intercept and after functions.
+func InstrumentGorillaRPC(server string, s *gorillarpc.Server) {
+	s.RegisterInterceptFunc(func(i *gorillarpc.RequestInfo) *http.Request {
+		return i.Request.WithContext(context.WithValue(i.Request.Context(), rpcStartKey{}, time.Now()))
+	})
+	s.RegisterAfterFunc(func(i *gorillarpc.RequestInfo) {
+		if start, ok := i.Request.Context().Value(rpcStartKey{}).(time.Time); ok {
+			ObserveRPC(server, i.Method, start, i.Error)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package negotiate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ugorji/go/codec"
+)
+
+type JSONEncoder struct{}
+
+func (JSONEncoder) ContentType() string { return MIMEJSON }
+
+func (JSONEncoder) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type XMLEncoder struct{}
+
+func (XMLEncoder) ContentType() string { return MIMEXML }
+
+func (XMLEncoder) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+var msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+
+type MsgPackEncoder struct{}
+
+func (MsgPackEncoder) ContentType() string { return MIMEMsgPack }
+
+func (MsgPackEncoder) Encode(w io.Writer, v interface{}) error {
+	return codec.NewEncoder(w, msgpackHandle).Encode(v)
+}
+
+// ErrNotTabular is returned by CSVEncoder for values that do not flatten into rows of columns.
+var ErrNotTabular = errors.New("value cannot be represented as CSV")
+
+// CSVEncoder writes a struct or a slice of structs as a header line followed by one line per struct.
+// Column names come from the json tags. A map with a single entry, such as the {"stations": [...]}
+// envelope the Gin handlers use, is unwrapped first.
+type CSVEncoder struct{}
+
+func (CSVEncoder) ContentType() string { return MIMECSV }
+
+func (CSVEncoder) Encode(w io.Writer, v interface{}) error {
+	value := unwrap(reflect.ValueOf(v))
+
+	var items []reflect.Value
+	switch value.Kind() {
+	case reflect.Struct:
+		items = []reflect.Value{value}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, unwrap(value.Index(i)))
+		}
+	default:
+		return ErrNotTabular
+	}
+
+	var elemType reflect.Type
+	if len(items) > 0 {
+		elemType = items[0].Type()
+	} else if value.Kind() != reflect.Struct {
+		elemType = value.Type().Elem()
+		for elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+	}
+	if elemType == nil || elemType.Kind() != reflect.Struct {
+		return ErrNotTabular
+	}
+
+	columns := csvColumns(elemType)
+
+	writer := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if item.Type() != elemType {
+			return ErrNotTabular
+		}
+
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			cell, err := csvCell(item.FieldByIndex(col.index))
+			if err != nil {
+				return err
+			}
+			record[i] = cell
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func unwrap(value reflect.Value) reflect.Value {
+	for {
+		switch value.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if value.IsNil() {
+				return value
+			}
+			value = value.Elem()
+		case reflect.Map:
+			if value.Len() != 1 {
+				return value
+			}
+			iter := value.MapRange()
+			iter.Next()
+			value = iter.Value()
+		default:
+			return value
+		}
+	}
+}
+
+type csvColumn struct {
+	name  string
+	index []int
+}
+
+func csvColumns(t reflect.Type) []csvColumn {
+	var columns []csvColumn
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		columns = append(columns, csvColumn{name: name, index: field.Index})
+	}
+	return columns
+}
+
+func csvCell(value reflect.Value) (string, error) {
+	if t, ok := value.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339), nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64), nil
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return "", nil
+		}
+		return csvCell(value.Elem())
+	}
+
+	// nested values are kept in a single cell as JSON
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return "", fmt.Errorf("encoding CSV cell: %w", err)
+	}
+	return string(data), nil
+}
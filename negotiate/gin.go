@@ -0,0 +1,27 @@
+package negotiate
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Gin writes v in the representation the request's Accept header asks for.
+// When no registered encoder is acceptable, or v cannot be encoded in the chosen one, it answers 406.
+func Gin(c *gin.Context, status int, v interface{}) {
+	enc, ok := Negotiate(c.GetHeader("Accept"))
+	if !ok {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "Not Acceptable", "available": MIMEs(), "request_id": logging.RequestID(c.Request.Context())})
+		return
+	}
+
+	var body bytes.Buffer
+	if err := enc.Encode(&body, v); err != nil {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error(), "available": MIMEs(), "request_id": logging.RequestID(c.Request.Context())})
+		return
+	}
+
+	c.Data(status, enc.ContentType(), body.Bytes())
+}
@@ -0,0 +1,173 @@
+package negotiate
+
+import (
+	"io"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	MIMEJSON    = "application/json"
+	MIMEXML     = "application/xml"
+	MIMECSV     = "text/csv"
+	MIMEMsgPack = "application/msgpack"
+)
+
+// Encoder writes a value in one media type. Encoders are shared by the go-restful and Gin services.
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+}
+
+var (
+	registryMutex = &sync.RWMutex{}
+	encoders      = map[string]Encoder{}
+	// offers keeps registration order; the first entry wins for */* and a missing Accept header
+	offers []string
+)
+
+func init() {
+	Register(JSONEncoder{})
+	Register(XMLEncoder{})
+	Register(CSVEncoder{})
+	Register(MsgPackEncoder{})
+	RegisterAlias("application/x-msgpack", MIMEMsgPack)
+	RegisterAlias("text/xml", MIMEXML)
+}
+
+// Register adds or replaces the encoder for its content type.
+func Register(enc Encoder) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	mediaType := enc.ContentType()
+	if _, exists := encoders[mediaType]; !exists {
+		offers = append(offers, mediaType)
+	}
+	encoders[mediaType] = enc
+}
+
+// RegisterAlias makes alias resolve to the encoder already registered for target.
+func RegisterAlias(alias, target string) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if enc, ok := encoders[target]; ok {
+		encoders[alias] = enc
+	}
+}
+
+// Lookup returns the encoder registered for the media type.
+func Lookup(mediaType string) (Encoder, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	enc, ok := encoders[mediaType]
+	return enc, ok
+}
+
+// MIMEs lists the media types that have an encoder, in preference order.
+func MIMEs() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	return append([]string(nil), offers...)
+}
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+	order     int
+}
+
+func (a acceptRange) specificity() int {
+	switch {
+	case a.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(a.mediaType, "/*"):
+		return 1
+	}
+	return 2
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+
+	for i, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality, order: i})
+	}
+	return ranges
+}
+
+func matches(rangeType, offer string) bool {
+	if rangeType == "*/*" || rangeType == offer {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(rangeType, "*"); ok {
+		return strings.HasPrefix(offer, prefix)
+	}
+	return false
+}
+
+// rangeFor returns the most specific range matching offer, which alone decides its quality:
+// in "*/*, application/xml;q=0" XML is not acceptable.
+func rangeFor(ranges []acceptRange, offer string) (acceptRange, bool) {
+	best, found := acceptRange{}, false
+	for _, r := range ranges {
+		if matches(r.mediaType, offer) && (!found || r.specificity() > best.specificity()) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// Negotiate picks the encoder that best satisfies an Accept header.
+// An empty header accepts anything. The boolean is false when nothing acceptable is registered.
+// Each offer takes the quality of the most specific range matching it; ties go to the more
+// specific range, then to the range listed first, then to the offer registered first.
+func Negotiate(accept string) (Encoder, bool) {
+	available := MIMEs()
+	if strings.TrimSpace(accept) == "" {
+		return Lookup(available[0])
+	}
+
+	ranges := parseAccept(accept)
+	// aliases are only offered when asked for by name
+	candidates := available
+	for _, r := range ranges {
+		if _, ok := Lookup(r.mediaType); ok && !slices.Contains(candidates, r.mediaType) {
+			candidates = append(candidates, r.mediaType)
+		}
+	}
+
+	best, bestRange, found := "", acceptRange{}, false
+	for _, offer := range candidates {
+		r, ok := rangeFor(ranges, offer)
+		if !ok || r.quality <= 0 {
+			continue
+		}
+		if !found || r.quality > bestRange.quality ||
+			(r.quality == bestRange.quality && (r.specificity() > bestRange.specificity() ||
+				(r.specificity() == bestRange.specificity() && r.order < bestRange.order))) {
+			best, bestRange, found = offer, r, true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	return Lookup(best)
+}
@@ -0,0 +1,43 @@
+package negotiate
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string // "" when nothing is acceptable
+	}{
+		{"", MIMEJSON},
+		{"*/*", MIMEJSON},
+		{"application/json", MIMEJSON},
+		{"application/xml", MIMEXML},
+		{"text/xml", MIMEXML},
+		{"text/csv", MIMECSV},
+		{"application/x-msgpack", MIMEMsgPack},
+		{"application/xml, application/json", MIMEXML},
+		{"application/json;q=0.5, application/xml", MIMEXML},
+		{"text/*", MIMECSV},
+		{"text/*;q=0.5, */*", MIMEJSON},
+		{"*/*;q=0.1, text/csv", MIMECSV},
+		{"image/png", ""},
+		{"application/json;q=0", ""},
+		{"*/*, application/json;q=0", MIMEXML},
+		{"*/*, application/xml;q=0", MIMEJSON},
+		{"*/*;q=0", ""},
+		{"*/*;q=0, text/csv", MIMECSV},
+		{"application/*, application/json;q=0, application/xml;q=0", MIMEMsgPack},
+		{"text/*;q=0, */*;q=0.5", MIMEJSON},
+		{"application/xml;q=0.8, */*;q=0.8", MIMEXML},
+		{"not a media type, text/csv", MIMECSV},
+	}
+	for _, tt := range tests {
+		enc, ok := Negotiate(tt.accept)
+		got := ""
+		if ok {
+			got = enc.ContentType()
+		}
+		if got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package negotiate
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"github.com/ugorji/go/codec"
+)
+
+// entityAccessor adapts an Encoder to go-restful's EntityReaderWriter.
+type entityAccessor struct {
+	encoder Encoder
+}
+
+// Read decodes request bodies for the formats that can carry entities in.
+func (a entityAccessor) Read(req *restful.Request, v interface{}) error {
+	switch a.encoder.ContentType() {
+	case MIMEMsgPack:
+		return codec.NewDecoder(req.Request.Body, msgpackHandle).Decode(v)
+	}
+	return fmt.Errorf("reading %s entities is not supported", a.encoder.ContentType())
+}
+
+// Write encodes into a buffer first so an unencodable value turns into a 406 instead of a truncated body.
+func (a entityAccessor) Write(resp *restful.Response, status int, v interface{}) error {
+	if v == nil {
+		resp.WriteHeader(status)
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := a.encoder.Encode(&body, v); err != nil {
+		resp.WriteHeader(http.StatusNotAcceptable)
+		_, writeErr := resp.Write([]byte(err.Error()))
+		if writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+
+	resp.Header().Set("Content-Type", a.encoder.ContentType())
+	resp.WriteHeader(status)
+	_, err := resp.Write(body.Bytes())
+	return err
+}
+
+// RegisterRestful installs the registered encoders as go-restful entity accessors.
+// JSON and XML keep go-restful's own accessors so existing responses stay byte-for-byte the same.
+// WebServices then only need Produces(negotiate.MIMEs()...) to honor the Accept header;
+// go-restful itself answers 406 when no produced type is acceptable.
+func RegisterRestful() {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	for mediaType, enc := range encoders {
+		if mediaType == restful.MIME_JSON || mediaType == restful.MIME_XML {
+			continue
+		}
+		restful.RegisterEntityAccessor(mediaType, entityAccessor{encoder: enc})
+	}
+}
@@ -1,12 +1,19 @@
 package othermux
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/tracing"
 	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
 )
 
 func ArticleHandler(w http.ResponseWriter, r *http.Request) {
@@ -16,9 +23,10 @@ func ArticleHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "ID is: %v\n", vars["id"])
 }
 
-func RunGorilla(port string) {
-
+// NewGorillaRouter returns the gorilla/mux router with the article routes.
+func NewGorillaRouter() *mux.Router {
 	r := mux.NewRouter()
+	r.Use(metrics.MuxRoute)
 
 	r.HandleFunc("/articles/{category}/{id:[0-9]+}", ArticleHandler)
 
@@ -26,19 +34,26 @@ func RunGorilla(port string) {
 		fmt.Fprintln(w, "Hello from the server")
 	})
 
+	return r
+}
+
+func RunGorilla(cfg config.Mux, lc config.Lifecycle) error {
+	m := lifecycle.New(lc)
 
-	address := port
-    if string(port[0]) != ":" {
-        address = ":" + port
-    }
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
+
+	r := NewGorillaRouter()
+	checker.RegisterMux(r)
+	metrics.RegisterMux(r)
 
 	srv := &http.Server{
-		Handler:      r,
-		Addr:         address,
+		Handler:      alice.New(tracing.Middleware("gorilla"), logging.Middleware, metrics.Middleware("gorilla")).Then(r),
+		Addr:         cfg.Addr,
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
 
-	fmt.Println("Server is listening on PORT:8000")
-	log.Fatal(srv.ListenAndServe())
-}
\ No newline at end of file
+	m.AddServer("gorilla", srv)
+	return m.Run(context.Background())
+}
@@ -1,13 +1,20 @@
 package othermux
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/exec"
 
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/tracing"
 	"github.com/julienschmidt/httprouter"
+	"github.com/justinas/alice"
 )
 
 func getCommandOutput(command string, arguments ...string) (string, error) {
@@ -31,22 +38,56 @@ func goVersion(w http.ResponseWriter, r *http.Request, params httprouter.Params)
 	}
 	fmt.Fprintln(w, output)
 }
-func getFileContent(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
-	output, err := os.ReadFile("./latin.txt")
-	if err != nil {
-		http.Error(w, "File not found or unreadable", 404)
+func showFile(path string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		output, err := os.ReadFile(path)
+		if err != nil {
+			http.Error(w, "File not found or unreadable", 404)
+			return
+		}
+		fmt.Fprintln(w, string(output))
 	}
-	fmt.Fprintln(w, string(output))
 }
 
-func RunHttpRouter(port string) {
+// route tags requests with the path they were registered on, httprouter does not expose the matched route.
+func route(path string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		metrics.SetRoute(r, path)
+		handle(w, r, params)
+	}
+}
+
+// NewHttpRouter returns the httprouter router. Static files are served from cfg.StaticDir when it is not empty.
+func NewHttpRouter(cfg config.Mux) *httprouter.Router {
 	router := httprouter.New()
-	router.ServeFiles("/static/*filepath", http.Dir("/Users/apple/Documents/static"))
+	if cfg.StaticDir != "" {
+		// same as router.ServeFiles, registered by hand so the route can be labelled
+		fileServer := http.FileServer(http.Dir(cfg.StaticDir))
+		router.GET("/static/*filepath", route("/static/*filepath", func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+			r.URL.Path = params.ByName("filepath")
+			fileServer.ServeHTTP(w, r)
+		}))
+	}
 	// Mapping to methods is possible with HttpRouter
-	router.GET("/api/v1/go-version", goVersion)
+	router.GET("/api/v1/go-version", route("/api/v1/go-version", goVersion))
 	// Path variable called name used here
-	router.GET("/api/v1/show-file", getFileContent)
+	router.GET("/api/v1/show-file", route("/api/v1/show-file", showFile(cfg.ShowFile)))
+
+	return router
+}
+
+func RunHttpRouter(cfg config.Mux, lc config.Lifecycle) error {
+	m := lifecycle.New(lc)
+
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
+
+	// httprouter has no adapter of its own, so the checks sit on a ServeMux in front of it
+	mux := http.NewServeMux()
+	mux.Handle("/", alice.New(tracing.Middleware("httprouter"), logging.Middleware, metrics.Middleware("httprouter")).Then(NewHttpRouter(cfg)))
+	checker.RegisterServeMux(mux)
+	metrics.RegisterServeMux(mux)
 
-	fmt.Printf("Server running on port %s", port)
-	log.Fatal(http.ListenAndServe(port, router))
+	m.AddServer("httprouter", &http.Server{Addr: cfg.Addr, Handler: mux})
+	return m.Run(context.Background())
 }
@@ -0,0 +1,91 @@
+package othermux
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Dav16Akin/go-dictionary/apitest"
+	"github.com/Dav16Akin/go-dictionary/config"
+)
+
+func TestMain(m *testing.M) {
+	apitest.Setup()
+	os.Exit(m.Run())
+}
+
+func TestGorillaRouter(t *testing.T) {
+	srv := apitest.NewServer(t, NewGorillaRouter())
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   string
+	}{
+		{"article", "/articles/books/42", 200, "Category is: books\nID is: 42\n"},
+		{"id must be numeric", "/articles/books/forty-two", 404, ""},
+		{"missing id", "/articles/books", 404, ""},
+		{"root", "/", 200, "Hello from the server\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := srv.Do(t, "GET", tt.path, nil).Status(t, tt.status)
+			if tt.want != "" && string(resp.Body) != tt.want {
+				t.Errorf("body = %q, want %q", resp.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestHttpRouter(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.txt"), []byte("static text"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	showFile := filepath.Join(dir, "show.txt")
+	if err := os.WriteFile(showFile, []byte("shown"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := apitest.NewServer(t, NewHttpRouter(config.Mux{StaticDir: dir, ShowFile: showFile}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		want   string
+	}{
+		{"static file", "GET", "/static/index.txt", 200, "static text"},
+		{"missing static file", "GET", "/static/other.txt", 404, ""},
+		{"show file", "GET", "/api/v1/show-file", 200, "shown\n"},
+		{"wrong method", "POST", "/api/v1/show-file", 405, ""},
+		{"unknown route", "GET", "/api/v2/show-file", 404, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := srv.Do(t, tt.method, tt.path, nil).Status(t, tt.status)
+			if tt.want != "" && string(resp.Body) != tt.want {
+				t.Errorf("body = %q, want %q", resp.Body, tt.want)
+			}
+		})
+	}
+
+	t.Run("go version", func(t *testing.T) {
+		resp := srv.Do(t, "GET", "/api/v1/go-version", nil).Status(t, 200)
+		if !strings.HasPrefix(string(resp.Body), "go version go") {
+			t.Errorf("body = %q, want go version output", resp.Body)
+		}
+	})
+
+	t.Run("without static files or a readable file", func(t *testing.T) {
+		bare := apitest.NewServer(t, NewHttpRouter(config.Mux{ShowFile: filepath.Join(dir, "missing.txt")}))
+		bare.Do(t, "GET", "/static/index.txt", nil).Status(t, 404)
+		resp := bare.Do(t, "GET", "/api/v1/show-file", nil).Status(t, 404)
+		if string(resp.Body) != "File not found or unreadable\n" {
+			t.Errorf("body = %q", resp.Body)
+		}
+	})
+}
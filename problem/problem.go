@@ -0,0 +1,48 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Dav16Akin/go-dictionary/logging"
+)
+
+// MIME is the media type of RFC 9457 (formerly RFC 7807) problem details.
+const MIME = "application/problem+json"
+
+// Details is a problem details object. Type stays about:blank unless a caller has a
+// page documenting the problem, in which case Title is the summary of that page.
+type Details struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// RequestID is an extension member matching the X-Request-ID header, for support requests.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// New describes a problem of the generic type for status.
+func New(r *http.Request, status int, detail string) Details {
+	return Details{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: logging.RequestID(r.Context()),
+	}
+}
+
+// Write sends d. Headers such as Retry-After must be set before calling it.
+func (d Details) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", MIME)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(d.Status)
+	json.NewEncoder(w).Encode(d)
+}
+
+// Write sends a generic problem for status.
+func Write(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	New(r, status, detail).Write(w)
+}
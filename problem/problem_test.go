@@ -0,0 +1,45 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dav16Akin/go-dictionary/logging"
+)
+
+func TestWrite(t *testing.T) {
+	handler := logging.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, http.StatusNotFound, "City Not Found")
+	}))
+
+	for _, incoming := range []string{"", "abc-123"} {
+		r := httptest.NewRequest("GET", "/city/9", nil)
+		if incoming != "" {
+			r.Header.Set(logging.HeaderRequestID, incoming)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if ct := w.Header().Get("Content-Type"); ct != MIME {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var d Details
+		if err := json.NewDecoder(w.Body).Decode(&d); err != nil {
+			t.Fatal(err)
+		}
+		id := w.Header().Get(logging.HeaderRequestID)
+		want := Details{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "City Not Found", Instance: "/city/9", RequestID: id}
+		if d != want || id == "" || (incoming != "" && id != incoming) {
+			t.Errorf("incoming ID %q: problem %+v with %s %q, want %+v", incoming, d, logging.HeaderRequestID, id, want)
+		}
+	}
+
+	// outside the logging middleware there is no ID to quote
+	w := httptest.NewRecorder()
+	Write(w, httptest.NewRequest("GET", "/", nil), http.StatusTeapot, "")
+	if body := w.Body.String(); body != `{"type":"about:blank","title":"I'm a teapot","status":418,"instance":"/"}`+"\n" {
+		t.Errorf("body = %s", body)
+	}
+}
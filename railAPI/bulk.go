@@ -0,0 +1,135 @@
+package railapi
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/emicklei/go-restful"
+
+	"github.com/Dav16Akin/go-dictionary/bulk"
+)
+
+var trainCSVHeader = []string{"id", "driver_name", "operating_status"}
+
+func trainFromCSV(record map[string]string) (TrainResource, error) {
+	var b TrainResource
+
+	b.DriverName = record["driver_name"]
+
+	if status := record["operating_status"]; status != "" {
+		operating, err := strconv.ParseBool(status)
+		if err != nil {
+			return b, errors.New("operating_status must be true or false")
+		}
+		b.OperatingStatus = operating
+	}
+
+	return b, nil
+}
+
+func trainToCSV(b TrainResource) []string {
+	return []string{strconv.Itoa(b.ID), b.DriverName, strconv.FormatBool(b.OperatingStatus)}
+}
+
+func insertTrain(tx *sql.Tx, b TrainResource) (int64, error) {
+	if b.DriverName == "" {
+		return 0, errors.New("driver_name is required")
+	}
+
+	result, err := tx.Exec("insert into train (DRIVER_NAME, OPERATING_STATUS) values (?,?)", b.DriverName, b.OperatingStatus)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// POST http://localhost:8000/v1/trains/import?mode=best-effort
+func (t *Train) importTrains(req *restful.Request, resp *restful.Response) {
+	mode, err := bulk.ParseMode(req.QueryParameter("mode"))
+	if err != nil {
+		resp.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format, err := bulk.FormatFromContentType(req.HeaderParameter("Content-Type"))
+	if err != nil {
+		resp.WriteErrorString(http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	rows, err := bulk.Decode(req.Request.Body, format, trainFromCSV)
+	if err != nil {
+		resp.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(rows) == 0 {
+		resp.WriteErrorString(http.StatusBadRequest, "No trains to import")
+		return
+	}
+
+	report, err := bulk.Import(req.Request.Context(), DB, mode, rows, insertTrain)
+	if err != nil {
+		slog.ErrorContext(req.Request.Context(), "Error importing trains", "err", err)
+		resp.WriteErrorString(http.StatusInternalServerError, "Could not import trains")
+		return
+	}
+
+	resp.WriteHeaderAndEntity(report.HTTPStatus(), report)
+}
+
+// GET http://localhost:8000/v1/trains/export?format=csv
+func (t *Train) exportTrains(req *restful.Request, resp *restful.Response) {
+	format, err := bulk.FormatForExport(req.QueryParameter("format"), req.HeaderParameter("Accept"))
+	if err != nil {
+		resp.WriteErrorString(http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	rows, err := DB.QueryContext(req.Request.Context(), "select ID, DRIVER_NAME, OPERATING_STATUS FROM train order by ID")
+	if err != nil {
+		slog.ErrorContext(req.Request.Context(), "Database error in exportTrains", "err", err)
+		resp.WriteErrorString(http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	defer rows.Close()
+
+	resp.AddHeader("Content-Type", format.ContentType())
+	resp.AddHeader("Content-Disposition", `attachment; filename="trains.`+string(format)+`"`)
+	resp.WriteHeader(http.StatusOK)
+
+	writer := bulk.NewWriter(resp, format, trainCSVHeader, trainToCSV)
+
+	for rows.Next() {
+		var train TrainResource
+		var driverName sql.NullString
+		var operatingStatus sql.NullBool
+
+		if err := rows.Scan(&train.ID, &driverName, &operatingStatus); err != nil {
+			slog.ErrorContext(req.Request.Context(), "Error scanning train", "err", err)
+			return
+		}
+
+		train.DriverName = driverName.String
+		train.OperatingStatus = operatingStatus.Bool
+
+		if err := writer.Write(train); err != nil {
+			slog.ErrorContext(req.Request.Context(), "Error writing train export", "err", err)
+			return
+		}
+	}
+
+	// the status line is already on the wire, so a late failure can only be logged
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(req.Request.Context(), "Row iteration error in exportTrains", "err", err)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		slog.ErrorContext(req.Request.Context(), "Error finishing train export", "err", err)
+	}
+}
@@ -1,8 +1,11 @@
 package dbutils
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"strings"
 )
 
 func Initialize(dbDriver *sql.DB) {
@@ -20,5 +23,66 @@ func Initialize(dbDriver *sql.DB) {
 	statement.Exec()
 	statement, _ = dbDriver.Prepare(schedule)
 	statement.Exec()
+	if err := migrateStationLocation(dbDriver); err != nil {
+		log.Printf("Error adding station locations : %v", err)
+	}
 	log.Println("All tables created/initialized successfully!")
 }
+
+// migrateStationLocation adds the coordinate columns to station tables created before they
+// existed, and builds the spatial index over them.
+func migrateStationLocation(dbDriver *sql.DB) error {
+	rows, err := dbDriver.Query("SELECT name FROM pragma_table_info('station')")
+	if err != nil {
+		return err
+	}
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[strings.ToUpper(name)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range []string{"LATITUDE", "LONGITUDE"} {
+		if columns[column] {
+			continue
+		}
+		if _, err := dbDriver.Exec("ALTER TABLE station ADD COLUMN " + column + " REAL NULL"); err != nil {
+			return err
+		}
+	}
+
+	for _, ddl := range append([]string{stationLocation}, stationLocationTriggers...) {
+		if _, err := dbDriver.Exec(ddl); err != nil {
+			return err
+		}
+	}
+
+	// stations located before the index existed, or while the triggers were missing
+	_, err = dbDriver.Exec(`INSERT OR REPLACE INTO station_location
+		SELECT ID, LATITUDE, LATITUDE, LONGITUDE, LONGITUDE FROM station
+		WHERE LATITUDE IS NOT NULL AND LONGITUDE IS NOT NULL`)
+	return err
+}
+
+// Migrated reports an error naming the first rail table that Initialize has not created yet.
+func Migrated(ctx context.Context, dbDriver *sql.DB) error {
+	for _, table := range []string{"train", "station", "schedule", "station_location"} {
+		var name string
+		err := dbDriver.QueryRowContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name=?", table).Scan(&name)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("table %s is missing", table)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
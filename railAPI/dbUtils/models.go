@@ -13,8 +13,10 @@ const station = `
 		ID INTEGER PRIMARY KEY AUTOINCREMENT,
 		NAME VARCHAR(64) NULL,
 		OPENING_TIME TIME NULL,
-		CLOSING_TIME TIME NULL
-	) 
+		CLOSING_TIME TIME NULL,
+		LATITUDE REAL NULL,
+		LONGITUDE REAL NULL
+	)
 `
 
 const schedule = `
@@ -27,3 +29,34 @@ const schedule = `
 		FOREIGN KEY (STATION_ID) REFERENCES station(ID)
 	)
 `
+
+// stationLocation indexes the station coordinates, stations without them are left out.
+const stationLocation = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS station_location USING rtree(
+		ID,
+		MIN_LAT, MAX_LAT,
+		MIN_LON, MAX_LON
+	)
+`
+
+// stationLocationTriggers keep station_location in step with every write to station,
+// whichever API or import makes it.
+var stationLocationTriggers = []string{`
+	CREATE TRIGGER IF NOT EXISTS station_location_insert AFTER INSERT ON station
+	WHEN NEW.LATITUDE IS NOT NULL AND NEW.LONGITUDE IS NOT NULL
+	BEGIN
+		INSERT INTO station_location VALUES (NEW.ID, NEW.LATITUDE, NEW.LATITUDE, NEW.LONGITUDE, NEW.LONGITUDE);
+	END
+`, `
+	CREATE TRIGGER IF NOT EXISTS station_location_update AFTER UPDATE OF LATITUDE, LONGITUDE ON station
+	BEGIN
+		DELETE FROM station_location WHERE ID = OLD.ID;
+		INSERT INTO station_location SELECT NEW.ID, NEW.LATITUDE, NEW.LATITUDE, NEW.LONGITUDE, NEW.LONGITUDE
+		WHERE NEW.LATITUDE IS NOT NULL AND NEW.LONGITUDE IS NOT NULL;
+	END
+`, `
+	CREATE TRIGGER IF NOT EXISTS station_location_delete AFTER DELETE ON station
+	BEGIN
+		DELETE FROM station_location WHERE ID = OLD.ID;
+	END
+`}
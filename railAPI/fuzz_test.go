@@ -0,0 +1,106 @@
+package railapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+
+	railapi "github.com/Dav16Akin/go-dictionary/railAPI"
+	"github.com/Dav16Akin/go-dictionary/railAPI/railtest"
+)
+
+// fuzzContainer serves the rail API from a database seeded with the network, shared by
+// every input of the fuzz target.
+func fuzzContainer(f *testing.F) *restful.Container {
+	db := railtest.NewDB(f)
+	db.SeedNetwork()
+	if err := railapi.Open(db.Path); err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { railapi.DB.Close() })
+	return railapi.NewContainer()
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// FuzzCreateTrain posts arbitrary bodies. Malformed ones must be refused with a 4xx, and an
+// accepted train must read back exactly as it was created.
+func FuzzCreateTrain(f *testing.F) {
+	for _, seed := range []string{
+		`{"driver_name":"Ada","operating_status":true}`,
+		`{"driver_name":"Grace"}`,
+		`{"driver_name":""}`,
+		`{"driver_name":"Ada","speed":300}`,
+		`{"driver_name":"é🚂","operating_status":false,"id":99}`,
+		`{"driver_name":"Ada"} trailing`,
+		`{"driver_name":1}`,
+		`{"driver_name":"Ada","operating_status":"yes"}`,
+		`[]`,
+		`null`,
+		``,
+		`{"driver_name":"` + "\x00\xff" + `"}`,
+	} {
+		f.Add([]byte(seed))
+	}
+	container := fuzzContainer(f)
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest("POST", "/v1/trains", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := serve(container, req)
+
+		switch {
+		case rec.Code >= 500:
+			t.Fatalf("POST %q = %d: %s", body, rec.Code, rec.Body)
+		case rec.Code != http.StatusCreated:
+			return
+		}
+
+		var created railapi.TrainResource
+		if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+			t.Fatalf("created train %s: %v", rec.Body, err)
+		}
+		if created.DriverName == "" {
+			t.Fatalf("POST %q created a train without a driver", body)
+		}
+
+		got := serve(container, httptest.NewRequest("GET", "/v1/trains/"+strconv.Itoa(created.ID), nil))
+		if got.Code != http.StatusOK {
+			t.Fatalf("GET created train %d = %d: %s", created.ID, got.Code, got.Body)
+		}
+		var read railapi.TrainResource
+		if err := json.Unmarshal(got.Body.Bytes(), &read); err != nil {
+			t.Fatal(err)
+		}
+		if read != created {
+			t.Fatalf("train read back as %+v, created as %+v", read, created)
+		}
+	})
+}
+
+// FuzzTrainID requests trains by arbitrary path parameters, which must never fail the server.
+func FuzzTrainID(f *testing.F) {
+	for _, seed := range []string{"1", "3", "0", "-1", "01", "1.5", "1e3", " 1", "abc", "9223372036854775808", "1 OR 1=1", "%", ""} {
+		f.Add(seed)
+	}
+	container := fuzzContainer(f)
+
+	f.Fuzz(func(t *testing.T, id string) {
+		for _, method := range []string{"GET", "DELETE"} {
+			rec := serve(container, httptest.NewRequest(method, "/v1/trains/"+url.PathEscape(id), nil))
+			if rec.Code >= 500 {
+				t.Fatalf("%s train %q = %d: %s", method, id, rec.Code, rec.Body)
+			}
+		}
+	})
+}
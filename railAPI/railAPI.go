@@ -1,27 +1,44 @@
 package railapi
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/emicklei/go-restful"
+	"github.com/justinas/alice"
 	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/Dav16Akin/go-dictionary/bulk"
+	"github.com/Dav16Akin/go-dictionary/cache"
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/idempotency"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/negotiate"
 	dbutils "github.com/Dav16Akin/go-dictionary/railAPI/dbUtils"
+	"github.com/Dav16Akin/go-dictionary/ratelimit"
+	"github.com/Dav16Akin/go-dictionary/tracing"
 )
 
 var DB *sql.DB
 
+// keys remembers the Idempotency-Key of train creations next to the trains themselves.
+var keys *idempotency.SQLiteStore
+
 type Train struct{}
 
 type TrainResource struct {
-	ID              int    `json:"id"`
-	DriverName      string `json:"driver_name"`
-	OperatingStatus bool   `json:"operating_status"`
+	ID              int    `json:"id" xml:"id"`
+	DriverName      string `json:"driver_name" xml:"driver_name"`
+	OperatingStatus bool   `json:"operating_status" xml:"operating_status"`
 }
 
 type StationResource struct {
@@ -29,6 +46,8 @@ type StationResource struct {
 	Name        string
 	OpeningTime time.Time
 	ClosingTime time.Time
+	Latitude    *float64
+	Longitude   *float64
 }
 
 type ScheduleResource struct {
@@ -41,28 +60,50 @@ type ScheduleResource struct {
 func (t *Train) Register(container *restful.Container) {
 	ws := new(restful.WebService)
 
-	/* with this we only entertain content-type application/json , if any other type is passed we will get a not supported media type error */
-	ws.Path("/v1/trains").Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+	// responses can be JSON, XML, CSV or MessagePack depending on the Accept header
+	negotiate.RegisterRestful()
 
+	/* with this we only entertain content-type application/json , if any other type is passed we will get a not supported media type error */
+	ws.Path("/v1/trains").Consumes(restful.MIME_JSON).Produces(negotiate.MIMEs()...)
+	ws.Filter(metrics.RestfulRoute)
+	ws.Filter(ratelimit.ForService("rail").Restful)
+	ws.Filter(cache.ForService("rail").Restful)
+	ws.Filter(idempotency.ForService("rail", keys).Restful)
+
+	ws.Route(ws.GET("/export").Produces(restful.MIME_JSON, bulk.MIMENDJSON, bulk.MIMECSV).To(t.exportTrains))
+	ws.Route(ws.POST("/import").Consumes(restful.MIME_JSON, bulk.MIMENDJSON, bulk.MIMECSV).Produces(restful.MIME_JSON).To(t.importTrains))
 	ws.Route(ws.GET("/{train-id}").To(t.getTrain))
 	ws.Route(ws.POST("").To(t.createTrain))
 	ws.Route(ws.DELETE("/{train-id}").To(t.removeTrain))
 	container.Add(ws)
 }
 
+// trainID reads the {train-id} path parameter, answering 400 itself when it is not a number.
+func trainID(req *restful.Request, resp *restful.Response) (int, bool) {
+	id, err := strconv.Atoi(req.PathParameter("train-id"))
+	if err != nil {
+		resp.WriteErrorString(http.StatusBadRequest, "Invalid train ID")
+		return 0, false
+	}
+	return id, true
+}
+
 // GET http://localhost:8000/v1/trains/1
 func (t *Train) getTrain(req *restful.Request, resp *restful.Response) {
-	id := req.PathParameter("train-id")
+	id, ok := trainID(req, resp)
+	if !ok {
+		return
+	}
 
 	var train TrainResource
 
-	err := DB.QueryRow("select ID, DRIVER_NAME, OPERATING_STATUS FROM train where id=?", id).Scan(&train.ID, &train.DriverName, &train.OperatingStatus)
+	err := DB.QueryRowContext(req.Request.Context(), "select ID, DRIVER_NAME, OPERATING_STATUS FROM train where id=?", id).Scan(&train.ID, &train.DriverName, &train.OperatingStatus)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
 			resp.WriteErrorString(http.StatusNotFound, "Train could not be found")
 		} else {
-			log.Printf("Database error in getTrain : %v", err)
+			slog.ErrorContext(req.Request.Context(), "Database error in getTrain", "err", err)
 			resp.WriteErrorString(http.StatusInternalServerError, "Internal server error")
 		}
 		return
@@ -79,7 +120,7 @@ func (t *Train) createTrain(req *restful.Request, resp *restful.Response) {
 	decoder.DisallowUnknownFields()
 
 	if err := decoder.Decode(&b); err != nil {
-		log.Println("Invalid json", err)
+		slog.WarnContext(req.Request.Context(), "Invalid json", "err", err)
 		resp.WriteErrorString(http.StatusBadRequest, "Invalid JSON body")
 		return
 	}
@@ -89,25 +130,25 @@ func (t *Train) createTrain(req *restful.Request, resp *restful.Response) {
 		return
 	}
 
-	statement, err := DB.Prepare("insert into train (DRIVER_NAME, OPERATING_STATUS) values (?,?)")
+	statement, err := DB.PrepareContext(req.Request.Context(), "insert into train (DRIVER_NAME, OPERATING_STATUS) values (?,?)")
 	if err != nil {
-		log.Printf("Error preparing statement : %v", err)
+		slog.ErrorContext(req.Request.Context(), "Error preparing statement", "err", err)
 		resp.WriteErrorString(http.StatusInternalServerError, "Database Error")
 		return
 	}
 
 	defer statement.Close()
 
-	result, err := statement.Exec(b.DriverName, b.OperatingStatus)
+	result, err := statement.ExecContext(req.Request.Context(), b.DriverName, b.OperatingStatus)
 	if err != nil {
-		log.Printf("Error executing insert : %v", err)
+		slog.ErrorContext(req.Request.Context(), "Error executing insert", "err", err)
 		resp.WriteErrorString(http.StatusInternalServerError, "Could not save train")
 		return
 	}
 
 	newID, err := result.LastInsertId()
 	if err != nil {
-		log.Printf("Error getting last insert ID: %v", err)
+		slog.ErrorContext(req.Request.Context(), "Error getting last insert ID", "err", err)
 		resp.WriteErrorString(http.StatusInternalServerError, "Database Error")
 		return
 	}
@@ -118,27 +159,30 @@ func (t *Train) createTrain(req *restful.Request, resp *restful.Response) {
 }
 
 func (t *Train) removeTrain(req *restful.Request, resp *restful.Response) {
-	id := req.PathParameter("train-id")
+	id, ok := trainID(req, resp)
+	if !ok {
+		return
+	}
 
-	statement, err := DB.Prepare("delete from train where ID=?")
+	statement, err := DB.PrepareContext(req.Request.Context(), "delete from train where ID=?")
 	if err != nil {
-		log.Printf("Error removing train : %v", err)
+		slog.ErrorContext(req.Request.Context(), "Error removing train", "err", err)
 		resp.WriteErrorString(http.StatusInternalServerError, "Database error")
 		return
 	}
 
 	defer statement.Close()
 
-	result, err := statement.Exec(id)
+	result, err := statement.ExecContext(req.Request.Context(), id)
 	if err != nil {
-		log.Printf("delete exec error: %v", err)
+		slog.ErrorContext(req.Request.Context(), "delete exec error", "err", err)
 		resp.WriteErrorString(http.StatusInternalServerError, "Could not delete train")
 		return
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("rowsAffected error: %v", err)
+		slog.ErrorContext(req.Request.Context(), "rowsAffected error", "err", err)
 		resp.WriteErrorString(http.StatusInternalServerError, "Database error")
 		return
 	}
@@ -151,27 +195,65 @@ func (t *Train) removeTrain(req *restful.Request, resp *restful.Response) {
 	resp.WriteHeader(http.StatusNoContent)
 }
 
-func RunRailGoRestfulAPI() {
+// Open connects to the SQLite database at dbPath and makes sure the rail tables exist.
+func Open(dbPath string) error {
 	var err error
-	DB, err = sql.Open("sqlite3", "./railapi.db")
+	// statements run with a request context show up as spans of the request's trace
+	DB, err = tracing.OpenDB("sqlite3", dbPath)
 	if err != nil {
-		log.Printf("Error Opening Database : %v", err)
+		return fmt.Errorf("opening database: %w", err)
 	}
 
 	// Always good practice to check if the DB is actually reachable
 	if err = DB.Ping(); err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
+		return fmt.Errorf("connecting to database: %w", err)
 	}
 
 	dbutils.Initialize(DB)
+	if keys, err = idempotency.NewSQLiteStore(DB); err != nil {
+		return err
+	}
+	metrics.RegisterDB("rail", DB)
+	return nil
+}
+
+// NewContainer returns a go-restful container with every rail web service registered.
+func NewContainer() *restful.Container {
 	wsContainer := restful.NewContainer()
 	wsContainer.Router(restful.CurlyRouter{})
+	wsContainer.Filter(logging.RestfulFilter)
 
 	t := Train{}
 	t.Register(wsContainer)
 
-	fmt.Println("Server is running on PORT 8000...")
+	return wsContainer
+}
+
+// AddHealthChecks registers the rail database checks on a readiness checker.
+func AddHealthChecks(checker *health.Checker) {
+	checker.AddReadinessCheck("rail database", health.DBPing(DB))
+	checker.AddReadinessCheck("rail migrations", func(ctx context.Context) error {
+		return dbutils.Migrated(ctx, DB)
+	})
+}
+
+func RunRailGoRestfulAPI(cfg config.Rail, lc config.Lifecycle) error {
+	if err := Open(cfg.DBPath); err != nil {
+		return err
+	}
+
+	m := lifecycle.New(lc)
+
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
+	AddHealthChecks(checker)
+
+	container := NewContainer()
+	checker.RegisterRestful(container)
+	metrics.RegisterRestful(container)
+
+	m.AddServer("rail", &http.Server{Addr: cfg.Addr, Handler: alice.New(tracing.Middleware("rail"), metrics.Middleware("rail")).Then(container)})
+	m.AddCloser("rail database", DB)
 
-	server := &http.Server{Addr: ":8000", Handler: wsContainer}
-	log.Fatal(server.ListenAndServe())
+	return m.Run(context.Background())
 }
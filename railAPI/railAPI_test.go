@@ -0,0 +1,198 @@
+package railapi_test
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Dav16Akin/go-dictionary/apitest"
+	railapi "github.com/Dav16Akin/go-dictionary/railAPI"
+	"github.com/Dav16Akin/go-dictionary/railAPI/railtest"
+)
+
+func TestMain(m *testing.M) {
+	apitest.Setup()
+	os.Exit(m.Run())
+}
+
+// newServer serves the rail API from db.
+func newServer(t *testing.T, db *railtest.DB) *apitest.Server {
+	t.Helper()
+	if err := railapi.Open(db.Path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { railapi.DB.Close() })
+	return apitest.NewServer(t, railapi.NewContainer())
+}
+
+func TestTrains(t *testing.T) {
+	srv := newServer(t, railtest.NewDB(t))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		status int
+		want   string
+		error  string
+	}{
+		{"create", "POST", "/v1/trains", map[string]any{"driver_name": "Ada", "operating_status": true}, 201,
+			`{"id":1,"driver_name":"Ada","operating_status":true}`, ""},
+		{"create another", "POST", "/v1/trains", map[string]any{"driver_name": "Grace"}, 201,
+			`{"id":2,"driver_name":"Grace","operating_status":false}`, ""},
+		{"create without driver", "POST", "/v1/trains", map[string]any{"operating_status": true}, 400, "", "driver_name is required"},
+		{"create with unknown field", "POST", "/v1/trains", map[string]any{"driver_name": "Ada", "speed": 300}, 400, "", "Invalid JSON body"},
+		{"get", "GET", "/v1/trains/1", nil, 200, `{"id":1,"driver_name":"Ada","operating_status":true}`, ""},
+		{"get missing", "GET", "/v1/trains/9", nil, 404, "", "Train could not be found"},
+		{"delete", "DELETE", "/v1/trains/2", nil, 204, "", ""},
+		{"delete again", "DELETE", "/v1/trains/2", nil, 404, "", "Train not found"},
+		{"get deleted", "GET", "/v1/trains/2", nil, 404, "", "Train could not be found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := srv.Do(t, tt.method, tt.path, tt.body).Status(t, tt.status)
+			if tt.want != "" {
+				resp.JSON(t, tt.want)
+			}
+			if tt.error != "" && string(resp.Body) != tt.error {
+				t.Errorf("body = %q, want %q", resp.Body, tt.error)
+			}
+		})
+	}
+
+	t.Run("form body", func(t *testing.T) {
+		srv.Do(t, "POST", "/v1/trains", "driver_name=Ada", "Content-Type", "application/x-www-form-urlencoded").Status(t, 415)
+	})
+	t.Run("xml", func(t *testing.T) {
+		resp := srv.Do(t, "GET", "/v1/trains/1", nil, "Accept", "application/xml").Status(t, 200)
+		if !strings.Contains(string(resp.Body), "<driver_name>Ada</driver_name>") {
+			t.Errorf("body = %s, want XML", resp.Body)
+		}
+	})
+	t.Run("idempotent create", func(t *testing.T) {
+		body := map[string]any{"driver_name": "Linus"}
+		first := srv.Do(t, "POST", "/v1/trains", body, "Idempotency-Key", "train-3").Status(t, 201)
+		again := srv.Do(t, "POST", "/v1/trains", body, "Idempotency-Key", "train-3").Status(t, 201)
+		again.JSON(t, string(first.Body))
+		if again.Header.Get("Idempotent-Replayed") != "true" {
+			t.Error("retry was not replayed")
+		}
+		srv.Do(t, "GET", "/v1/trains/4", nil).Status(t, 404)
+	})
+}
+
+func TestTrainBulk(t *testing.T) {
+	srv := newServer(t, railtest.NewDB(t))
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		status      int
+		want        string
+	}{
+		{"json", "", "application/json", `[{"driver_name":"Ada","operating_status":true},{"driver_name":"Grace"}]`, 201,
+			`{"mode":"all-or-nothing","committed":true,"total":2,"succeeded":2,"failed":0,"rows":[
+				{"row":1,"id":1,"status":"created"},{"row":2,"id":2,"status":"created"}]}`},
+		{"all or nothing", "", "application/x-ndjson", "{\"driver_name\":\"Linus\"}\n{\"driver_name\":\"\"}\n", 422,
+			`{"mode":"all-or-nothing","committed":false,"total":2,"succeeded":0,"failed":1,"rows":[
+				{"row":1,"status":"rolled_back"},{"row":2,"status":"failed","error":"driver_name is required"}]}`},
+		{"best effort", "?mode=best-effort", "text/csv", "driver_name,operating_status\nLinus,true\nKen,maybe\n", 207,
+			`{"mode":"best-effort","committed":true,"total":2,"succeeded":1,"failed":1,"rows":[
+				{"row":2,"id":3,"status":"created"},{"row":3,"status":"failed","error":"operating_status must be true or false"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Do(t, "POST", "/v1/trains/import"+tt.query, tt.body, "Content-Type", tt.contentType).Status(t, tt.status).JSON(t, tt.want)
+		})
+	}
+
+	for _, tt := range []struct {
+		name, query, contentType, body string
+		status                         int
+	}{
+		{"unknown mode", "?mode=some", "application/json", `[{"driver_name":"Ada"}]`, 400},
+		{"empty", "", "application/json", `[]`, 400},
+		{"malformed", "", "application/json", `[{`, 400},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.Do(t, "POST", "/v1/trains/import"+tt.query, tt.body, "Content-Type", tt.contentType).Status(t, tt.status)
+		})
+	}
+
+	exports := []struct {
+		name        string
+		query       string
+		accept      string
+		status      int
+		contentType string
+		want        string
+	}{
+		{"json", "", "", 200, "application/json",
+			`[{"id":1,"driver_name":"Ada","operating_status":true},{"id":2,"driver_name":"Grace","operating_status":false},{"id":3,"driver_name":"Linus","operating_status":true}]`},
+		{"csv by query", "?format=csv", "", 200, "text/csv",
+			"id,driver_name,operating_status\n1,Ada,true\n2,Grace,false\n3,Linus,true\n"},
+		{"ndjson by accept", "", "application/x-ndjson", 200, "application/x-ndjson",
+			"{\"id\":1,\"driver_name\":\"Ada\",\"operating_status\":true}\n{\"id\":2,\"driver_name\":\"Grace\",\"operating_status\":false}\n{\"id\":3,\"driver_name\":\"Linus\",\"operating_status\":true}\n"},
+		{"unknown format", "?format=xlsx", "", 406, "", ""},
+	}
+	for _, tt := range exports {
+		t.Run("export "+tt.name, func(t *testing.T) {
+			var header []string
+			if tt.accept != "" {
+				header = []string{"Accept", tt.accept}
+			}
+			resp := srv.Do(t, "GET", "/v1/trains/export"+tt.query, nil, header...).Status(t, tt.status)
+			if tt.contentType == "" {
+				return
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if tt.contentType == "application/json" {
+				resp.JSON(t, tt.want)
+			} else if string(resp.Body) != tt.want {
+				t.Errorf("body = %q, want %q", resp.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	db := railtest.NewDB(t)
+	network := db.SeedNetwork()
+	srv := newServer(t, db)
+
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+	}{
+		{"train", "/v1/trains/" + strconv.Itoa(network.Southbound.ID), ""},
+		{"spare train", "/v1/trains/" + strconv.Itoa(network.Spare.ID), ""},
+		{"export", "/v1/trains/export", ""},
+		{"export csv", "/v1/trains/export?format=csv", ""},
+		{"export ndjson", "/v1/trains/export", "application/x-ndjson"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header []string
+			if tt.accept != "" {
+				header = []string{"Accept", tt.accept}
+			}
+			resp := srv.Do(t, "GET", tt.path, nil, header...).Status(t, 200)
+			railtest.Golden(t, strings.ReplaceAll(tt.name, " ", "-"), resp.Body)
+		})
+	}
+
+	var stops int
+	if err := db.QueryRow("select count(*) from schedule where TRAIN_ID = ?", network.Northbound.ID).Scan(&stops); err != nil {
+		t.Fatal(err)
+	}
+	if stops != 4 {
+		t.Errorf("northbound train has %d stops, want 4", stops)
+	}
+}
@@ -0,0 +1,46 @@
+package railtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the responses the tests got")
+
+// Golden fails the test unless got matches testdata/name.golden in the package under test.
+// JSON is indented before it is compared and written, so the files stay readable and diff well.
+// Run the tests with -update to write the files from what the handlers answer now.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	var indented bytes.Buffer
+	if json.Valid(got) {
+		if err := json.Indent(&indented, bytes.TrimSpace(got), "", "  "); err != nil {
+			t.Fatalf("indenting %s: %v", name, err)
+		}
+		indented.WriteByte('\n')
+		got = indented.Bytes()
+	}
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s, run the tests with -update to create it: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response differs from %s, run the tests with -update if the change is intended\n got:\n%s\nwant:\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package railtest
+
+import (
+	railapi "github.com/Dav16Akin/go-dictionary/railAPI"
+)
+
+// Network is the Lagos–Ibadan line: four located stations, a station still being built,
+// a train each way with a timetable, and a spare train that is out of service.
+type Network struct {
+	Lagos, Agege, Abeokuta, Ibadan, Kajola railapi.StationResource
+	Northbound, Southbound, Spare          railapi.TrainResource
+	// Timetable holds the northbound stops and then the southbound ones, in the order they are called at.
+	Timetable []railapi.ScheduleResource
+}
+
+// SeedNetwork inserts the Network. Into a fresh NewDB the IDs always come out the same, stations
+// and trains numbered from 1 in the order of the Network fields, so golden files can show them.
+func (db *DB) SeedNetwork() Network {
+	db.t.Helper()
+	named := func(name string) func(*railapi.StationResource) {
+		return func(s *railapi.StationResource) { s.Name = name }
+	}
+	hours := func(opening, closing int) func(*railapi.StationResource) {
+		return func(s *railapi.StationResource) { s.OpeningTime, s.ClosingTime = At(opening, 0), At(closing, 0) }
+	}
+
+	n := Network{
+		Lagos:    db.Station(named("Mobolaji Johnson"), Located(6.4845, 3.38), hours(5, 23)),
+		Agege:    db.Station(named("Babatunde Raji Fashola"), Located(6.618, 3.3209), hours(5, 22)),
+		Abeokuta: db.Station(named("Professor Wole Soyinka"), Located(7.1475, 3.3619), hours(6, 21)),
+		Ibadan:   db.Station(named("Obafemi Awolowo"), Located(7.3775, 3.947), hours(5, 22)),
+		Kajola:   db.Station(named("Kajola"), hours(7, 19)),
+	}
+
+	driver := func(name string, operating bool) func(*railapi.TrainResource) {
+		return func(t *railapi.TrainResource) { t.DriverName, t.OperatingStatus = name, operating }
+	}
+	n.Northbound = db.Train(driver("Adaeze Okafor", true))
+	n.Southbound = db.Train(driver("Tunde Bakare", true))
+	n.Spare = db.Train(driver("Musa Ibrahim", false))
+
+	stop := func(train railapi.TrainResource, station railapi.StationResource, hour, minute int) {
+		n.Timetable = append(n.Timetable, db.Schedule(func(s *railapi.ScheduleResource) {
+			s.TrainID, s.StationID, s.ArrivalTime = train.ID, station.ID, At(hour, minute)
+		}))
+	}
+	stop(n.Northbound, n.Lagos, 8, 0)
+	stop(n.Northbound, n.Agege, 8, 20)
+	stop(n.Northbound, n.Abeokuta, 9, 15)
+	stop(n.Northbound, n.Ibadan, 10, 10)
+	stop(n.Southbound, n.Ibadan, 16, 0)
+	stop(n.Southbound, n.Abeokuta, 16, 55)
+	stop(n.Southbound, n.Agege, 17, 50)
+	stop(n.Southbound, n.Lagos, 18, 10)
+	return n
+}
@@ -0,0 +1,126 @@
+package railtest
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	railapi "github.com/Dav16Akin/go-dictionary/railAPI"
+	dbutils "github.com/Dav16Akin/go-dictionary/railAPI/dbUtils"
+)
+
+// ServiceDay is the date the factories and the network put their times on, the schema stores
+// whole timestamps for what are times of day.
+var ServiceDay = time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC)
+
+// At returns hour:minute on the ServiceDay.
+func At(hour, minute int) time.Time {
+	return ServiceDay.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+// seq numbers the default names, so rows made by one test never look like each other.
+var seq atomic.Int64
+
+// DB is a rail database in a temporary file, with the schema already applied. The services open
+// it again from Path, rows inserted here are visible to them.
+type DB struct {
+	*sql.DB
+	Path string
+	t    testing.TB
+}
+
+// NewDB creates an empty rail database that is removed when the test ends.
+func NewDB(t testing.TB) *DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "railapi.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	dbutils.Initialize(db)
+	if err := dbutils.Migrated(t.Context(), db); err != nil {
+		t.Fatalf("creating the rail schema: %v", err)
+	}
+	return &DB{DB: db, Path: path, t: t}
+}
+
+// Train inserts a train and returns it with its ID. It operates, with a driver named after
+// its number, unless the overrides say otherwise.
+func (db *DB) Train(overrides ...func(*railapi.TrainResource)) railapi.TrainResource {
+	db.t.Helper()
+	train := railapi.TrainResource{
+		DriverName:      fmt.Sprintf("Driver %d", seq.Add(1)),
+		OperatingStatus: true,
+	}
+	for _, override := range overrides {
+		override(&train)
+	}
+
+	train.ID = db.insert("insert into train (DRIVER_NAME, OPERATING_STATUS) values (?, ?)", train.DriverName, train.OperatingStatus)
+	return train
+}
+
+// Station inserts a station and returns it with its ID. It opens from 05:00 to 23:00 and has
+// no location unless the overrides say otherwise.
+func (db *DB) Station(overrides ...func(*railapi.StationResource)) railapi.StationResource {
+	db.t.Helper()
+	station := railapi.StationResource{
+		Name:        fmt.Sprintf("Station %d", seq.Add(1)),
+		OpeningTime: At(5, 0),
+		ClosingTime: At(23, 0),
+	}
+	for _, override := range overrides {
+		override(&station)
+	}
+
+	station.ID = db.insert("insert into station (NAME, OPENING_TIME, CLOSING_TIME, LATITUDE, LONGITUDE) values (?, ?, ?, ?, ?)",
+		station.Name, station.OpeningTime, station.ClosingTime, station.Latitude, station.Longitude)
+	return station
+}
+
+// Schedule inserts a stop and returns it with its ID. A new train and station are made for
+// it when the overrides leave TrainID or StationID zero, and it arrives at 08:00 by default.
+func (db *DB) Schedule(overrides ...func(*railapi.ScheduleResource)) railapi.ScheduleResource {
+	db.t.Helper()
+	schedule := railapi.ScheduleResource{ArrivalTime: At(8, 0)}
+	for _, override := range overrides {
+		override(&schedule)
+	}
+	if schedule.TrainID == 0 {
+		schedule.TrainID = db.Train().ID
+	}
+	if schedule.StationID == 0 {
+		schedule.StationID = db.Station().ID
+	}
+
+	schedule.ID = db.insert("insert into schedule (TRAIN_ID, STATION_ID, ARRIVAL_TIME) values (?, ?, ?)",
+		schedule.TrainID, schedule.StationID, schedule.ArrivalTime)
+	return schedule
+}
+
+// Located is a Station override placing it at lat, lon.
+func Located(lat, lon float64) func(*railapi.StationResource) {
+	return func(s *railapi.StationResource) {
+		s.Latitude, s.Longitude = &lat, &lon
+	}
+}
+
+func (db *DB) insert(query string, args ...any) int {
+	db.t.Helper()
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		db.t.Fatalf("%s: %v", query, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		db.t.Fatalf("%s: %v", query, err)
+	}
+	return int(id)
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"time"
+)
+
+// bucket is a token bucket: it holds up to burst tokens, refilled at rate tokens per second,
+// and every request takes one.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Decision is the outcome of one request against its bucket.
+type Decision struct {
+	Allowed bool
+	// Limit is the burst size, the most requests a client can make at once.
+	Limit int
+	// Remaining is how many requests may follow right away.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, zero when it already is.
+	RetryAfter time.Duration
+}
+
+// take refills b for the time elapsed since it was last used and tries to take a token.
+func (b *bucket) take(now time.Time, rate float64, burst int) Decision {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(burst), b.tokens+elapsed*rate)
+	}
+	b.last = now
+
+	d := Decision{Limit: burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((float64(burst) - b.tokens) / rate)
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// lru keeps the buckets of the most recently seen keys. A client whose bucket was evicted
+// starts over with a full one, so max should stay well above the number of clients seen
+// while an empty bucket refills.
+type lru struct {
+	max   int
+	order *list.List // front is the most recently used
+	items map[string]*list.Element
+}
+
+type entry struct {
+	key    string
+	bucket bucket
+}
+
+func newLRU(max int) *lru {
+	return &lru{max: max, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get returns the bucket of key, creating a full one if the key is unknown.
+func (l *lru) get(key string, now time.Time, burst int) *bucket {
+	if element, ok := l.items[key]; ok {
+		l.order.MoveToFront(element)
+		return &element.Value.(*entry).bucket
+	}
+
+	if l.order.Len() >= l.max {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*entry).key)
+	}
+
+	e := &entry{key: key, bucket: bucket{tokens: float64(burst), last: now}}
+	l.items[key] = l.order.PushFront(e)
+	return &e.bucket
+}
+
+func (l *lru) len() int {
+	return l.order.Len()
+}
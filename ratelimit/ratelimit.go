@@ -0,0 +1,220 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/problem"
+	"github.com/emicklei/go-restful"
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderAPIKey identifies a client when limiting by API key.
+const HeaderAPIKey = "X-API-Key"
+
+var (
+	rejected = metrics.NewCounterVec("rate_limit_rejected_total",
+		"Requests refused with 429 Too Many Requests, by service.", "service")
+	buckets = metrics.NewGaugeFunc("rate_limit_buckets", "Token buckets held in memory, by service.", "service")
+)
+
+// KeyFunc names the bucket a request is counted against. route is the route template when
+// the adapter knows it, or else the path.
+type KeyFunc func(r *http.Request, route string) string
+
+// ByIP gives every client address its own bucket. With trustProxy the first address of
+// X-Forwarded-For is used, which clients can forge unless a proxy in front overwrites it.
+func ByIP(trustProxy bool) KeyFunc {
+	return func(r *http.Request, route string) string {
+		return "ip:" + clientIP(r, trustProxy)
+	}
+}
+
+// ByAPIKey gives each of keys its own bucket. Requests with another key, or none, are limited
+// by IP: a made-up key would otherwise get a full bucket of its own.
+func ByAPIKey(trustProxy bool, keys []string) KeyFunc {
+	byIP := ByIP(trustProxy)
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key] = true
+	}
+	return func(r *http.Request, route string) string {
+		if key := r.Header.Get(HeaderAPIKey); known[key] {
+			return "key:" + key
+		}
+		return byIP(r, route)
+	}
+}
+
+// ByRoute shares one bucket between all clients of an endpoint, to protect what is behind it.
+func ByRoute(r *http.Request, route string) string {
+	return "route:" + r.Method + " " + route
+}
+
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Limiter throttles the requests of one service. A nil *Limiter lets everything through,
+// so the adapters can be wired in unconditionally and switched off in the configuration.
+type Limiter struct {
+	service string
+	rate    float64
+	burst   int
+	key     KeyFunc
+
+	mutex   sync.Mutex
+	buckets *lru
+}
+
+// New returns a limiter allowing rate requests per second and bursts of burst requests
+// to each key, keeping at most maxKeys buckets.
+func New(service string, rate float64, burst int, key KeyFunc, maxKeys int) *Limiter {
+	l := &Limiter{
+		service: service,
+		rate:    rate,
+		burst:   burst,
+		key:     key,
+		buckets: newLRU(maxKeys),
+	}
+	buckets.Set(func() float64 {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		return float64(l.buckets.len())
+	}, service)
+	return l
+}
+
+var (
+	policyMutex sync.RWMutex
+	policy      = config.Default().RateLimit
+)
+
+// Setup sets the policy the limiters returned by ForService follow.
+func Setup(cfg config.RateLimit) {
+	policyMutex.Lock()
+	defer policyMutex.Unlock()
+	policy = cfg
+}
+
+// ForService returns a limiter following the configured policy, or nil when rate limiting is disabled.
+func ForService(service string) *Limiter {
+	policyMutex.RLock()
+	cfg := policy
+	policyMutex.RUnlock()
+
+	if !cfg.Enabled {
+		return nil
+	}
+
+	var key KeyFunc
+	switch cfg.Key {
+	case "api-key":
+		key = ByAPIKey(cfg.TrustProxy, cfg.APIKeys)
+	case "route":
+		key = ByRoute
+	default:
+		key = ByIP(cfg.TrustProxy)
+	}
+	return New(service, cfg.Rate, cfg.Burst, key, cfg.MaxKeys)
+}
+
+// Allow counts a request against the bucket of key.
+func (l *Limiter) Allow(key string) Decision {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	return l.buckets.get(key, now, l.burst).take(now, l.rate, l.burst)
+}
+
+// check counts r and answers for it when the limit is exceeded, reporting whether to go on.
+// The RateLimit-* headers follow draft-ietf-httpapi-ratelimit-headers.
+func (l *Limiter) check(w http.ResponseWriter, r *http.Request, route string) bool {
+	d := l.Allow(l.key(r, route))
+
+	// the window is how long an empty bucket takes to fill up
+	header := w.Header()
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", l.burst, ceilSeconds(seconds(float64(l.burst)/l.rate))))
+	header.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+
+	if d.Allowed {
+		return true
+	}
+
+	rejected.Inc(l.service)
+	retry := ceilSeconds(d.RetryAfter)
+	header.Set("Retry-After", strconv.Itoa(retry))
+	problem.Write(w, r, http.StatusTooManyRequests, fmt.Sprintf("rate limit of %d requests exceeded, retry in %d s", l.burst, retry))
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Middleware limits the requests reaching next. It fits an alice chain as l.Middleware.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.Pattern
+		if route == "" {
+			route = r.URL.Path
+		}
+		if l.check(w, r, route) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Restful does what Middleware does, as a go-restful filter keyed by route template.
+// It has to be added with WebService.Filter: container filters also run for requests no route matched.
+func (l *Limiter) Restful(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	if l == nil {
+		chain.ProcessFilter(req, resp)
+		return
+	}
+	if l.check(resp, req.Request, req.SelectedRoutePath()) {
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+// Gin does what Middleware does, as Gin middleware.
+func (l *Limiter) Gin(c *gin.Context) {
+	if l == nil {
+		c.Next()
+		return
+	}
+
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	if !l.check(c.Writer, c.Request, route) {
+		c.Abort()
+	}
+}
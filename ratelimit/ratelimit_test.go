@@ -0,0 +1,188 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBucketRefill(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	// 2 tokens per second, bursts of 3
+	b := bucket{tokens: 3, last: start}
+
+	for i := range 3 {
+		if d := b.take(start, 2, 3); !d.Allowed || d.Remaining != 2-i {
+			t.Fatalf("request %d: %+v, want allowed with %d remaining", i+1, d, 2-i)
+		}
+	}
+
+	d := b.take(start, 2, 3)
+	if d.Allowed {
+		t.Fatal("fourth request of the burst allowed")
+	}
+	if d.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 500ms", d.RetryAfter)
+	}
+	if d.Reset != 1500*time.Millisecond {
+		t.Errorf("Reset = %v, want 1.5s", d.Reset)
+	}
+
+	// half a second brings back one token
+	if d := b.take(start.Add(500*time.Millisecond), 2, 3); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("after 500ms: %+v, want allowed with 0 remaining", d)
+	}
+
+	// an idle client never gets more than the burst
+	if d := b.take(start.Add(time.Hour), 2, 3); !d.Allowed || d.Remaining != 2 {
+		t.Errorf("after an hour: %+v, want allowed with 2 remaining", d)
+	}
+
+	// a clock going backwards refills nothing
+	b = bucket{tokens: 0, last: start}
+	if d := b.take(start.Add(-time.Second), 2, 3); d.Allowed {
+		t.Error("allowed after the clock went backwards")
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newLRU(2)
+
+	a := l.get("a", now, 5)
+	a.tokens = 1
+	l.get("b", now, 5)
+	// a becomes the most recently used, so c evicts b
+	l.get("a", now, 5)
+	l.get("c", now, 5)
+
+	if l.len() != 2 {
+		t.Fatalf("len = %d, want 2", l.len())
+	}
+	if _, ok := l.items["b"]; ok {
+		t.Error("b was kept, the least recently used key must go")
+	}
+	if got := l.get("a", now, 5).tokens; got != 1 {
+		t.Errorf("a has %v tokens, want its bucket kept with 1", got)
+	}
+
+	// an evicted key starts over with a full bucket
+	if got := l.get("b", now, 5).tokens; got != 5 {
+		t.Errorf("b has %v tokens, want a full bucket of 5", got)
+	}
+	if _, ok := l.items["c"]; ok {
+		t.Error("c was kept after b came back")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l := New("test", 1, 2, ByIP(false), 10)
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := range 2 {
+		w := request("192.0.2.1:1234")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != strconv.Itoa(1-i) {
+			t.Errorf("request %d: RateLimit-Remaining = %s, want %d", i+1, got, 1-i)
+		}
+	}
+
+	w := request("192.0.2.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "2;w=2" {
+		t.Errorf("RateLimit-Policy = %q, want 2;w=2", got)
+	}
+
+	// another address has its own bucket
+	if w := request("192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("other client: status %d", w.Code)
+	}
+}
+
+func TestMiddlewareAPIKeys(t *testing.T) {
+	l := New("test", 1, 1, ByAPIKey(false, []string{"secret"}), 10)
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(key string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set(HeaderAPIKey, key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// a new made-up key on every request does not buy a new bucket
+	if code := request("made-up-1"); code != http.StatusOK {
+		t.Fatalf("first request: status %d", code)
+	}
+	if code := request("made-up-2"); code != http.StatusTooManyRequests {
+		t.Errorf("second made-up key from the same address: status %d, want 429", code)
+	}
+
+	// a configured key has its own bucket
+	if code := request("secret"); code != http.StatusOK {
+		t.Errorf("configured key: status %d", code)
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	r := httptest.NewRequest("POST", "/v1/trains", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+
+	tests := []struct {
+		name string
+		key  KeyFunc
+		want string
+	}{
+		{"ip", ByIP(false), "ip:192.0.2.1"},
+		{"ip behind proxy", ByIP(true), "ip:203.0.113.7"},
+		{"api key missing", ByAPIKey(false, []string{"secret"}), "ip:192.0.2.1"},
+		{"route", ByRoute, "route:POST /v1/trains"},
+	}
+	for _, tt := range tests {
+		if got := tt.key(r, "/v1/trains"); got != tt.want {
+			t.Errorf("%s: key %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	byKey := ByAPIKey(false, []string{"secret", "other"})
+	r.Header.Set(HeaderAPIKey, "secret")
+	if got := byKey(r, ""); got != "key:secret" {
+		t.Errorf("api key: key %q, want key:secret", got)
+	}
+
+	// a key nobody was given is counted against the client's address, like no key at all
+	r.Header.Set(HeaderAPIKey, "made-up")
+	if got := byKey(r, ""); got != "ip:192.0.2.1" {
+		t.Errorf("unknown api key: key %q, want ip:192.0.2.1", got)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	called := false
+	l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !called {
+		t.Error("a nil limiter must let requests through")
+	}
+}
@@ -1,51 +1,117 @@
 package rpcClient
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
-	"net/rpc"
-	"os"
+	"net/http"
+	"time"
 
+	"github.com/Dav16Akin/go-dictionary/config"
 	rpcserver "github.com/Dav16Akin/go-dictionary/rpcServer"
+	"github.com/Dav16Akin/go-dictionary/tracing"
+	gjson "github.com/gorilla/rpc/json"
 )
 
-func Client() {
-	command := ""
-    if len(os.Args) > 1 {
-        command = os.Args[1]
-    }
-
-    switch command {
-    case "client":
-        // --- CLIENT MODE ---
-        // Run this manually: go run main.go client
-        runClient()
+// caller is what the client needs of a connection. *rpc.Client is one, TimeClient wraps it in
+// contextCaller; the contract tests record the calls through another.
+type caller interface {
+	Call(serviceMethod string, args any, reply any) error
+}
 
-    case "server":
-        // --- SERVER MODE ---
-        // You can run this explicitly: go run main.go server
-        rpcserver.Run()
+// Book is what the client reads of a JSON-RPC book reply.
+type Book struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Author string `json:"author"`
+}
 
-    default:
-        // --- DEFAULT (AIR) MODE ---
-        // If no args are provided (which is what Air does), run the server!
-        fmt.Println("No command provided, defaulting to RPC Server for Air...")
-        rpcserver.Run()
-    }
+// bookArgs are the params of JSONServer.GiveBookDetail.
+type bookArgs struct {
+	ID string `json:"Id"`
 }
 
-func runClient() {
+func serverTime(ctx context.Context, c caller) (int64, error) {
 	var reply int64
-	args := &rpcserver.Args{}
-	client, err := rpc.DialHTTP("tcp", "localhost"+":1234")
+	args := &rpcserver.Args{TraceParent: tracing.Traceparent(ctx)}
+	if err := c.Call("TimeServer.GiveServerTime", args, &reply); err != nil {
+		// a call given up on may still write reply later
+		return 0, err
+	}
+	return reply, nil
+}
+
+func bookDetail(c caller, id string) (Book, error) {
+	var reply Book
+	err := c.Call("JSONServer.GiveBookDetail", &bookArgs{ID: id}, &reply)
+	return reply, err
+}
+
+// jsonRPCClient calls the methods of a gorilla JSON-RPC server, one HTTP POST per call.
+type jsonRPCClient struct {
+	ctx context.Context
+	url string
+}
+
+func (c jsonRPCClient) Call(serviceMethod string, args any, reply any) error {
+	body, err := gjson.EncodeClientRequest(serviceMethod, args)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := tracing.Client.Do(req)
 	if err != nil {
-		log.Fatal("dialing: ", err)
+		return err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", c.url, resp.Status)
+	}
+	return gjson.DecodeClientResponse(resp.Body, reply)
+}
 
-	err = client.Call("TimeServer.GiveServerTime", args, &reply)
+// Run asks the RPC server at cfg.Addr for its time. cfg.Path is the HTTP path the server
+// is mounted on, rpc.DefaultRPCPath unless it sits behind a prefix.
+// With cfg.Book it looks the book up on the JSON-RPC book server instead.
+func Run(cfg config.RPCClient) error {
+	if cfg.Book != "" {
+		return runBook(cfg)
+	}
+
+	client := NewTimeClient(cfg)
+	defer client.Close()
 
+	now, err := client.ServerTime(context.Background())
 	if err != nil {
-		log.Fatal("arith error: ", err)
+		return fmt.Errorf("time error: %w", err)
+	}
+	log.Printf("%d", now.Unix())
+	return nil
+}
+
+func runBook(cfg config.RPCClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout))
+	defer cancel()
+
+	ctx, span := tracing.Start(ctx, "JSONServer.GiveBookDetail", tracing.KindClient)
+	defer span.End()
+	span.SetAttribute("rpc.system", "jsonrpc")
+	span.SetAttribute("net.peer.name", cfg.Addr)
+
+	book, err := bookDetail(jsonRPCClient{ctx: ctx, url: "http://" + cfg.Addr + cfg.Path}, cfg.Book)
+	if err != nil {
+		return fmt.Errorf("book error: %w", err)
+	}
+	if book.ID == "" {
+		log.Printf("no book with ID %q", cfg.Book)
+		return nil
 	}
-	log.Printf("%d", reply)
+	log.Printf("%s by %s", book.Name, book.Author)
+	return nil
 }
@@ -0,0 +1,27 @@
+package rpcClient
+
+import (
+	"testing"
+
+	"github.com/Dav16Akin/go-dictionary/contract"
+)
+
+// The contracts pin down what this client expects of the servers it calls; the server tests
+// verify them. Changing a call here fails the test until the contract is rewritten with
+// -update-contracts, which makes the change show up in the servers' tests and in review.
+
+func TestTimeServerContract(t *testing.T) {
+	recorder := contract.NewRecorder(t, "rpc-client", "rpc-server", contract.NetRPC)
+	if _, err := serverTime(t.Context(), recorder); err != nil {
+		t.Fatal(err)
+	}
+	recorder.Check("../contracts/rpc-client.rpc-server.json")
+}
+
+func TestJSONServerContract(t *testing.T) {
+	recorder := contract.NewRecorder(t, "rpc-client", "jsonrpc", contract.JSONRPC)
+	if _, err := bookDetail(recorder, "1"); err != nil {
+		t.Fatal(err)
+	}
+	recorder.Check("../contracts/rpc-client.jsonrpc.json")
+}
@@ -0,0 +1,217 @@
+package rpcClient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/rpc"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/tracing"
+)
+
+// ErrClosed is returned by calls on a TimeClient after Close.
+var ErrClosed = errors.New("time client closed")
+
+// connected is the handshake reply of a net/rpc server mounted on an HTTP path.
+const connected = "200 Connected to Go RPC"
+
+// TimeClient asks a TimeServer for its time. Calls are spread over cfg.PoolSize connections,
+// dialed when first needed; net/rpc multiplexes concurrent calls on each of them. A connection
+// that breaks is dropped and the call retried on a new one, waiting longer before every retry.
+// A TimeClient is safe for concurrent use.
+type TimeClient struct {
+	cfg    config.RPCClient
+	conns  []conn
+	next   atomic.Uint32
+	closed atomic.Bool
+}
+
+// conn is one slot of the pool, empty until dialed and again after it broke.
+type conn struct {
+	mutex  sync.Mutex
+	client *rpc.Client
+}
+
+// NewTimeClient returns a client for the TimeServer at cfg.Addr, mounted on cfg.Path. It does
+// not dial until the first call.
+func NewTimeClient(cfg config.RPCClient) *TimeClient {
+	return &TimeClient{cfg: cfg, conns: make([]conn, max(cfg.PoolSize, 1))}
+}
+
+// ServerTime asks the server for its time. The call, retries included, gives up after
+// cfg.Timeout or when ctx is done, whichever comes first. Errors the server returns are not
+// retried.
+func (c *TimeClient) ServerTime(ctx context.Context) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.cfg.Timeout))
+	defer cancel()
+
+	ctx, span := tracing.Start(ctx, "TimeServer.GiveServerTime", tracing.KindClient)
+	defer span.End()
+	span.SetAttribute("rpc.system", "net/rpc")
+	span.SetAttribute("net.peer.name", c.cfg.Addr)
+
+	for attempt := 0; ; attempt++ {
+		span.SetAttribute("rpc.attempts", strconv.Itoa(attempt+1))
+
+		reply, err := c.call(ctx)
+		if err == nil {
+			return time.Unix(reply, 0), nil
+		}
+		var serverErr rpc.ServerError
+		if errors.As(err, &serverErr) || errors.Is(err, ErrClosed) {
+			return time.Time{}, err
+		}
+		if ctx.Err() != nil {
+			return time.Time{}, fmt.Errorf("TimeServer.GiveServerTime: %w", ctx.Err())
+		}
+		if attempt == c.cfg.Retries {
+			return time.Time{}, fmt.Errorf("TimeServer.GiveServerTime: giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		timer := time.NewTimer(c.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return time.Time{}, fmt.Errorf("TimeServer.GiveServerTime: %w (last error: %v)", ctx.Err(), err)
+		}
+	}
+}
+
+// call makes one attempt on the next connection of the pool, dropping the connection unless
+// the server answered.
+func (c *TimeClient) call(ctx context.Context) (int64, error) {
+	if c.closed.Load() {
+		return 0, ErrClosed
+	}
+	slot := &c.conns[int(c.next.Add(1))%len(c.conns)]
+	client, err := c.get(ctx, slot)
+	if err != nil {
+		return 0, err
+	}
+
+	reply, err := serverTime(ctx, contextCaller{ctx: ctx, client: client})
+	var serverErr rpc.ServerError
+	if err != nil && !errors.As(err, &serverErr) {
+		// after a timeout the reply may still come, or never; either way the connection is
+		// not worth keeping
+		slot.drop(client)
+		// a call cut off by Close fails like the calls after it
+		if c.closed.Load() {
+			return 0, ErrClosed
+		}
+	}
+	return reply, err
+}
+
+// get returns the connection of slot, dialing it if there is none.
+func (c *TimeClient) get(ctx context.Context, slot *conn) (*rpc.Client, error) {
+	slot.mutex.Lock()
+	defer slot.mutex.Unlock()
+	if slot.client != nil {
+		return slot.client, nil
+	}
+
+	client, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Close may have gone over this slot between the check in call and the lock above, and would
+	// never see the new connection
+	if c.closed.Load() {
+		client.Close()
+		return nil, ErrClosed
+	}
+	slot.client = client
+	return client, nil
+}
+
+// drop closes client and empties slot, unless another call already replaced it.
+func (slot *conn) drop(client *rpc.Client) {
+	slot.mutex.Lock()
+	if slot.client == client {
+		slot.client = nil
+	}
+	slot.mutex.Unlock()
+	client.Close()
+}
+
+// dial does what rpc.DialHTTPPath does, but gives up when ctx is done.
+func (c *TimeClient) dial(ctx context.Context) (*rpc.Client, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("dialing: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+
+	io.WriteString(nc, "CONNECT "+c.cfg.Path+" HTTP/1.0\n\n")
+	resp, err := http.ReadResponse(bufio.NewReader(nc), &http.Request{Method: http.MethodConnect})
+	if err == nil && resp.Status != connected {
+		err = fmt.Errorf("unexpected HTTP response: %s", resp.Status)
+	}
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("dialing %s%s: %w", c.cfg.Addr, c.cfg.Path, err)
+	}
+	nc.SetDeadline(time.Time{})
+	return rpc.NewClient(nc), nil
+}
+
+// backoff is how long to wait before retry attempt+1: cfg.Backoff doubled attempt times, capped
+// at cfg.MaxBackoff, of which a random half is taken off so clients cut off together do not
+// all come back at once.
+func (c *TimeClient) backoff(attempt int) time.Duration {
+	d := time.Duration(c.cfg.Backoff)
+	for i := 0; i < attempt && d < time.Duration(c.cfg.MaxBackoff); i++ {
+		d *= 2
+	}
+	d = min(d, time.Duration(c.cfg.MaxBackoff))
+	return d/2 + rand.N(d/2+1)
+}
+
+// Close closes the connections. Calls in flight, those still dialing included, and later ones
+// fail with ErrClosed.
+func (c *TimeClient) Close() error {
+	c.closed.Store(true)
+	var errs []error
+	for i := range c.conns {
+		slot := &c.conns[i]
+		slot.mutex.Lock()
+		if slot.client != nil {
+			errs = append(errs, slot.client.Close())
+			slot.client = nil
+		}
+		slot.mutex.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// contextCaller is a caller whose calls give up when ctx is done. A reply arriving later is
+// decoded into a value nobody reads any more.
+type contextCaller struct {
+	ctx    context.Context
+	client *rpc.Client
+}
+
+func (c contextCaller) Call(serviceMethod string, args any, reply any) error {
+	call := c.client.Go(serviceMethod, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
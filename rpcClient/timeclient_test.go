@@ -0,0 +1,272 @@
+package rpcClient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	rpcserver "github.com/Dav16Akin/go-dictionary/rpcServer"
+)
+
+// server serves handler on a loopback port and keeps the connections it accepted, so a test
+// can cut them the way a restarting server or a dropped network would.
+type server struct {
+	net.Listener
+	accepted atomic.Int32
+	mutex    sync.Mutex
+	conns    []net.Conn
+}
+
+func newServer(t *testing.T, handler http.Handler) *server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{Listener: l}
+	go http.Serve(s, handler)
+	t.Cleanup(func() {
+		s.Close()
+		s.cut()
+	})
+	return s
+}
+
+func (s *server) Accept() (net.Conn, error) {
+	c, err := s.Listener.Accept()
+	if err == nil {
+		s.accepted.Add(1)
+		s.mutex.Lock()
+		s.conns = append(s.conns, c)
+		s.mutex.Unlock()
+	}
+	return c, err
+}
+
+// cut closes every connection accepted so far.
+func (s *server) cut() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func testConfig(addr string) config.RPCClient {
+	cfg := config.Default().RPCClient
+	cfg.Addr = addr
+	cfg.Path = rpc.DefaultRPCPath
+	cfg.Timeout = config.Duration(2 * time.Second)
+	cfg.Backoff = config.Duration(10 * time.Millisecond)
+	cfg.MaxBackoff = config.Duration(40 * time.Millisecond)
+	return cfg
+}
+
+func TestServerTime(t *testing.T) {
+	s := newServer(t, rpcserver.NewHandler())
+	client := NewTimeClient(testConfig(s.Addr().String()))
+	defer client.Close()
+
+	before := time.Now().Truncate(time.Second)
+	now, err := client.ServerTime(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if now.Before(before) || now.After(time.Now()) {
+		t.Errorf("server time %v, want about %v", now, before)
+	}
+}
+
+func TestServerTimeReconnects(t *testing.T) {
+	s := newServer(t, rpcserver.NewHandler())
+	cfg := testConfig(s.Addr().String())
+	cfg.PoolSize = 1
+	client := NewTimeClient(cfg)
+	defer client.Close()
+
+	if _, err := client.ServerTime(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	s.cut()
+	if _, err := client.ServerTime(t.Context()); err != nil {
+		t.Fatalf("after the connection was cut: %v", err)
+	}
+	if n := s.accepted.Load(); n != 2 {
+		t.Errorf("%d connections dialed, want 2", n)
+	}
+}
+
+func TestServerTimeGivesUp(t *testing.T) {
+	s := newServer(t, rpcserver.NewHandler())
+	cfg := testConfig(s.Addr().String())
+	cfg.Retries = 2
+	s.Close()
+
+	client := NewTimeClient(cfg)
+	defer client.Close()
+
+	start := time.Now()
+	_, err := client.ServerTime(t.Context())
+	if err == nil {
+		t.Fatal("call to a closed port succeeded")
+	}
+	// two retries wait at least half of 10ms and of 20ms
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("gave up after %v without backing off", elapsed)
+	}
+}
+
+func TestServerTimeTimeout(t *testing.T) {
+	// a server that takes the connection and never answers
+	hang := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.0 "+connected+"\n\n")
+		io.Copy(io.Discard, conn)
+	})
+	s := newServer(t, hang)
+	cfg := testConfig(s.Addr().String())
+	cfg.Timeout = config.Duration(100 * time.Millisecond)
+	client := NewTimeClient(cfg)
+	defer client.Close()
+
+	start := time.Now()
+	_, err := client.ServerTime(t.Context())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("timed out after %v", elapsed)
+	}
+}
+
+func TestServerTimePool(t *testing.T) {
+	s := newServer(t, rpcserver.NewHandler())
+	cfg := testConfig(s.Addr().String())
+	cfg.PoolSize = 3
+	client := NewTimeClient(cfg)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			if _, err := client.ServerTime(t.Context()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if n := s.accepted.Load(); n != 3 {
+		t.Errorf("%d connections dialed, want 3", n)
+	}
+}
+
+func TestServerTimeClosed(t *testing.T) {
+	s := newServer(t, rpcserver.NewHandler())
+	client := NewTimeClient(testConfig(s.Addr().String()))
+	if _, err := client.ServerTime(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ServerTime(t.Context()); !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+}
+
+// stall is a server that takes a connection, waits for release before completing the net/rpc
+// handshake and then never answers. called is closed when a call arrives, done when the client
+// closed the connection.
+type stall struct {
+	release, called, done chan struct{}
+}
+
+func newStall() *stall {
+	return &stall{release: make(chan struct{}), called: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (st *stall) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	<-st.release
+	io.WriteString(conn, "HTTP/1.0 "+connected+"\n\n")
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		close(st.called)
+	}
+	io.Copy(io.Discard, conn)
+	close(st.done)
+}
+
+// serverTimeAsync starts a call and returns where its error will be sent.
+func serverTimeAsync(t *testing.T, client *TimeClient) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.ServerTime(t.Context())
+		result <- err
+	}()
+	return result
+}
+
+func TestServerTimeClosedInFlight(t *testing.T) {
+	st := newStall()
+	close(st.release)
+	s := newServer(t, st)
+	client := NewTimeClient(testConfig(s.Addr().String()))
+
+	result := serverTimeAsync(t, client)
+	<-st.called
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+	<-st.done
+}
+
+func TestServerTimeClosedWhileDialing(t *testing.T) {
+	st := newStall()
+	s := newServer(t, st)
+	client := NewTimeClient(testConfig(s.Addr().String()))
+
+	result := serverTimeAsync(t, client)
+	for s.accepted.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Close waits for the slot being dialed, the handshake finishes once it marked the client closed
+	closed := make(chan error, 1)
+	go func() { closed <- client.Close() }()
+	for !client.closed.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	close(st.release)
+
+	if err := <-result; !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	// the connection dialed for the call was closed rather than kept in the pool
+	select {
+	case <-st.done:
+	case <-time.After(time.Second):
+		t.Error("connection dialed during Close was left open")
+	}
+}
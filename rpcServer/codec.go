@@ -0,0 +1,92 @@
+package rpcserver
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/rpc"
+
+	"github.com/Dav16Akin/go-dictionary/metrics"
+)
+
+// connected is the handshake reply rpc.DialHTTP waits for.
+const connected = "200 Connected to Go RPC"
+
+// instrumentedHandler does what rpc.Server.ServeHTTP does, but serves the hijacked connection
+// through a codec that records metrics for every call.
+type instrumentedHandler struct {
+	server *rpc.Server
+}
+
+func (h instrumentedHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodConnect {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		slog.ErrorContext(req.Context(), "rpc hijacking", "remote_addr", req.RemoteAddr, "err", err)
+		return
+	}
+	io.WriteString(conn, "HTTP/1.0 "+connected+"\n\n")
+
+	h.server.ServeCodec(metrics.ServerCodec("netrpc", newGobServerCodec(conn)))
+}
+
+// gobServerCodec is the gob codec net/rpc uses by default, which the package does not export.
+type gobServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+func newGobServerCodec(conn io.ReadWriteCloser) *gobServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &gobServerCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+	}
+}
+
+func (c *gobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobServerCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	if err := c.enc.Encode(r); err != nil {
+		// gob couldn't encode the header, the stream is unusable
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobServerCodec) Close() error {
+	if c.closed {
+		// only close the connection once
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
@@ -1,34 +1,71 @@
 package rpcserver
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/rpc"
 	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/tracing"
 )
 
 
-type Args struct {}
+// Args carries the W3C traceparent of the caller, net/rpc has no headers to put it in.
+type Args struct {
+	TraceParent string
+}
 type TimeServer int64
 
 func (t *TimeServer) GiveServerTime(args *Args, reply *int64) error {
+	_, span := tracing.StartRemote(context.Background(), args.TraceParent, "TimeServer.GiveServerTime", tracing.KindServer)
+	defer span.End()
+
 	*reply = time.Now().Unix()
 	return  nil
 }
 
-
-func Run() {
+// NewHandler returns an HTTP handler speaking net/rpc on rpc.DefaultRPCPath with TimeServer registered.
+// Every call is counted and timed in the rpc_* metrics.
+func NewHandler() http.Handler {
 	//creating a RPC server here
-	timeserver := new(TimeServer)
+	server := rpc.NewServer()
 
 	//registering the rpc server
-	rpc.Register(timeserver)
-	rpc.HandleHTTP()
+	if err := server.Register(new(TimeServer)); err != nil {
+		log.Fatal("register error: ", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, instrumentedHandler{server: server})
+	return mux
+}
 
-	l, err:= net.Listen("tcp", ":1234")
+// Run serves TimeServer until SIGINT/SIGTERM. Connections already switched to the RPC protocol
+// are hijacked from the HTTP server, so draining only waits for the CONNECT handshakes.
+func Run(cfg config.RPCServer, lc config.Lifecycle) error {
+	l, err:= net.Listen("tcp", cfg.Addr)
 	if err != nil {
-		log.Fatal("listen error: ", err)
+		return fmt.Errorf("listen error: %w", err)
 	}
-	http.Serve(l, nil)
-}
\ No newline at end of file
+
+	m := lifecycle.New(lc)
+
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
+	checker.AddReadinessCheck("rpc listener", health.Dial(func() string { return m.Addr("rpc") }))
+
+	mux := http.NewServeMux()
+	mux.Handle("/", NewHandler())
+	checker.RegisterServeMux(mux)
+	metrics.RegisterServeMux(mux)
+
+	m.AddListener("rpc", &http.Server{Handler: mux}, l)
+	return m.Run(context.Background())
+}
@@ -0,0 +1,17 @@
+package rpcserver
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/Dav16Akin/go-dictionary/contract"
+)
+
+func TestContracts(t *testing.T) {
+	contract.Verify(t, "../contracts", contract.Provider{
+		Name:      "rpc-server",
+		Handler:   NewHandler(),
+		Path:      rpc.DefaultRPCPath,
+		Receivers: []any{new(TimeServer)},
+	})
+}
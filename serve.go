@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	ginfundamentals "github.com/Dav16Akin/go-dictionary/ginFundamentals"
+	gorillarpcserver "github.com/Dav16Akin/go-dictionary/gorillaRPCServer"
+	"github.com/Dav16Akin/go-dictionary/health"
+	learningmiddlewares "github.com/Dav16Akin/go-dictionary/learningMiddlewares"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	othermux "github.com/Dav16Akin/go-dictionary/otherMux"
+	railapi "github.com/Dav16Akin/go-dictionary/railAPI"
+	rpcserver "github.com/Dav16Akin/go-dictionary/rpcServer"
+	testingstatefulapi "github.com/Dav16Akin/go-dictionary/testingStatefulApi"
+	"github.com/Dav16Akin/go-dictionary/tracing"
+	"github.com/justinas/alice"
+)
+
+// mountable lists the services serve can put behind a path prefix.
+var mountable = map[string]func(cfg *config.Config, m *lifecycle.Manager, checker *health.Checker) (http.Handler, error){
+	"rail": func(cfg *config.Config, m *lifecycle.Manager, checker *health.Checker) (http.Handler, error) {
+		if err := railapi.Open(cfg.Rail.DBPath); err != nil {
+			return nil, err
+		}
+		m.AddCloser("rail database", railapi.DB)
+		railapi.AddHealthChecks(checker)
+		return railapi.NewContainer(), nil
+	},
+	"gin": func(cfg *config.Config, m *lifecycle.Manager, checker *health.Checker) (http.Handler, error) {
+		if err := ginfundamentals.Open(cfg.Gin.DBPath); err != nil {
+			return nil, err
+		}
+		m.AddCloser("gin database", ginfundamentals.DB)
+		ginfundamentals.AddHealthChecks(checker)
+		return ginfundamentals.NewRouter(), nil
+	},
+	"users": func(cfg *config.Config, m *lifecycle.Manager, checker *health.Checker) (http.Handler, error) {
+		if err := testingstatefulapi.Open(cfg.Users.DBPath); err != nil {
+			return nil, err
+		}
+		if testingstatefulapi.DB != nil {
+			m.AddCloser("users database", testingstatefulapi.DB)
+		}
+		testingstatefulapi.AddHealthChecks(checker)
+		return testingstatefulapi.NewHandler(), nil
+	},
+	"cities": func(*config.Config, *lifecycle.Manager, *health.Checker) (http.Handler, error) {
+		return learningmiddlewares.NewHandler(), nil
+	},
+	"rpc": func(*config.Config, *lifecycle.Manager, *health.Checker) (http.Handler, error) {
+		return rpcserver.NewHandler(), nil
+	},
+	"jsonrpc": func(cfg *config.Config, _ *lifecycle.Manager, _ *health.Checker) (http.Handler, error) {
+		return gorillarpcserver.NewHandler(cfg.JSONRPC), nil
+	},
+	"mux": func(*config.Config, *lifecycle.Manager, *health.Checker) (http.Handler, error) {
+		return othermux.NewGorillaRouter(), nil
+	},
+	"httprouter": func(cfg *config.Config, _ *lifecycle.Manager, _ *health.Checker) (http.Handler, error) {
+		return othermux.NewHttpRouter(cfg.Mux), nil
+	},
+}
+
+// hardened lists the services whose handler already carries the access log and the Production
+// chain, so serve does not wrap them a second time.
+var hardened = map[string]bool{"cities": true}
+
+// mount is one service[=prefix] argument of serve.
+type mount struct {
+	service string
+	prefix  string
+}
+
+func parseMount(arg string) (mount, error) {
+	service, prefix, hasPrefix := strings.Cut(arg, "=")
+	if _, ok := mountable[service]; !ok {
+		return mount{}, fmt.Errorf("unknown service %q", service)
+	}
+
+	if !hasPrefix {
+		prefix = "/" + service
+	}
+	prefix = "/" + strings.Trim(prefix, "/")
+
+	return mount{service: service, prefix: prefix}, nil
+}
+
+func serveUsage() {
+	names := make([]string, 0, len(mountable))
+	for name := range mountable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: go-dictionary serve [flags] service[=prefix] ...")
+	fmt.Fprintf(os.Stderr, "Services: %s\n", strings.Join(names, ", "))
+	fmt.Fprintln(os.Stderr, "The prefix defaults to /<service>; use service=/ to mount one service at the root.")
+	fmt.Fprintln(os.Stderr, "Without arguments the serve.mounts list of the config is used.")
+}
+
+func runServe(cfg *config.Config, args []string) error {
+	mounts := cfg.Serve.Mounts
+	if len(args) > 0 {
+		mounts = args
+	}
+
+	if len(mounts) == 0 {
+		serveUsage()
+		return fmt.Errorf("no services to mount")
+	}
+
+	// every argument is checked before the first service opens its database
+	var parsed []mount
+	seen := map[string]bool{}
+	for _, arg := range mounts {
+		m, err := parseMount(arg)
+		if err != nil {
+			return err
+		}
+
+		if seen[m.prefix] {
+			return fmt.Errorf("prefix %s is mounted twice", m.prefix)
+		}
+		seen[m.prefix] = true
+		parsed = append(parsed, m)
+	}
+
+	manager := lifecycle.New(cfg.Lifecycle)
+	mux := http.NewServeMux()
+
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(manager))
+
+	for _, m := range parsed {
+		handler, err := mountable[m.service](cfg, manager, checker)
+		if err != nil {
+			return errors.Join(fmt.Errorf("starting %s: %w", m.service, err), manager.Close())
+		}
+
+		switch {
+		case m.service == "rpc":
+			// the rpc CONNECT handshake hands the connection over, its calls are counted by the rpc metrics
+		case hardened[m.service]:
+			handler = alice.New(tracing.Middleware(m.service), metrics.Middleware(m.service)).Then(handler)
+		default:
+			handler = alice.New(tracing.Middleware(m.service), logging.Middleware, metrics.Middleware(m.service)).
+				Extend(learningmiddlewares.Production()).
+				Then(handler)
+		}
+
+		if m.prefix == "/" {
+			mux.Handle("/", handler)
+		} else {
+			mux.Handle(m.prefix+"/", http.StripPrefix(m.prefix, handler))
+		}
+		log.Printf("Mounted %s on %s", m.service, m.prefix)
+	}
+
+	// the probes and metrics live at the root, outside every prefix
+	checker.RegisterServeMux(mux)
+	metrics.RegisterServeMux(mux)
+
+	manager.AddServer("serve", &http.Server{Addr: cfg.Serve.Addr, Handler: mux})
+	return manager.Run(context.Background())
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"log"
 
+	"github.com/Dav16Akin/go-dictionary/config"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -13,8 +14,8 @@ type Book struct {
 	author string
 }
 
-func Run() {
-	db, err := sql.Open("sqlite3", "./books.db")
+func Run(cfg config.Books) {
+	db, err := sql.Open("sqlite3", cfg.DBPath)
 	log.Println(db)
 	if err != nil {
 		log.Println(err)
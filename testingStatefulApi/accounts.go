@@ -0,0 +1,572 @@
+package testingstatefulapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/problem"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionCookie = "session"
+	// bcrypt reads at most 72 bytes of a password, a longer one would be cut without a word
+	minPassword, maxPassword = 8, 72
+
+	purposeVerifyEmail   = "verify-email"
+	purposeResetPassword = "reset-password"
+)
+
+// Credentials are what a user logs in with. Email is the login, set at registration and kept
+// lowercased; the user's own email may change afterwards without changing it.
+type Credentials struct {
+	UserID        int
+	Email         string
+	PasswordHash  []byte
+	EmailVerified bool
+	// FailedLogins counts the wrong passwords since the last login or lockout.
+	FailedLogins int
+	LockedUntil  time.Time
+}
+
+// Session is a login. Only the SHA-256 of its token is kept, so the table logs no one in.
+type Session struct {
+	TokenHash string
+	UserID    int
+	ExpiresAt time.Time
+}
+
+// Token is the single use token of an email verification or password reset link, kept hashed
+// like a session's.
+type Token struct {
+	TokenHash string
+	UserID    int
+	Purpose   string
+	ExpiresAt time.Time
+}
+
+// Account is a user as its owner sees it.
+type Account struct {
+	User
+	EmailVerified bool `json:"email_verified"`
+}
+
+type registerRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token     string    `json:"token"`
+	UserID    int       `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type passwordChange struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type tokenRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+// registeredSubject and registeredBody tell the owner of an email that someone registered it again.
+const (
+	registeredSubject = "Someone tried to register your email address"
+	registeredBody    = "Someone tried to create an account with this email address, which already has one.\r\n\r\n" +
+		"If it was you, log in instead, or ask for a password reset if you forgot your password. " +
+		"If it wasn't, ignore this email: your account is unchanged.\r\n"
+)
+
+// emails are the links sendToken writes for each purpose, the body formatted with the link and
+// how long it is valid.
+var emails = map[string]struct{ subject, path, body string }{
+	purposeVerifyEmail: {
+		"Verify your email address",
+		"/accounts/verify-email",
+		"Open the link below to confirm this is your email address. It is valid for %[2]s.\r\n\r\n%[1]s\r\n",
+	},
+	purposeResetPassword: {
+		"Reset your password",
+		"/accounts/password-reset",
+		"Open the link below to choose a new password. It is valid for %[2]s.\r\n\r\n%[1]s\r\n\r\n" +
+			"If you did not ask for it, ignore this email and your password stays as it is.\r\n",
+	},
+}
+
+var (
+	policyMutex    sync.RWMutex
+	accountsPolicy = config.Default().Accounts
+)
+
+// Setup sets the policy the account endpoints follow.
+func Setup(cfg config.Accounts) {
+	policyMutex.Lock()
+	defer policyMutex.Unlock()
+	accountsPolicy = cfg
+}
+
+func currentPolicy() config.Accounts {
+	policyMutex.RLock()
+	defer policyMutex.RUnlock()
+	return accountsPolicy
+}
+
+// dummyHash is compared against for an unknown email, so a login takes as long whether the
+// account exists or not. It is made on the first login, after Setup set the cost.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("no account has this password"), currentPolicy().BcryptCost)
+	return hash
+})
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeEmail returns a bare address lowercased, the form credentials are looked up by.
+func normalizeEmail(email string) (string, error) {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != strings.TrimSpace(email) {
+		return "", fmt.Errorf("email %q is not an address", email)
+	}
+	return strings.ToLower(address.Address), nil
+}
+
+// credentialsFor looks up the credentials of an email as the user typed it. One that isn't an
+// address has no account either, so it gets ErrNoAccount like an unknown one and the endpoints
+// answer both alike.
+func credentialsFor(ctx context.Context, email string) (Credentials, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return Credentials{}, ErrNoAccount
+	}
+	return store.GetCredentials(ctx, email)
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPassword || len(password) > maxPassword {
+		return fmt.Errorf("password must be %d to %d bytes long", minPassword, maxPassword)
+	}
+	return nil
+}
+
+// decode reads the JSON request body into v, answering the request itself when it can't.
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid Json")
+		return false
+	}
+	return true
+}
+
+// hashPassword hashes a password that passed validatePassword, answering the request itself
+// when bcrypt fails.
+func hashPassword(w http.ResponseWriter, r *http.Request, password string) ([]byte, bool) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), currentPolicy().BcryptCost)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "hashing password failed", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, "hashing password failed")
+		return nil, false
+	}
+	return hash, true
+}
+
+// sendToken emails the account a link carrying a new token of purpose.
+func sendToken(ctx context.Context, creds Credentials, purpose string) error {
+	policy := currentPolicy()
+	token := rand.Text()
+	err := store.CreateToken(ctx, Token{
+		TokenHash: hashToken(token),
+		UserID:    creds.UserID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(time.Duration(policy.TokenTTL)),
+	})
+	if err != nil {
+		return err
+	}
+
+	email := emails[purpose]
+	link := strings.TrimSuffix(policy.PublicURL, "/") + email.path + "?token=" + token
+	return sendEmail(policy, creds.Email, email.subject, fmt.Sprintf(email.body, link, policy.TokenTTL))
+}
+
+func setSessionCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   currentPolicy().SecureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// authenticate finds the session of the request's bearer token, or else its session cookie,
+// answering the request itself with 401 when there is none.
+func authenticate(w http.ResponseWriter, r *http.Request) (Session, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+
+	if token != "" {
+		session, err := store.GetSession(r.Context(), hashToken(token))
+		if err == nil {
+			return session, true
+		}
+		if !errors.Is(err, ErrNoSession) {
+			storeError(w, r, err)
+			return Session{}, false
+		}
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="users"`)
+	problem.Write(w, r, http.StatusUnauthorized, "Login Required")
+	return Session{}, false
+}
+
+// register creates a user with credentials and emails it a verification link. An email that
+// already has an account gets a notice instead, and the answer is 202 either way, so the endpoint
+// doesn't tell which emails are registered.
+func register(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		problem.Write(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		problem.Write(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	// hashed for a registered email too, which would otherwise be answered faster
+	hash, ok := hashPassword(w, r, req.Password)
+	if !ok {
+		return
+	}
+
+	err = createAccount(r.Context(), req.Name, email, hash)
+	if errors.Is(err, ErrEmailTaken) {
+		err = sendEmail(currentPolicy(), email, registeredSubject, registeredBody)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "registration failed", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, "registration failed")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// createAccount creates a user with credentials and sends the verification link. It returns
+// ErrEmailTaken, having created nothing, when email already has an account.
+func createAccount(ctx context.Context, name, email string, hash []byte) error {
+	if _, err := store.GetCredentials(ctx, email); err == nil {
+		return ErrEmailTaken
+	} else if !errors.Is(err, ErrNoAccount) {
+		return err
+	}
+
+	user, err := store.Create(ctx, User{Name: name, Email: email})
+	if err != nil {
+		return err
+	}
+	creds := Credentials{UserID: user.ID, Email: email, PasswordHash: hash}
+	// checked again here, another registration may have taken the email since
+	if err := store.CreateCredentials(ctx, creds); err != nil {
+		if err := store.Delete(ctx, user.ID); err != nil {
+			slog.ErrorContext(ctx, "removing user of failed registration", "user_id", user.ID, "err", err)
+		}
+		return err
+	}
+
+	// the account works without it, another link can be asked for
+	if err := sendToken(ctx, creds, purposeVerifyEmail); err != nil {
+		slog.ErrorContext(ctx, "sending verification email failed", "user_id", user.ID, "err", err)
+	}
+	return nil
+}
+
+// login answers the right password with a session, as a cookie for browsers and a bearer token
+// for other clients. MaxFailedLogins wrong ones in a row lock the account for LockoutDuration,
+// during which even the right password is refused.
+func login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	policy := currentPolicy()
+
+	creds, err := credentialsFor(r.Context(), req.Email)
+	if errors.Is(err, ErrNoAccount) {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(req.Password))
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid Email Or Password")
+		return
+	}
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	if now.Before(creds.LockedUntil) {
+		retry := int(creds.LockedUntil.Sub(now).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		problem.Write(w, r, http.StatusTooManyRequests, fmt.Sprintf("account locked after %d failed logins, retry in %d s", policy.MaxFailedLogins, retry))
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword(creds.PasswordHash, []byte(req.Password)) != nil {
+		until := now.Add(time.Duration(policy.LockoutDuration))
+		locked, err := store.RecordFailedLogin(r.Context(), creds.UserID, policy.MaxFailedLogins, until)
+		if err != nil {
+			storeError(w, r, err)
+			return
+		}
+		if locked {
+			slog.WarnContext(r.Context(), "account locked", "user_id", creds.UserID, "until", until)
+		}
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid Email Or Password")
+		return
+	}
+
+	if creds.FailedLogins > 0 || !creds.LockedUntil.IsZero() {
+		creds.FailedLogins, creds.LockedUntil = 0, time.Time{}
+		if err := store.UpdateCredentials(r.Context(), creds); err != nil {
+			storeError(w, r, err)
+			return
+		}
+	}
+
+	token := rand.Text()
+	session := Session{TokenHash: hashToken(token), UserID: creds.UserID, ExpiresAt: now.Add(time.Duration(policy.SessionTTL))}
+	if err := store.CreateSession(r.Context(), session); err != nil {
+		storeError(w, r, err)
+		return
+	}
+
+	setSessionCookie(w, token, session.ExpiresAt)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, loginResponse{Token: token, UserID: session.UserID, ExpiresAt: session.ExpiresAt})
+}
+
+func logout(w http.ResponseWriter, r *http.Request) {
+	session, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteSession(r.Context(), session.TokenHash); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	setSessionCookie(w, "", time.Unix(0, 0))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getAccount(w http.ResponseWriter, r *http.Request) {
+	session, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := store.Get(r.Context(), session.UserID)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	creds, err := store.GetCredentialsByUser(r.Context(), session.UserID)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, Account{User: user, EmailVerified: creds.EmailVerified})
+}
+
+// changePassword replaces the password given the current one, and logs out every other session.
+func changePassword(w http.ResponseWriter, r *http.Request) {
+	session, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+	var req passwordChange
+	if !decode(w, r, &req) {
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		problem.Write(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	creds, err := store.GetCredentialsByUser(r.Context(), session.UserID)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if bcrypt.CompareHashAndPassword(creds.PasswordHash, []byte(req.CurrentPassword)) != nil {
+		problem.Write(w, r, http.StatusForbidden, "Current Password Is Wrong")
+		return
+	}
+	hash, ok := hashPassword(w, r, req.NewPassword)
+	if !ok {
+		return
+	}
+	creds.PasswordHash = hash
+
+	if err := store.UpdateCredentials(r.Context(), creds); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if err := store.DeleteSessions(r.Context(), creds.UserID, session.TokenHash); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestVerification emails a new verification link to the logged in account.
+func requestVerification(w http.ResponseWriter, r *http.Request) {
+	session, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	creds, err := store.GetCredentialsByUser(r.Context(), session.UserID)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if creds.EmailVerified {
+		problem.Write(w, r, http.StatusConflict, "Email Already Verified")
+		return
+	}
+	if err := sendToken(r.Context(), creds, purposeVerifyEmail); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verifyEmail marks the email of the account a verification token was sent to as verified.
+func verifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	token, err := store.ConsumeToken(r.Context(), hashToken(req.Token), purposeVerifyEmail)
+	if err != nil {
+		tokenError(w, r, err)
+		return
+	}
+	creds, err := store.GetCredentialsByUser(r.Context(), token.UserID)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	creds.EmailVerified = true
+	if err := store.UpdateCredentials(r.Context(), creds); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestPasswordReset emails a reset link when the email has an account. The answer is 202
+// either way, so it doesn't tell which emails are registered.
+func requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req emailRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	creds, err := credentialsFor(r.Context(), req.Email)
+	if err == nil {
+		err = sendToken(r.Context(), creds, purposeResetPassword)
+	}
+	if err != nil && !errors.Is(err, ErrNoAccount) {
+		slog.ErrorContext(r.Context(), "sending password reset email failed", "err", err)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resetPassword sets a new password with a reset token. It unlocks the account and logs out
+// every session, and as the token came by email, verifies the email too.
+func resetPassword(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	// checked before the token is used up, so a rejected password can be retried with it
+	if err := validatePassword(req.NewPassword); err != nil {
+		problem.Write(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	token, err := store.ConsumeToken(r.Context(), hashToken(req.Token), purposeResetPassword)
+	if err != nil {
+		tokenError(w, r, err)
+		return
+	}
+	creds, err := store.GetCredentialsByUser(r.Context(), token.UserID)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	hash, ok := hashPassword(w, r, req.NewPassword)
+	if !ok {
+		return
+	}
+	creds.PasswordHash, creds.EmailVerified = hash, true
+	creds.FailedLogins, creds.LockedUntil = 0, time.Time{}
+
+	if err := store.UpdateCredentials(r.Context(), creds); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if err := store.DeleteSessions(r.Context(), creds.UserID, ""); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tokenError answers a failed ConsumeToken.
+func tokenError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrNoToken) {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid Or Expired Token")
+		return
+	}
+	storeError(w, r, err)
+}
@@ -0,0 +1,361 @@
+package testingstatefulapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/apitest"
+	"github.com/Dav16Akin/go-dictionary/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupAccounts points the emails at a directory of the test and makes hashing cheap.
+func setupAccounts(t *testing.T) string {
+	outbox := t.TempDir()
+	Setup(config.Accounts{
+		OutboxDir:       outbox,
+		PublicURL:       "http://users.test",
+		SessionTTL:      config.Duration(time.Hour),
+		TokenTTL:        config.Duration(time.Hour),
+		MaxFailedLogins: 3,
+		LockoutDuration: config.Duration(time.Minute),
+		BcryptCost:      bcrypt.MinCost,
+	})
+	t.Cleanup(func() { Setup(config.Default().Accounts) })
+	return outbox
+}
+
+var emailToken = regexp.MustCompile(`\?token=([A-Z0-9]+)`)
+
+// lastToken returns the token of the newest email in outbox whose subject is subject.
+func lastToken(t *testing.T, outbox, subject string) string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(outbox, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := ""
+	var newest time.Time
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "Subject: "+subject+"\r\n") || info.ModTime().Before(newest) {
+			continue
+		}
+		match := emailToken.FindStringSubmatch(string(data))
+		if match == nil {
+			t.Fatalf("no link in %s:\n%s", file, data)
+		}
+		token, newest = match[1], info.ModTime()
+	}
+	if token == "" {
+		t.Fatalf("no email %q in the outbox", subject)
+	}
+	return token
+}
+
+// outboxEmails returns the emails in outbox whose subject is subject.
+func outboxEmails(t *testing.T, outbox, subject string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(outbox, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "Subject: "+subject+"\r\n") {
+			found = append(found, string(data))
+		}
+	}
+	return found
+}
+
+// loginToken logs in and returns the bearer token.
+func loginToken(t *testing.T, srv *apitest.Server, email, password string) string {
+	t.Helper()
+	var session loginResponse
+	srv.Do(t, "POST", "/accounts/login", loginRequest{Email: email, Password: password}).Status(t, http.StatusOK).Decode(t, &session)
+	return session.Token
+}
+
+func bearer(token string) []string {
+	return []string{"Authorization", "Bearer " + token}
+}
+
+// signUp registers a user with the password hunter22 and returns the header of its session.
+func signUp(t *testing.T, srv *apitest.Server, name, email string) []string {
+	t.Helper()
+	setupAccounts(t)
+	srv.Do(t, "POST", "/accounts/register", registerRequest{Name: name, Email: email, Password: "hunter22"}).Status(t, http.StatusAccepted)
+	return bearer(loginToken(t, srv, email, "hunter22"))
+}
+
+func TestRegister(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		outbox := setupAccounts(t)
+
+		run(t, srv, []step{
+			{"register", "POST", "/accounts/register", registerRequest{Name: "Ann", Email: "Ann@Example.com", Password: "hunter22"}, 202, "", ""},
+			// answered like a new email, so the endpoint doesn't tell which emails are registered
+			{"email taken in another case", "POST", "/accounts/register", registerRequest{Name: "A", Email: "ANN@example.com", Password: "hunter22"}, 202, "", ""},
+			{"not an address", "POST", "/accounts/register", registerRequest{Email: "Ann <a@example.com>", Password: "hunter22"}, 422, "", `email "Ann <a@example.com>" is not an address`},
+			{"short password", "POST", "/accounts/register", registerRequest{Email: "b@example.com", Password: "short"}, 422, "", "password must be 8 to 72 bytes long"},
+			{"long password", "POST", "/accounts/register", registerRequest{Email: "b@example.com", Password: strings.Repeat("x", 73)}, 422, "", "password must be 8 to 72 bytes long"},
+			{"invalid json", "POST", "/accounts/register", "{", 400, "", "Invalid Json"},
+		})
+
+		// the owner of the email is told about the second registration instead
+		if notices := outboxEmails(t, outbox, registeredSubject); len(notices) != 1 || !strings.Contains(notices[0], "To: <ann@example.com>\r\n") {
+			t.Errorf("notices of the second registration = %q, want one to ann@example.com", notices)
+		}
+		if verifications := outboxEmails(t, outbox, "Verify your email address"); len(verifications) != 1 {
+			t.Errorf("%d verification emails, want one for the account created", len(verifications))
+		}
+
+		token := lastToken(t, outbox, "Verify your email address")
+		srv.Do(t, "POST", "/accounts/verify-email", tokenRequest{Token: token}).Status(t, 204)
+		srv.Do(t, "POST", "/accounts/verify-email", tokenRequest{Token: token}).Status(t, 400).Problem(t, "Invalid Or Expired Token")
+
+		// the password of the second registration changed nothing
+		srv.Do(t, "POST", "/accounts/login", loginRequest{Email: "ann@example.com", Password: "hunter22"}).Status(t, 200)
+		session := loginToken(t, srv, "ann@example.com", "hunter22")
+		srv.Do(t, "GET", "/accounts/me", nil, bearer(session)...).Status(t, 200).
+			JSON(t, `{"id":1,"name":"Ann","email":"ann@example.com","email_verified":true}`)
+		srv.Do(t, "POST", "/accounts/verify-email/request", nil, bearer(session)...).Status(t, 409)
+		// only the registered user exists
+		srv.Do(t, "GET", "/users", nil, bearer(session)...).Status(t, 200).JSON(t, `[{"id":1,"name":"Ann"}]`)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		setupAccounts(t)
+		srv.Do(t, "POST", "/accounts/register", registerRequest{Name: "Ann", Email: "ann@example.com", Password: "hunter22"}).Status(t, 202)
+
+		resp := srv.Do(t, "POST", "/accounts/login", loginRequest{Email: " ANN@example.com", Password: "hunter22"}).Status(t, 200)
+		var session loginResponse
+		resp.Decode(t, &session)
+		cookie := resp.Header.Get("Set-Cookie")
+		for _, attribute := range []string{"session=" + session.Token, "HttpOnly", "SameSite=Lax", "Path=/"} {
+			if !strings.Contains(cookie, attribute) {
+				t.Errorf("Set-Cookie = %q, missing %s", cookie, attribute)
+			}
+		}
+
+		tests := []struct {
+			name   string
+			header []string
+			status int
+		}{
+			{"bearer", bearer(session.Token), 200},
+			{"cookie", []string{"Cookie", "session=" + session.Token}, 200},
+			{"none", nil, 401},
+			{"unknown token", bearer("NOTATOKEN"), 401},
+			{"not bearer", []string{"Authorization", "Basic " + session.Token}, 401},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := srv.Do(t, "GET", "/accounts/me", nil, tt.header...).Status(t, tt.status)
+				if tt.status == 401 && resp.Header.Get("WWW-Authenticate") == "" {
+					t.Error("401 without WWW-Authenticate")
+				}
+			})
+		}
+
+		srv.Do(t, "POST", "/accounts/logout", nil, bearer(session.Token)...).Status(t, 204)
+		srv.Do(t, "GET", "/accounts/me", nil, bearer(session.Token)...).Status(t, 401)
+		srv.Do(t, "POST", "/accounts/logout", nil, bearer(session.Token)...).Status(t, 401)
+	})
+}
+
+// TestIdempotencyKeysOnAccounts checks that neither a session nor another user's response is
+// replayed for a reused Idempotency-Key.
+func TestIdempotencyKeysOnAccounts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		ann := signUp(t, srv, "Ann", "ann@example.com")
+		bob := signUp(t, srv, "Bob", "bob@example.com")
+
+		login := loginRequest{Email: "ann@example.com", Password: "hunter22"}
+		var first, retry loginResponse
+		srv.Do(t, "POST", "/accounts/login", login, "Idempotency-Key", "login").Status(t, 200).Decode(t, &first)
+		resp := srv.Do(t, "POST", "/accounts/login", login, "Idempotency-Key", "login").Status(t, 200)
+		resp.Decode(t, &retry)
+		if resp.Header.Get("Idempotent-Replayed") != "" || retry.Token == first.Token {
+			t.Error("a retried login replayed the first session")
+		}
+
+		home := Address{Street: "1 Main St", City: "Lagos", Country: "NG"}
+		srv.Do(t, "POST", "/users/1/addresses", home, append(ann, "Idempotency-Key", "address")...).Status(t, 201)
+		srv.Do(t, "POST", "/users/1/addresses", home, append(bob, "Idempotency-Key", "address")...).Status(t, 403).Problem(t, "Not Your Account")
+		srv.Do(t, "POST", "/users/1/addresses", home, "Idempotency-Key", "address").Status(t, 401)
+	})
+}
+
+func TestLockout(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		outbox := setupAccounts(t)
+		srv.Do(t, "POST", "/accounts/register", registerRequest{Name: "Ann", Email: "ann@example.com", Password: "hunter22"}).Status(t, 202)
+
+		wrong := loginRequest{Email: "ann@example.com", Password: "wrong password"}
+		srv.Do(t, "POST", "/accounts/login", wrong).Status(t, 401).Problem(t, "Invalid Email Or Password")
+		// a login in between starts the count over
+		loginToken(t, srv, "ann@example.com", "hunter22")
+		srv.Do(t, "POST", "/accounts/login", wrong).Status(t, 401)
+		srv.Do(t, "POST", "/accounts/login", wrong).Status(t, 401)
+		srv.Do(t, "POST", "/accounts/login", wrong).Status(t, 401)
+
+		resp := srv.Do(t, "POST", "/accounts/login", loginRequest{Email: "ann@example.com", Password: "hunter22"}).Status(t, 429)
+		if retry := resp.Header.Get("Retry-After"); retry != "60" {
+			t.Errorf("Retry-After = %q, want 60", retry)
+		}
+
+		// an unknown email, or one that isn't an address, is refused like a wrong password and locks nothing
+		srv.Do(t, "POST", "/accounts/login", loginRequest{Email: "bob@example.com", Password: "hunter22"}).Status(t, 401).Problem(t, "Invalid Email Or Password")
+		srv.Do(t, "POST", "/accounts/login", loginRequest{Email: "Ann <ann@example.com>", Password: "hunter22"}).Status(t, 401).Problem(t, "Invalid Email Or Password")
+
+		// a password reset unlocks the account
+		srv.Do(t, "POST", "/accounts/password-reset/request", emailRequest{Email: "ann@example.com"}).Status(t, 202)
+		token := lastToken(t, outbox, "Reset your password")
+		srv.Do(t, "POST", "/accounts/password-reset", tokenRequest{Token: token, NewPassword: "correct horse"}).Status(t, 204)
+		loginToken(t, srv, "ann@example.com", "correct horse")
+	})
+}
+
+// TestLockoutConcurrent sends wrong passwords at once, which must all be counted: a count read
+// and written back by each login would lose some and never lock the account.
+func TestLockoutConcurrent(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		signUp(t, srv, "Ann", "ann@example.com")
+
+		const max = 20
+		var wg sync.WaitGroup
+		var locks atomic.Int32
+		until := time.Now().Add(time.Minute)
+		for range max {
+			wg.Go(func() {
+				locked, err := store.RecordFailedLogin(t.Context(), 1, max, until)
+				if err != nil {
+					t.Error(err)
+				}
+				if locked {
+					locks.Add(1)
+				}
+			})
+		}
+		wg.Wait()
+		if locks.Load() != 1 {
+			t.Errorf("%d of %d failed logins locked the account, want the last one", locks.Load(), max)
+		}
+		creds, err := store.GetCredentialsByUser(t.Context(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds.FailedLogins != 0 || !creds.LockedUntil.Equal(until) {
+			t.Errorf("FailedLogins %d, LockedUntil %v, want 0 and %v", creds.FailedLogins, creds.LockedUntil, until)
+		}
+		if _, err := store.RecordFailedLogin(t.Context(), 9, max, until); !errors.Is(err, ErrNoAccount) {
+			t.Errorf("RecordFailedLogin of an unknown user = %v, want %v", err, ErrNoAccount)
+		}
+
+		// through the handler, as many wrong passwords at once as the policy allows lock it too
+		signUp(t, srv, "Bob", "bob@example.com")
+		handler := NewHandler()
+		for range 3 {
+			wg.Go(func() {
+				body := strings.NewReader(`{"email":"bob@example.com","password":"wrong password"}`)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest("POST", "/accounts/login", body))
+				if rec.Code != http.StatusUnauthorized {
+					t.Errorf("wrong password = %d: %s", rec.Code, rec.Body)
+				}
+			})
+		}
+		wg.Wait()
+		srv.Do(t, "POST", "/accounts/login", loginRequest{Email: "bob@example.com", Password: "hunter22"}).Status(t, 429)
+	})
+}
+
+func TestPasswordChange(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		setupAccounts(t)
+		srv.Do(t, "POST", "/accounts/register", registerRequest{Name: "Ann", Email: "ann@example.com", Password: "hunter22"}).Status(t, 202)
+		current := loginToken(t, srv, "ann@example.com", "hunter22")
+		other := loginToken(t, srv, "ann@example.com", "hunter22")
+
+		change := func(currentPassword, newPassword string) *apitest.Response {
+			return srv.Do(t, "POST", "/accounts/password", passwordChange{CurrentPassword: currentPassword, NewPassword: newPassword}, bearer(current)...)
+		}
+		change("wrong password", "correct horse").Status(t, 403).Problem(t, "Current Password Is Wrong")
+		change("hunter22", "short").Status(t, 422)
+		change("hunter22", "correct horse").Status(t, 204)
+
+		// the session that changed it stays, the others are logged out
+		srv.Do(t, "GET", "/accounts/me", nil, bearer(current)...).Status(t, 200)
+		srv.Do(t, "GET", "/accounts/me", nil, bearer(other)...).Status(t, 401)
+
+		srv.Do(t, "POST", "/accounts/login", loginRequest{Email: "ann@example.com", Password: "hunter22"}).Status(t, 401)
+		loginToken(t, srv, "ann@example.com", "correct horse")
+		srv.Do(t, "POST", "/accounts/password", passwordChange{CurrentPassword: "correct horse", NewPassword: "hunter22"}).Status(t, 401)
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		outbox := setupAccounts(t)
+		srv.Do(t, "POST", "/accounts/register", registerRequest{Name: "Ann", Email: "ann@example.com", Password: "hunter22"}).Status(t, 202)
+		session := loginToken(t, srv, "ann@example.com", "hunter22")
+
+		// 202 for an unknown email too, so the endpoint doesn't tell who is registered
+		srv.Do(t, "POST", "/accounts/password-reset/request", emailRequest{Email: "bob@example.com"}).Status(t, 202)
+		srv.Do(t, "POST", "/accounts/password-reset/request", emailRequest{Email: "not an address"}).Status(t, 202)
+		srv.Do(t, "POST", "/accounts/password-reset/request", emailRequest{Email: "Ann@example.com"}).Status(t, 202)
+		token := lastToken(t, outbox, "Reset your password")
+		verification := lastToken(t, outbox, "Verify your email address")
+
+		run(t, srv, []step{
+			{"verification token", "POST", "/accounts/password-reset", tokenRequest{Token: verification, NewPassword: "correct horse"}, 400, "", "Invalid Or Expired Token"},
+			{"unknown token", "POST", "/accounts/password-reset", tokenRequest{Token: "NOTATOKEN", NewPassword: "correct horse"}, 400, "", "Invalid Or Expired Token"},
+			{"short password keeps the token", "POST", "/accounts/password-reset", tokenRequest{Token: token, NewPassword: "short"}, 422, "", ""},
+			{"reset", "POST", "/accounts/password-reset", tokenRequest{Token: token, NewPassword: "correct horse"}, 204, "", ""},
+			{"token used", "POST", "/accounts/password-reset", tokenRequest{Token: token, NewPassword: "correct horse"}, 400, "", "Invalid Or Expired Token"},
+		})
+		srv.Do(t, "GET", "/accounts/me", nil, bearer(session)...).Status(t, 401)
+
+		// the reset link came by email, so the email is verified
+		session = loginToken(t, srv, "ann@example.com", "correct horse")
+		srv.Do(t, "GET", "/accounts/me", nil, bearer(session)...).Status(t, 200).
+			JSON(t, `{"id":1,"name":"Ann","email":"ann@example.com","email_verified":true}`)
+	})
+}
+
+func TestDeleteUserDeletesAccount(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		setupAccounts(t)
+		srv.Do(t, "POST", "/accounts/register", registerRequest{Name: "Ann", Email: "ann@example.com", Password: "hunter22"}).Status(t, 202)
+		session := loginToken(t, srv, "ann@example.com", "hunter22")
+
+		srv.Do(t, "DELETE", "/users/1", nil, bearer(session)...).Status(t, 204)
+		srv.Do(t, "GET", "/accounts/me", nil, bearer(session)...).Status(t, 401)
+		srv.Do(t, "POST", "/accounts/login", loginRequest{Email: "ann@example.com", Password: "hunter22"}).Status(t, 401)
+		srv.Do(t, "POST", "/accounts/register", registerRequest{Name: "Ann", Email: "ann@example.com", Password: "hunter22"}).Status(t, 202)
+	})
+}
@@ -0,0 +1,142 @@
+package testingstatefulapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Dav16Akin/go-dictionary/problem"
+)
+
+// Address is one of a user's postal addresses.
+type Address struct {
+	ID         int    `json:"id"`
+	UserID     int    `json:"user_id"`
+	Label      string `json:"label"`
+	Street     string `json:"street"`
+	City       string `json:"city"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+func (a Address) validate() error {
+	var missing []string
+	for _, field := range []struct{ name, value string }{{"street", a.Street}, {"city", a.City}, {"country", a.Country}} {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.New("address needs " + strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// decodeAddress reads an address from the request body, answering the request itself when it can't.
+func decodeAddress(w http.ResponseWriter, r *http.Request, address *Address) bool {
+	if err := json.NewDecoder(r.Body).Decode(address); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid Json")
+		return false
+	}
+	if err := address.validate(); err != nil {
+		problem.Write(w, r, http.StatusUnprocessableEntity, err.Error())
+		return false
+	}
+	return true
+}
+
+func listAddresses(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
+
+	addresses, err := store.ListAddresses(r.Context(), userID)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, addresses)
+}
+
+func createAddress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var address Address
+	if !decodeAddress(w, r, &address) {
+		return
+	}
+	address.UserID = userID
+
+	address, err := store.CreateAddress(r.Context(), address)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", r.URL.Path+"/"+strconv.Itoa(address.ID))
+	writeJSON(w, http.StatusCreated, address)
+}
+
+func getAddress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r, "addressID", "Address")
+	if !ok {
+		return
+	}
+
+	address, err := store.GetAddress(r.Context(), userID, id)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, address)
+}
+
+func updateAddress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r, "addressID", "Address")
+	if !ok {
+		return
+	}
+
+	var address Address
+	if !decodeAddress(w, r, &address) {
+		return
+	}
+	address.ID, address.UserID = id, userID
+
+	address, err := store.UpdateAddress(r.Context(), address)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, address)
+}
+
+func deleteAddress(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r, "addressID", "Address")
+	if !ok {
+		return
+	}
+
+	if err := store.DeleteAddress(r.Context(), userID, id); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
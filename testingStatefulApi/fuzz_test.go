@@ -0,0 +1,160 @@
+package testingstatefulapi
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fuzzStores returns a memory and a SQLite store, shared by every input of the fuzz target.
+// The store in use is put back when the target ends.
+func fuzzStores(f *testing.F) []Store {
+	db, err := sql.Open("sqlite3", f.TempDir()+"/users.db")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { db.Close() })
+	sqliteStore, err := NewSQLiteStore(db)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	previous := store
+	f.Cleanup(func() { store = previous })
+	return []Store{NewMemoryStore(), sqliteStore}
+}
+
+// serveEach sends the request to the handler once for each store, and fails the test on a 5xx
+// or when the stores answer differently. header is added to the request.
+func serveEach(t *testing.T, stores []Store, handler http.Handler, method, target string, body []byte, header ...string) (int, []byte) {
+	t.Helper()
+	// control characters never make it through a request line
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	var status int
+	var answer []byte
+	for i, s := range stores {
+		store = s
+		req := httptest.NewRequest(method, "/", bytes.NewReader(body))
+		req.URL = u
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code >= 500 {
+			t.Fatalf("%s %s %q = %d: %s", method, target, body, rec.Code, rec.Body)
+		}
+		// problem documents carry the request ID, only their status is compared
+		got := rec.Body.Bytes()
+		if rec.Code >= 400 {
+			got = nil
+		}
+		if i > 0 && (rec.Code != status || !bytes.Equal(got, answer)) {
+			t.Fatalf("%s %s %q: memory store answered %d %s, SQLite store %d %s", method, target, body, status, answer, rec.Code, got)
+		}
+		status, answer = rec.Code, got
+	}
+	return status, answer
+}
+
+// FuzzUsers replaces users with arbitrary bodies. Malformed ones must be refused with a 4xx,
+// and an accepted user must read back exactly as it was written, from either store.
+func FuzzUsers(f *testing.F) {
+	for _, seed := range []string{
+		`{"name":"Ada Lovelace","email":"ada@example.com"}`,
+		`{"name":"Grace"}`,
+		`{}`,
+		`{"id":99,"name":"Chosen ID"}`,
+		`{"name":"Ünïcödé 🚀","email":"ü@example.com"}`,
+		`{"name":"` + "\x00\xff" + `"}`,
+		`{"name":1}`,
+		`{"name":"Ada"} trailing`,
+		`[]`,
+		`null`,
+		``,
+	} {
+		f.Add([]byte(seed))
+	}
+	stores := fuzzStores(f)
+	handler := NewHandler()
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		// every input gets a user of its own, logged in; both stores hand out the same IDs
+		token := rand.Text()
+		var id int
+		for _, s := range stores {
+			user, err := s.Create(t.Context(), User{Name: "Ada", Email: "ada@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			id = user.ID
+			session := Session{TokenHash: hashToken(token), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+			if err := s.CreateSession(t.Context(), session); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		path := "/users/" + strconv.Itoa(id)
+		if status, replaced := serveEach(t, stores, handler, "PUT", path, body, bearer(token)...); status == http.StatusOK {
+			if _, read := serveEach(t, stores, handler, "GET", path, nil, bearer(token)...); !bytes.Equal(read, replaced) {
+				t.Fatalf("user read back as %s, replaced as %s", read, replaced)
+			}
+		}
+	})
+}
+
+// FuzzUserRequests sends arbitrary user IDs and list queries, logged in as the first user, which
+// must never fail the server and must be answered alike by both stores.
+func FuzzUserRequests(f *testing.F) {
+	for _, seed := range []struct{ id, query string }{
+		{"1", ""},
+		{"2", "fields=name"},
+		{"0", "fields=password"},
+		{"-1", "sort=-name&limit=1"},
+		{"01", "q=ada&sort=email"},
+		{"2", "fields=email"},
+		{"abc", "limit=0"},
+		{"9223372036854775808", "offset=-1"},
+		{"1.5", "cursor=garbage"},
+		{" 1", "sort=unknown"},
+		{"", "limit=101&offset=1e3"},
+		{"%", "%zz"},
+	} {
+		f.Add(seed.id, seed.query)
+	}
+	stores := fuzzStores(f)
+	handler := NewHandler()
+	token := rand.Text()
+	for _, s := range stores {
+		for _, name := range []string{"Ada", "Grace", "Linus"} {
+			if _, err := s.Create(f.Context(), User{Name: name, Email: name + "@example.com"}); err != nil {
+				f.Fatal(err)
+			}
+		}
+		session := Session{TokenHash: hashToken(token), UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+		if err := s.CreateSession(f.Context(), session); err != nil {
+			f.Fatal(err)
+		}
+	}
+	ada := bearer(token)
+
+	f.Fuzz(func(t *testing.T, id, query string) {
+		serveEach(t, stores, handler, "GET", "/users?"+query, nil, ada...)
+		path := "/users/" + url.PathEscape(id)
+		serveEach(t, stores, handler, "GET", path+"?"+query, nil, ada...)
+		serveEach(t, stores, handler, "GET", path+"/addresses", nil, ada...)
+		serveEach(t, stores, handler, "GET", path+"/preferences", nil, ada...)
+	})
+}
@@ -0,0 +1,348 @@
+package testingstatefulapi
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the users in a map, they are gone when the process exits.
+type MemoryStore struct {
+	mutex       sync.Mutex
+	users       map[int]User
+	addresses   map[int]Address
+	preferences map[int]Preferences
+	credentials map[int]Credentials
+	sessions    map[string]Session
+	tokens      map[string]Token
+	idSeq       int
+	addressSeq  int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       make(map[int]User),
+		addresses:   make(map[int]Address),
+		preferences: make(map[int]Preferences),
+		credentials: make(map[int]Credentials),
+		sessions:    make(map[string]Session),
+		tokens:      make(map[string]Token),
+		idSeq:       1,
+		addressSeq:  1,
+	}
+}
+
+func (s *MemoryStore) List(_ context.Context, q Query) (Page, error) {
+	s.mutex.Lock()
+	matched := make([]User, 0, len(s.users))
+	for _, user := range s.users {
+		if q.matches(user) {
+			matched = append(matched, user)
+		}
+	}
+	s.mutex.Unlock()
+
+	slices.SortFunc(matched, q.compare)
+	page := Page{Total: len(matched)}
+
+	start := min(q.Offset, len(matched))
+	if q.After != nil {
+		start = len(matched)
+		for i, user := range matched {
+			if q.behind(user) {
+				start = i
+				break
+			}
+		}
+	}
+	end := min(start+q.Limit, len(matched))
+
+	page.Users = matched[start:end]
+	if end < len(matched) {
+		page.Next = q.cursor(matched[end-1])
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id int) (User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *MemoryStore) Create(_ context.Context, user User) (User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	user.ID = s.idSeq
+	s.idSeq++
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, user User) (User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.users[user.ID]; !ok {
+		return User{}, ErrNotFound
+	}
+	s.users[user.ID] = user
+	return user, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.users, id)
+	delete(s.preferences, id)
+	delete(s.credentials, id)
+	for hash, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, hash)
+		}
+	}
+	for hash, token := range s.tokens {
+		if token.UserID == id {
+			delete(s.tokens, hash)
+		}
+	}
+	for addressID, address := range s.addresses {
+		if address.UserID == id {
+			delete(s.addresses, addressID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ListAddresses(_ context.Context, userID int) ([]Address, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	addresses := []Address{}
+	for _, address := range s.addresses {
+		if address.UserID == userID {
+			addresses = append(addresses, address)
+		}
+	}
+	slices.SortFunc(addresses, func(a, b Address) int { return a.ID - b.ID })
+	return addresses, nil
+}
+
+func (s *MemoryStore) GetAddress(_ context.Context, userID, id int) (Address, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	address, ok := s.addresses[id]
+	if !ok || address.UserID != userID {
+		return Address{}, ErrAddressNotFound
+	}
+	return address, nil
+}
+
+func (s *MemoryStore) CreateAddress(_ context.Context, address Address) (Address, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	address.ID = s.addressSeq
+	s.addressSeq++
+	s.addresses[address.ID] = address
+	return address, nil
+}
+
+func (s *MemoryStore) UpdateAddress(_ context.Context, address Address) (Address, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if current, ok := s.addresses[address.ID]; !ok || current.UserID != address.UserID {
+		return Address{}, ErrAddressNotFound
+	}
+	s.addresses[address.ID] = address
+	return address, nil
+}
+
+func (s *MemoryStore) DeleteAddress(_ context.Context, userID, id int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if address, ok := s.addresses[id]; !ok || address.UserID != userID {
+		return ErrAddressNotFound
+	}
+	delete(s.addresses, id)
+	return nil
+}
+
+func (s *MemoryStore) GetPreferences(_ context.Context, userID int) (Preferences, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	prefs, ok := s.preferences[userID]
+	if !ok {
+		return DefaultPreferences(userID), nil
+	}
+	return prefs, nil
+}
+
+func (s *MemoryStore) PutPreferences(_ context.Context, prefs Preferences) (Preferences, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.preferences[prefs.UserID] = prefs
+	return prefs, nil
+}
+
+func (s *MemoryStore) DeletePreferences(_ context.Context, userID int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.preferences, userID)
+	return nil
+}
+
+func (s *MemoryStore) GetCredentials(_ context.Context, email string) (Credentials, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, creds := range s.credentials {
+		if creds.Email == email {
+			return creds, nil
+		}
+	}
+	return Credentials{}, ErrNoAccount
+}
+
+func (s *MemoryStore) GetCredentialsByUser(_ context.Context, userID int) (Credentials, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	creds, ok := s.credentials[userID]
+	if !ok {
+		return Credentials{}, ErrNoAccount
+	}
+	return creds, nil
+}
+
+func (s *MemoryStore) CreateCredentials(_ context.Context, creds Credentials) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, other := range s.credentials {
+		if other.Email == creds.Email {
+			return ErrEmailTaken
+		}
+	}
+	s.credentials[creds.UserID] = creds
+	return nil
+}
+
+func (s *MemoryStore) UpdateCredentials(_ context.Context, creds Credentials) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.credentials[creds.UserID]; !ok {
+		return ErrNoAccount
+	}
+	s.credentials[creds.UserID] = creds
+	return nil
+}
+
+func (s *MemoryStore) RecordFailedLogin(_ context.Context, userID, max int, until time.Time) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	creds, ok := s.credentials[userID]
+	if !ok {
+		return false, ErrNoAccount
+	}
+	creds.FailedLogins++
+	locked := creds.FailedLogins >= max
+	if locked {
+		creds.FailedLogins, creds.LockedUntil = 0, until
+	}
+	s.credentials[userID] = creds
+	return locked, nil
+}
+
+func (s *MemoryStore) CreateSession(_ context.Context, session Session) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for hash, other := range s.sessions {
+		if !other.ExpiresAt.After(now) {
+			delete(s.sessions, hash)
+		}
+	}
+	s.sessions[session.TokenHash] = session
+	return nil
+}
+
+func (s *MemoryStore) GetSession(_ context.Context, tokenHash string) (Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[tokenHash]
+	if !ok || !session.ExpiresAt.After(time.Now()) {
+		return Session{}, ErrNoSession
+	}
+	return session, nil
+}
+
+func (s *MemoryStore) DeleteSession(_ context.Context, tokenHash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.sessions, tokenHash)
+	return nil
+}
+
+func (s *MemoryStore) DeleteSessions(_ context.Context, userID int, keep string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for hash, session := range s.sessions {
+		if session.UserID == userID && hash != keep {
+			delete(s.sessions, hash)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateToken(_ context.Context, token Token) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for hash, other := range s.tokens {
+		if !other.ExpiresAt.After(now) {
+			delete(s.tokens, hash)
+		}
+	}
+	s.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (s *MemoryStore) ConsumeToken(_ context.Context, tokenHash, purpose string) (Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token, ok := s.tokens[tokenHash]
+	if !ok || token.Purpose != purpose {
+		return Token{}, ErrNoToken
+	}
+	delete(s.tokens, tokenHash)
+	if !token.ExpiresAt.After(time.Now()) {
+		return Token{}, ErrNoToken
+	}
+	return token, nil
+}
@@ -0,0 +1,112 @@
+package testingstatefulapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestMemoryStoreConcurrent hammers the store's maps from many goroutines, for go test -race.
+func TestMemoryStoreConcurrent(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	const workers, perWorker = 8, 25
+
+	var wg sync.WaitGroup
+	ids := make(chan int, workers*perWorker)
+	for w := range workers {
+		wg.Go(func() {
+			for i := range perWorker {
+				user, err := s.Create(ctx, User{Name: fmt.Sprintf("user %d-%d", w, i)})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids <- user.ID
+
+				if _, err := s.CreateAddress(ctx, Address{UserID: user.ID, Street: "1 Main St", City: "Springfield", Country: "US"}); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.PutPreferences(ctx, Preferences{UserID: user.ID, Language: "en", Timezone: "UTC"}); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.List(ctx, Query{Sort: "name", Limit: 10}); err != nil {
+					t.Error(err)
+				}
+				// every other user is gone again, with what belongs to it
+				if i%2 == 0 {
+					if err := s.Delete(ctx, user.ID); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[int]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("ID %d handed out twice", id)
+		}
+		seen[id] = true
+	}
+
+	page, err := s.List(ctx, Query{Sort: "id", Limit: maxLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := workers * (perWorker / 2); page.Total != want {
+		t.Errorf("Total = %d, want %d", page.Total, want)
+	}
+	if len(s.addresses) != page.Total || len(s.preferences) != page.Total {
+		t.Errorf("%d addresses and %d preferences left for %d users", len(s.addresses), len(s.preferences), page.Total)
+	}
+}
+
+// TestHandlerConcurrent sends requests through the whole handler at once.
+func TestHandlerConcurrent(t *testing.T) {
+	store = NewMemoryStore()
+	t.Cleanup(func() { store = NewMemoryStore() })
+	handler := NewHandler()
+	createUsers(t, "Ann")
+	ann := logIn(t, 1)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(ann[0], ann[1])
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	const requests = 50
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Go(func() {
+			body := fmt.Sprintf(`{"street":"%d Main St","city":"Springfield","country":"US"}`, i)
+			if rec := serve("POST", "/users/1/addresses", body); rec.Code != http.StatusCreated {
+				t.Errorf("POST /users/1/addresses = %d: %s", rec.Code, rec.Body)
+				return
+			}
+			if rec := serve("GET", "/users?limit=5", ""); rec.Code != http.StatusOK {
+				t.Errorf("GET /users = %d: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+	wg.Wait()
+
+	var addresses []Address
+	if err := json.NewDecoder(serve("GET", "/users/1/addresses", "").Body).Decode(&addresses); err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != requests {
+		t.Errorf("%d addresses, want %d", len(addresses), requests)
+	}
+}
@@ -0,0 +1,45 @@
+package testingstatefulapi
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+)
+
+// sendEmail writes an email to the outbox directory as an .eml file, where a mail client or a
+// test can open it. Nothing is sent, the links in it are what the account flows need.
+func sendEmail(policy config.Accounts, to, subject, body string) error {
+	from := "localhost"
+	if u, err := url.Parse(policy.PublicURL); err == nil && u.Hostname() != "" {
+		from = u.Hostname()
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", (&mail.Address{Name: "go-dictionary", Address: "no-reply@" + from}).String())
+	fmt.Fprintf(&message, "To: %s\r\n", (&mail.Address{Address: to}).String())
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(body)
+
+	if err := os.MkdirAll(policy.OutboxDir, 0o700); err != nil {
+		return fmt.Errorf("creating outbox: %w", err)
+	}
+	// a reset link sets the password, so only the server's own user may read the files
+	file, err := os.CreateTemp(policy.OutboxDir, time.Now().UTC().Format("20060102T150405Z")+"-*.eml")
+	if err != nil {
+		return fmt.Errorf("writing email: %w", err)
+	}
+	if _, err := file.Write(message.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("writing email: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,129 @@
+package testingstatefulapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/problem"
+)
+
+// Preferences are a user's settings. Every user has them, DefaultPreferences until saved.
+type Preferences struct {
+	UserID     int    `json:"user_id"`
+	Language   string `json:"language"`
+	Timezone   string `json:"timezone"`
+	Newsletter bool   `json:"newsletter"`
+}
+
+// DefaultPreferences returns the preferences of a user that never saved any.
+func DefaultPreferences(userID int) Preferences {
+	return Preferences{UserID: userID, Language: "en", Timezone: "UTC"}
+}
+
+// preferencesPatch is a JSON merge patch of Preferences, fields left out stay as they are.
+type preferencesPatch struct {
+	Language   *string `json:"language"`
+	Timezone   *string `json:"timezone"`
+	Newsletter *bool   `json:"newsletter"`
+}
+
+func (p Preferences) validate() error {
+	if strings.TrimSpace(p.Language) == "" {
+		return errors.New("language is required")
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil || p.Timezone == "" {
+		return fmt.Errorf("timezone %q is not an IANA time zone", p.Timezone)
+	}
+	return nil
+}
+
+func getPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
+
+	prefs, err := store.GetPreferences(r.Context(), userID)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// putPreferences replaces the preferences, fields left out take their default.
+func putPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
+
+	prefs := DefaultPreferences(userID)
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid Json")
+		return
+	}
+	savePreferences(w, r, userID, prefs)
+}
+
+func patchPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
+
+	var patch preferencesPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid Json")
+		return
+	}
+
+	prefs, err := store.GetPreferences(r.Context(), userID)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	if patch.Language != nil {
+		prefs.Language = *patch.Language
+	}
+	if patch.Timezone != nil {
+		prefs.Timezone = *patch.Timezone
+	}
+	if patch.Newsletter != nil {
+		prefs.Newsletter = *patch.Newsletter
+	}
+	savePreferences(w, r, userID, prefs)
+}
+
+func savePreferences(w http.ResponseWriter, r *http.Request, userID int, prefs Preferences) {
+	prefs.UserID = userID
+	if err := prefs.validate(); err != nil {
+		problem.Write(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	prefs, err := store.PutPreferences(r.Context(), prefs)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// deletePreferences resets the preferences to their defaults.
+func deletePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
+
+	if err := store.DeletePreferences(r.Context(), userID); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,459 @@
+package testingstatefulapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var schema = []string{`
+	CREATE TABLE IF NOT EXISTS users (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		NAME VARCHAR(64) NOT NULL DEFAULT '',
+		EMAIL VARCHAR(255) NOT NULL DEFAULT ''
+	)`,
+	// the sort orders, so a page is read off an index rather than sorted
+	`CREATE INDEX IF NOT EXISTS users_name ON users (NAME COLLATE NOCASE, ID)`,
+	// users were sorted by email too before emails were kept private
+	`DROP INDEX IF EXISTS users_email`,
+	`CREATE TABLE IF NOT EXISTS user_address (
+		ID INTEGER PRIMARY KEY AUTOINCREMENT,
+		USER_ID INTEGER NOT NULL REFERENCES users(ID),
+		LABEL VARCHAR(64) NOT NULL DEFAULT '',
+		STREET VARCHAR(255) NOT NULL,
+		CITY VARCHAR(64) NOT NULL,
+		POSTAL_CODE VARCHAR(16) NOT NULL DEFAULT '',
+		COUNTRY VARCHAR(64) NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS user_address_user ON user_address (USER_ID, ID)`,
+	`CREATE TABLE IF NOT EXISTS user_preferences (
+		USER_ID INTEGER PRIMARY KEY REFERENCES users(ID),
+		LANGUAGE VARCHAR(16) NOT NULL,
+		TIMEZONE VARCHAR(64) NOT NULL,
+		NEWSLETTER BOOLEAN NOT NULL
+	)`,
+	// times are Unix nanoseconds, LOCKED_UNTIL 0 when the account isn't locked
+	`CREATE TABLE IF NOT EXISTS user_credentials (
+		USER_ID INTEGER PRIMARY KEY REFERENCES users(ID),
+		EMAIL VARCHAR(255) NOT NULL UNIQUE,
+		PASSWORD_HASH BLOB NOT NULL,
+		EMAIL_VERIFIED BOOLEAN NOT NULL DEFAULT 0,
+		FAILED_LOGINS INTEGER NOT NULL DEFAULT 0,
+		LOCKED_UNTIL INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE IF NOT EXISTS user_session (
+		TOKEN_HASH TEXT PRIMARY KEY,
+		USER_ID INTEGER NOT NULL REFERENCES users(ID),
+		EXPIRES_AT INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS user_session_user ON user_session (USER_ID)`,
+	`CREATE TABLE IF NOT EXISTS user_token (
+		TOKEN_HASH TEXT PRIMARY KEY,
+		USER_ID INTEGER NOT NULL REFERENCES users(ID),
+		PURPOSE VARCHAR(32) NOT NULL,
+		EXPIRES_AT INTEGER NOT NULL
+	)`,
+}
+
+// sortColumns maps a sort to the expression it orders by, with the collation foldASCII mirrors.
+var sortColumns = map[string]string{
+	"id":   "ID",
+	"name": "NAME COLLATE NOCASE",
+}
+
+// SQLiteStore keeps the users and what belongs to them in tables, so they survive a restart.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore creates the users tables in db, those of addresses, preferences and accounts
+// included, if they do not exist yet.
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	for _, ddl := range schema {
+		if _, err := db.Exec(ddl); err != nil {
+			return nil, fmt.Errorf("creating users tables: %w", err)
+		}
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// likePattern matches search anywhere in a value, with LIKE's wildcards in it taken literally.
+func likePattern(search string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(search) + "%"
+}
+
+func (s *SQLiteStore) List(ctx context.Context, q Query) (Page, error) {
+	var where []string
+	var args []any
+	if q.Search != "" {
+		where = append(where, `NAME LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(q.Search))
+	}
+
+	var page Page
+	countQuery := "SELECT COUNT(*) FROM users"
+	if len(where) > 0 {
+		countQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&page.Total); err != nil {
+		return Page{}, err
+	}
+
+	column, direction, after := sortColumns[q.Sort], "ASC", ">"
+	if q.Descending {
+		direction, after = "DESC", "<"
+	}
+	if q.After != nil {
+		if q.Sort == "id" {
+			where = append(where, "ID "+after+" ?")
+			args = append(args, q.After.ID)
+		} else {
+			where = append(where, fmt.Sprintf("(%s %s ? OR (%s = ? AND ID %s ?))", column, after, column, after))
+			args = append(args, q.After.Key, q.After.Key, q.After.ID)
+		}
+	}
+
+	query := "SELECT ID, NAME, EMAIL FROM users"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s", column, direction)
+	if q.Sort != "id" {
+		query += ", ID " + direction
+	}
+	// one row more than the page tells whether there is a next one
+	query += " LIMIT ? OFFSET ?"
+	offset := q.Offset
+	if q.After != nil {
+		offset = 0
+	}
+	args = append(args, q.Limit+1, offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	defer rows.Close()
+
+	page.Users = []User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
+			return Page{}, err
+		}
+		page.Users = append(page.Users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return Page{}, err
+	}
+
+	if len(page.Users) > q.Limit {
+		page.Users = page.Users[:q.Limit]
+		page.Next = q.cursor(page.Users[q.Limit-1])
+	}
+	return page, nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, id int) (User, error) {
+	user := User{ID: id}
+	err := s.db.QueryRowContext(ctx, "SELECT NAME, EMAIL FROM users WHERE ID = ?", id).Scan(&user.Name, &user.Email)
+	if err == sql.ErrNoRows {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+func (s *SQLiteStore) Create(ctx context.Context, user User) (User, error) {
+	result, err := s.db.ExecContext(ctx, "INSERT INTO users (NAME, EMAIL) VALUES (?, ?)", user.Name, user.Email)
+	if err != nil {
+		return User{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return User{}, err
+	}
+	user.ID = int(id)
+	return user, nil
+}
+
+func (s *SQLiteStore) Update(ctx context.Context, user User) (User, error) {
+	result, err := s.db.ExecContext(ctx, "UPDATE users SET NAME = ?, EMAIL = ? WHERE ID = ?", user.Name, user.Email, user.ID)
+	if err != nil {
+		return User{}, err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return User{}, err
+	} else if updated == 0 {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+// Delete removes the user with its addresses, preferences and account in one transaction.
+func (s *SQLiteStore) Delete(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE ID = ?", id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return ErrNotFound
+	}
+
+	for _, table := range []string{"user_address", "user_preferences", "user_credentials", "user_session", "user_token"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE USER_ID = ?", id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) ListAddresses(ctx context.Context, userID int) ([]Address, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT ID, USER_ID, LABEL, STREET, CITY, POSTAL_CODE, COUNTRY FROM user_address WHERE USER_ID = ? ORDER BY ID", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []Address{}
+	for rows.Next() {
+		var a Address
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Label, &a.Street, &a.City, &a.PostalCode, &a.Country); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses, rows.Err()
+}
+
+func (s *SQLiteStore) GetAddress(ctx context.Context, userID, id int) (Address, error) {
+	a := Address{ID: id, UserID: userID}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT LABEL, STREET, CITY, POSTAL_CODE, COUNTRY FROM user_address WHERE ID = ? AND USER_ID = ?", id, userID).
+		Scan(&a.Label, &a.Street, &a.City, &a.PostalCode, &a.Country)
+	if err == sql.ErrNoRows {
+		return Address{}, ErrAddressNotFound
+	}
+	return a, err
+}
+
+func (s *SQLiteStore) CreateAddress(ctx context.Context, a Address) (Address, error) {
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO user_address (USER_ID, LABEL, STREET, CITY, POSTAL_CODE, COUNTRY) VALUES (?, ?, ?, ?, ?, ?)",
+		a.UserID, a.Label, a.Street, a.City, a.PostalCode, a.Country)
+	if err != nil {
+		return Address{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Address{}, err
+	}
+	a.ID = int(id)
+	return a, nil
+}
+
+func (s *SQLiteStore) UpdateAddress(ctx context.Context, a Address) (Address, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE user_address SET LABEL = ?, STREET = ?, CITY = ?, POSTAL_CODE = ?, COUNTRY = ? WHERE ID = ? AND USER_ID = ?",
+		a.Label, a.Street, a.City, a.PostalCode, a.Country, a.ID, a.UserID)
+	if err != nil {
+		return Address{}, err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return Address{}, err
+	} else if updated == 0 {
+		return Address{}, ErrAddressNotFound
+	}
+	return a, nil
+}
+
+func (s *SQLiteStore) DeleteAddress(ctx context.Context, userID, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM user_address WHERE ID = ? AND USER_ID = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return ErrAddressNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetPreferences(ctx context.Context, userID int) (Preferences, error) {
+	prefs := Preferences{UserID: userID}
+	err := s.db.QueryRowContext(ctx,
+		"SELECT LANGUAGE, TIMEZONE, NEWSLETTER FROM user_preferences WHERE USER_ID = ?", userID).
+		Scan(&prefs.Language, &prefs.Timezone, &prefs.Newsletter)
+	if err == sql.ErrNoRows {
+		return DefaultPreferences(userID), nil
+	}
+	return prefs, err
+}
+
+func (s *SQLiteStore) PutPreferences(ctx context.Context, prefs Preferences) (Preferences, error) {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO user_preferences (USER_ID, LANGUAGE, TIMEZONE, NEWSLETTER) VALUES (?, ?, ?, ?)
+		ON CONFLICT (USER_ID) DO UPDATE SET LANGUAGE = excluded.LANGUAGE, TIMEZONE = excluded.TIMEZONE, NEWSLETTER = excluded.NEWSLETTER`,
+		prefs.UserID, prefs.Language, prefs.Timezone, prefs.Newsletter)
+	if err != nil {
+		return Preferences{}, err
+	}
+	return prefs, nil
+}
+
+func (s *SQLiteStore) DeletePreferences(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_preferences WHERE USER_ID = ?", userID)
+	return err
+}
+
+// credentialsColumns are the columns scanCredentials reads, in order.
+const credentialsColumns = "USER_ID, EMAIL, PASSWORD_HASH, EMAIL_VERIFIED, FAILED_LOGINS, LOCKED_UNTIL"
+
+func scanCredentials(row *sql.Row) (Credentials, error) {
+	var creds Credentials
+	var lockedUntil int64
+	err := row.Scan(&creds.UserID, &creds.Email, &creds.PasswordHash, &creds.EmailVerified, &creds.FailedLogins, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return Credentials{}, ErrNoAccount
+	}
+	if err != nil {
+		return Credentials{}, err
+	}
+	if lockedUntil != 0 {
+		creds.LockedUntil = time.Unix(0, lockedUntil)
+	}
+	return creds, nil
+}
+
+// unixNano is t in the columns' Unix nanoseconds, 0 for the zero time.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func (s *SQLiteStore) GetCredentials(ctx context.Context, email string) (Credentials, error) {
+	return scanCredentials(s.db.QueryRowContext(ctx, "SELECT "+credentialsColumns+" FROM user_credentials WHERE EMAIL = ?", email))
+}
+
+func (s *SQLiteStore) GetCredentialsByUser(ctx context.Context, userID int) (Credentials, error) {
+	return scanCredentials(s.db.QueryRowContext(ctx, "SELECT "+credentialsColumns+" FROM user_credentials WHERE USER_ID = ?", userID))
+}
+
+func (s *SQLiteStore) CreateCredentials(ctx context.Context, creds Credentials) error {
+	result, err := s.db.ExecContext(ctx, "INSERT INTO user_credentials ("+credentialsColumns+") VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING",
+		creds.UserID, creds.Email, creds.PasswordHash, creds.EmailVerified, creds.FailedLogins, unixNano(creds.LockedUntil))
+	if err != nil {
+		return err
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if inserted == 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+func (s *SQLiteStore) UpdateCredentials(ctx context.Context, creds Credentials) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE user_credentials SET PASSWORD_HASH = ?, EMAIL_VERIFIED = ?, FAILED_LOGINS = ?, LOCKED_UNTIL = ? WHERE USER_ID = ?",
+		creds.PasswordHash, creds.EmailVerified, creds.FailedLogins, unixNano(creds.LockedUntil), creds.UserID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return ErrNoAccount
+	}
+	return nil
+}
+
+// RecordFailedLogin counts and locks in a single UPDATE, which SQLite runs atomically. The
+// right-hand sides all see the row as it was; RETURNING sees it as it is, with the count back
+// at 0 only when this login locked the account.
+func (s *SQLiteStore) RecordFailedLogin(ctx context.Context, userID, max int, until time.Time) (bool, error) {
+	var failed int
+	err := s.db.QueryRowContext(ctx, `UPDATE user_credentials SET
+			FAILED_LOGINS = CASE WHEN FAILED_LOGINS + 1 >= ? THEN 0 ELSE FAILED_LOGINS + 1 END,
+			LOCKED_UNTIL = CASE WHEN FAILED_LOGINS + 1 >= ? THEN ? ELSE LOCKED_UNTIL END
+		WHERE USER_ID = ? RETURNING FAILED_LOGINS`,
+		max, max, unixNano(until), userID).Scan(&failed)
+	if err == sql.ErrNoRows {
+		return false, ErrNoAccount
+	}
+	if err != nil {
+		return false, err
+	}
+	return failed == 0, nil
+}
+
+func (s *SQLiteStore) CreateSession(ctx context.Context, session Session) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM user_session WHERE EXPIRES_AT <= ?", time.Now().UnixNano()); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "INSERT INTO user_session (TOKEN_HASH, USER_ID, EXPIRES_AT) VALUES (?, ?, ?)",
+		session.TokenHash, session.UserID, session.ExpiresAt.UnixNano())
+	return err
+}
+
+func (s *SQLiteStore) GetSession(ctx context.Context, tokenHash string) (Session, error) {
+	session := Session{TokenHash: tokenHash}
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx, "SELECT USER_ID, EXPIRES_AT FROM user_session WHERE TOKEN_HASH = ? AND EXPIRES_AT > ?",
+		tokenHash, time.Now().UnixNano()).Scan(&session.UserID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return Session{}, ErrNoSession
+	}
+	session.ExpiresAt = time.Unix(0, expiresAt)
+	return session, err
+}
+
+func (s *SQLiteStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_session WHERE TOKEN_HASH = ?", tokenHash)
+	return err
+}
+
+func (s *SQLiteStore) DeleteSessions(ctx context.Context, userID int, keep string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_session WHERE USER_ID = ? AND TOKEN_HASH <> ?", userID, keep)
+	return err
+}
+
+func (s *SQLiteStore) CreateToken(ctx context.Context, token Token) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM user_token WHERE EXPIRES_AT <= ?", time.Now().UnixNano()); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "INSERT INTO user_token (TOKEN_HASH, USER_ID, PURPOSE, EXPIRES_AT) VALUES (?, ?, ?, ?)",
+		token.TokenHash, token.UserID, token.Purpose, token.ExpiresAt.UnixNano())
+	return err
+}
+
+// ConsumeToken deletes the token with RETURNING, so of two requests using it only one gets it.
+func (s *SQLiteStore) ConsumeToken(ctx context.Context, tokenHash, purpose string) (Token, error) {
+	token := Token{TokenHash: tokenHash, Purpose: purpose}
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx, "DELETE FROM user_token WHERE TOKEN_HASH = ? AND PURPOSE = ? RETURNING USER_ID, EXPIRES_AT",
+		tokenHash, purpose).Scan(&token.UserID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return Token{}, ErrNoToken
+	}
+	if err != nil {
+		return Token{}, err
+	}
+	token.ExpiresAt = time.Unix(0, expiresAt)
+	if !token.ExpiresAt.After(time.Now()) {
+		return Token{}, ErrNoToken
+	}
+	return token, nil
+}
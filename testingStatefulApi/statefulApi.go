@@ -1,11 +1,28 @@
 package testingstatefulapi
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"sync"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/idempotency"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+	"github.com/Dav16Akin/go-dictionary/logging"
+	"github.com/Dav16Akin/go-dictionary/metrics"
+	"github.com/Dav16Akin/go-dictionary/problem"
+	"github.com/Dav16Akin/go-dictionary/ratelimit"
+	"github.com/Dav16Akin/go-dictionary/tracing"
+	"github.com/justinas/alice"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 type User struct {
@@ -15,94 +32,308 @@ type User struct {
 }
 
 var (
-	users = make(map[int]User)
-	idSeq = 1
-	mutex = &sync.Mutex{}
+	// DB is the users database, nil while the users are kept in memory.
+	DB    *sql.DB
+	store Store = NewMemoryStore()
+	// keys remembers Idempotency-Keys wherever the users are kept
+	keys idempotency.Store = idempotency.NewMemoryStore()
 )
 
-func usersHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	switch r.Method {
-	case "GET":
-		mutex.Lock()
-		defer mutex.Unlock()
+var (
+	// userFields are the fields ?fields= can select of one's own user.
+	userFields = []string{"id", "name", "email"}
+	// publicFields are those any logged in user may read of the others.
+	publicFields = []string{"id", "name"}
+)
 
-		userList := make([]User, 0, len(users))
-		for _, user := range users {
-			userList = append(userList, user)
+// parseFields reads ?fields=name,email, any of allowed, nil when every field is wanted.
+func parseFields(value string, allowed []string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	fields := strings.Split(value, ",")
+	for _, field := range fields {
+		if !slices.Contains(allowed, field) {
+			return nil, fmt.Errorf("fields may only name %s, got %q", strings.Join(allowed, ", "), field)
 		}
+	}
+	return fields, nil
+}
 
-		json.NewEncoder(w).Encode(userList)
-	case "POST":
-		var user User
-		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-			http.Error(w, "Invalid Json", http.StatusBadRequest)
-			return
+// selectFields returns user with only fields, or the whole user when fields is nil.
+func selectFields(user User, fields []string) any {
+	if fields == nil {
+		return user
+	}
+	selected := make(map[string]any, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "id":
+			selected[field] = user.ID
+		case "name":
+			selected[field] = user.Name
+		case "email":
+			selected[field] = user.Email
 		}
+	}
+	return selected
+}
 
-		mutex.Lock()
-		user.ID = idSeq
-		idSeq++
-		users[user.ID] = user
-		mutex.Unlock()
-
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(user)
+// storeError answers a failed store call, 404 for a missing user or address and 500 otherwise.
+func storeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		problem.Write(w, r, http.StatusNotFound, "User Not Found")
+	case errors.Is(err, ErrAddressNotFound):
+		problem.Write(w, r, http.StatusNotFound, "Address Not Found")
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		slog.ErrorContext(r.Context(), "users store failed", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, "users store failed")
 	}
 }
 
-func userHandler(w http.ResponseWriter, r *http.Request) {
+// writeJSON writes v as the JSON response body with status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// pathID reads the numeric path value name, the ID of a kind of resource, answering the
+// request itself when it isn't one.
+func pathID(w http.ResponseWriter, r *http.Request, name, kind string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid "+kind+" ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// requireOwner reads the {id} of the path, a user whose own data the request changes or reads,
+// and checks that the request is logged in as that user. It answers the request itself with 401
+// without a session and 403 with another user's.
+func requireOwner(w http.ResponseWriter, r *http.Request) (int, bool) {
+	session, ok := authenticate(w, r)
+	if !ok {
+		return 0, false
+	}
+	id, ok := pathID(w, r, "id", "User")
+	if !ok {
+		return 0, false
+	}
+	// deleting a user deletes its sessions, so the session's user exists
+	if session.UserID != id {
+		problem.Write(w, r, http.StatusForbidden, "Not Your Account")
+		return 0, false
+	}
+	return id, true
+}
+
+// listUsers answers GET /users with a page of users, their public fields only. X-Total-Count
+// holds the number of users the search matched, and Link the URL of the next page, by cursor.
+func listUsers(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authenticate(w, r); !ok {
+		return
+	}
+	query, err := ParseQuery(r.URL.Query())
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields, err := parseFields(r.URL.Query().Get("fields"), publicFields)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if fields == nil {
+		fields = publicFields
+	}
+
+	page, err := store.List(r.Context(), query)
+	if err != nil {
+		storeError(w, r, err)
+		return
+	}
+
+	userList := make([]any, 0, len(page.Users))
+	for _, user := range page.Users {
+		userList = append(userList, selectFields(user, fields))
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.Next != nil {
+		next := r.URL.Query()
+		next.Del("offset")
+		next.Set("cursor", page.Next.Encode())
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	writeJSON(w, http.StatusOK, userList)
+}
+
+// getUser answers with the whole user to the user itself, and its public fields to the others.
+func getUser(w http.ResponseWriter, r *http.Request) {
+	session, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r, "id", "User")
+	if !ok {
+		return
+	}
+	allowed := publicFields
+	if session.UserID == id {
+		allowed = userFields
+	}
+	fields, err := parseFields(r.URL.Query().Get("fields"), allowed)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if fields == nil && session.UserID != id {
+		fields = publicFields
+	}
 
-	var id int
-	_, err := fmt.Sscanf(r.URL.Path, "/users/%d", &id)
+	user, err := store.Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, "Invalid User ID", http.StatusBadRequest)
+		storeError(w, r, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, selectFields(user, fields))
+}
 
-	mutex.Lock()
-	defer mutex.Unlock()
+func updateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
 
-	user, exits := users[id]
+	var updatedUser User
+	if err := json.NewDecoder(r.Body).Decode(&updatedUser); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid Json")
+		return
+	}
 
-	if !exits {
-		http.Error(w, "User Not Found", http.StatusNotFound)
+	updatedUser.ID = id
+	updatedUser, err := store.Update(r.Context(), updatedUser)
+	if err != nil {
+		storeError(w, r, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, updatedUser)
+}
 
-	switch r.Method {
-	case "GET":
-		json.NewEncoder(w).Encode(user)
+// deleteUser deletes the user with its addresses and preferences.
+func deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := requireOwner(w, r)
+	if !ok {
+		return
+	}
 
-	case "PUT":
-		var updatedUser User
-		if err := json.NewDecoder(r.Body).Decode(&updatedUser); err != nil {
-			http.Error(w, "Invalid Json", http.StatusBadRequest)
-			return
-		}
+	if err := store.Delete(r.Context(), id); err != nil {
+		storeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-		updatedUser.ID = id
-		users[id] = updatedUser
-		json.NewEncoder(w).Encode(updatedUser)
+// Open keeps the users in the SQLite database at dbPath, or in memory when dbPath is empty.
+func Open(dbPath string) error {
+	if dbPath == "" {
+		return nil
+	}
 
-	case "DELETE":
-		delete(users, id)
-		w.WriteHeader(http.StatusNoContent)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	var err error
+	DB, err = tracing.OpenDB("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+
+	if err = DB.Ping(); err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+
+	sqliteStore, err := NewSQLiteStore(DB)
+	if err != nil {
+		return err
 	}
+	store = sqliteStore
+	if keys, err = idempotency.NewSQLiteStore(DB); err != nil {
+		return err
+	}
+	metrics.RegisterDB("users", DB)
+	return nil
+}
+
+// AddHealthChecks registers the users database check on a readiness checker, when there is a database.
+func AddHealthChecks(checker *health.Checker) {
+	if DB != nil {
+		checker.AddReadinessCheck("users database", health.DBPing(DB))
+	}
+}
+
+// NewHandler returns a mux serving the users API.
+func NewHandler() http.Handler {
+	// method and path patterns: other paths are 404, other methods 405 with an Allow header
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users", listUsers)
+	mux.HandleFunc("GET /users/{id}", getUser)
+	mux.HandleFunc("PUT /users/{id}", updateUser)
+	mux.HandleFunc("DELETE /users/{id}", deleteUser)
+
+	mux.HandleFunc("GET /users/{id}/addresses", listAddresses)
+	mux.HandleFunc("POST /users/{id}/addresses", createAddress)
+	mux.HandleFunc("GET /users/{id}/addresses/{addressID}", getAddress)
+	mux.HandleFunc("PUT /users/{id}/addresses/{addressID}", updateAddress)
+	mux.HandleFunc("DELETE /users/{id}/addresses/{addressID}", deleteAddress)
+
+	mux.HandleFunc("GET /users/{id}/preferences", getPreferences)
+	mux.HandleFunc("PUT /users/{id}/preferences", putPreferences)
+	mux.HandleFunc("PATCH /users/{id}/preferences", patchPreferences)
+	mux.HandleFunc("DELETE /users/{id}/preferences", deletePreferences)
+
+	mux.HandleFunc("POST /accounts/register", register)
+	mux.HandleFunc("POST /accounts/login", login)
+	mux.HandleFunc("POST /accounts/logout", logout)
+	mux.HandleFunc("GET /accounts/me", getAccount)
+	mux.HandleFunc("POST /accounts/password", changePassword)
+	mux.HandleFunc("POST /accounts/verify-email/request", requestVerification)
+	mux.HandleFunc("POST /accounts/verify-email", verifyEmail)
+	mux.HandleFunc("POST /accounts/password-reset/request", requestPasswordReset)
+	mux.HandleFunc("POST /accounts/password-reset", resetPassword)
+
+	// account requests carry passwords and their answers sessions, neither belongs in the store of
+	// idempotent responses; a retried registration or login does no harm anyway
+	guarded := idempotency.ForService("users", keys).Middleware(mux)
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/accounts/") {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		guarded.ServeHTTP(w, r)
+	})
+	return logging.Middleware(ratelimit.ForService("users").Middleware(routed))
 }
 
-func Run(port string) {
-	http.HandleFunc("/users", usersHandler)
-	http.HandleFunc("/users/", userHandler)
+func Run(cfg config.Users, lc config.Lifecycle) error {
+	if err := Open(cfg.DBPath); err != nil {
+		return err
+	}
+
+	m := lifecycle.New(lc)
+
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
+	AddHealthChecks(checker)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", alice.New(tracing.Middleware("users"), metrics.Middleware("users")).Then(NewHandler()))
+	checker.RegisterServeMux(mux)
+	metrics.RegisterServeMux(mux)
 
-	fmt.Println("Server running on Port 8080...")
-	if err := http.ListenAndServe(port, nil); err != nil {
-		log.Fatal(err)
+	m.AddServer("users", &http.Server{Addr: cfg.Addr, Handler: mux})
+	if DB != nil {
+		m.AddCloser("users database", DB)
 	}
+	return m.Run(context.Background())
 }
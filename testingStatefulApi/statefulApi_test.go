@@ -0,0 +1,323 @@
+package testingstatefulapi
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dav16Akin/go-dictionary/apitest"
+)
+
+func TestMain(m *testing.M) {
+	apitest.Setup()
+	os.Exit(m.Run())
+}
+
+// forEachStore runs test against an empty memory store and an empty SQLite store, which must
+// answer alike.
+func forEachStore(t *testing.T, test func(t *testing.T, srv *apitest.Server)) {
+	t.Run("memory", func(t *testing.T) {
+		store = NewMemoryStore()
+		test(t, apitest.NewServer(t, NewHandler()))
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := sql.Open("sqlite3", apitest.TempDB(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if store, err = NewSQLiteStore(db); err != nil {
+			t.Fatal(err)
+		}
+		test(t, apitest.NewServer(t, NewHandler()))
+	})
+	store = NewMemoryStore()
+}
+
+// step is one request of a table-driven test. want is the JSON body, detail that of a problem;
+// either is only checked when set.
+type step struct {
+	name   string
+	method string
+	path   string
+	body   any
+	status int
+	want   string
+	detail string
+}
+
+// run sends the steps in order, each seeing what the ones before it did, with header added to
+// every request.
+func run(t *testing.T, srv *apitest.Server, steps []step, header ...string) {
+	t.Helper()
+	for _, s := range steps {
+		t.Run(s.name, func(t *testing.T) {
+			resp := srv.Do(t, s.method, s.path, s.body, header...).Status(t, s.status)
+			if s.want != "" {
+				resp.JSON(t, s.want)
+			}
+			if s.detail != "" {
+				resp.Problem(t, s.detail)
+			}
+		})
+	}
+}
+
+// createUsers adds users with names to the store, skipping the accounts API.
+func createUsers(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if _, err := store.Create(t.Context(), User{Name: name, Email: strings.ToLower(name) + "@x.io"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// logIn makes a session for userID straight in the store and returns the header carrying its token.
+func logIn(t *testing.T, userID int) []string {
+	t.Helper()
+	token := rand.Text()
+	session := Session{TokenHash: hashToken(token), UserID: userID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := store.CreateSession(t.Context(), session); err != nil {
+		t.Fatal(err)
+	}
+	return bearer(token)
+}
+
+func TestUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		ann := signUp(t, srv, "Ann", "ann@example.com")
+		bob := signUp(t, srv, "Bob", "bob@example.com")
+
+		run(t, srv, []step{
+			{"list without login", "GET", "/users", nil, 401, "", "Login Required"},
+			{"get without login", "GET", "/users/1", nil, 401, "", "Login Required"},
+			{"update without login", "PUT", "/users/1", User{Name: "Mallory"}, 401, "", "Login Required"},
+			{"delete without login", "DELETE", "/users/1", nil, 401, "", "Login Required"},
+			// users are only created by registering
+			{"create", "POST", "/users", User{Name: "Cat", Email: "cat@example.com"}, 405, "", ""},
+		})
+		run(t, srv, []step{
+			{"get", "GET", "/users/1", nil, 200, `{"id":1,"name":"Ann","email":"ann@example.com"}`, ""},
+			{"get fields", "GET", "/users/1?fields=name,email", nil, 200, `{"name":"Ann","email":"ann@example.com"}`, ""},
+			{"get unknown field", "GET", "/users/1?fields=age", nil, 400, "", ""},
+			// another user's email is private
+			{"get another user", "GET", "/users/2", nil, 200, `{"id":2,"name":"Bob"}`, ""},
+			{"get email of another user", "GET", "/users/2?fields=email", nil, 400, "", "fields may only name id, name, got \"email\""},
+			{"get missing", "GET", "/users/9", nil, 404, "", "User Not Found"},
+			{"get bad id", "GET", "/users/one", nil, 400, "", "Invalid User ID"},
+			{"list", "GET", "/users", nil, 200, `[{"id":1,"name":"Ann"},{"id":2,"name":"Bob"}]`, ""},
+			{"list emails", "GET", "/users?fields=name,email", nil, 400, "", "fields may only name id, name, got \"email\""},
+			{"update", "PUT", "/users/1", User{Name: "Ann Lee", Email: "ann@example.com"}, 200, `{"id":1,"name":"Ann Lee","email":"ann@example.com"}`, ""},
+			{"update another user", "PUT", "/users/2", User{Name: "Mallory"}, 403, "", "Not Your Account"},
+			{"update missing", "PUT", "/users/9", User{Name: "X"}, 403, "", "Not Your Account"},
+			{"update bad id", "PUT", "/users/one", User{Name: "X"}, 400, "", "Invalid User ID"},
+			{"update invalid json", "PUT", "/users/1", "[", 400, "", "Invalid Json"},
+			{"delete another user", "DELETE", "/users/2", nil, 403, "", "Not Your Account"},
+		}, ann...)
+		run(t, srv, []step{
+			{"delete", "DELETE", "/users/2", nil, 204, "", ""},
+			// the user's sessions went with it
+			{"delete again", "DELETE", "/users/2", nil, 401, "", "Login Required"},
+		}, bob...)
+		run(t, srv, []step{
+			{"list", "GET", "/users", nil, 200, `[{"id":1,"name":"Ann Lee"}]`, ""},
+			{"method not allowed", "PATCH", "/users/1", nil, 405, "", ""},
+			{"unknown path", "GET", "/users/1/anything", nil, 404, "", ""},
+		}, ann...)
+	})
+}
+
+func TestListUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		createUsers(t, "dave", "Ann", "carol", "bob", "Eve")
+		dave := logIn(t, 1)
+
+		tests := []struct {
+			name  string
+			query string
+			want  string
+			total string
+		}{
+			{"default order", "", `[{"name":"dave"},{"name":"Ann"},{"name":"carol"},{"name":"bob"},{"name":"Eve"}]`, "5"},
+			{"by name ignoring case", "?sort=name", `[{"name":"Ann"},{"name":"bob"},{"name":"carol"},{"name":"dave"},{"name":"Eve"}]`, "5"},
+			{"by name descending", "?sort=-name&limit=2", `[{"name":"Eve"},{"name":"dave"}]`, "5"},
+			{"offset", "?sort=name&limit=2&offset=2", `[{"name":"carol"},{"name":"dave"}]`, "5"},
+			{"offset past the end", "?offset=10", `[]`, "5"},
+			{"search", "?q=A&sort=name", `[{"name":"Ann"},{"name":"carol"},{"name":"dave"}]`, "3"},
+			// emails are private, so they aren't searched
+			{"search ignores emails", "?q=x.io", `[]`, "0"},
+			{"search wildcard taken literally", "?q=%25", `[]`, "0"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				sep := "?"
+				if tt.query != "" {
+					sep = "&"
+				}
+				resp := srv.Do(t, "GET", "/users"+tt.query+sep+"fields=name", nil, dave...).Status(t, 200)
+				resp.JSON(t, tt.want)
+				if got := resp.Header.Get("X-Total-Count"); got != tt.total {
+					t.Errorf("X-Total-Count = %s, want %s", got, tt.total)
+				}
+			})
+		}
+
+		for _, query := range []string{"?sort=age", "?sort=email", "?limit=0", "?limit=101", "?offset=-1", "?cursor=abc", "?offset=1&cursor=e30"} {
+			t.Run("rejects "+query, func(t *testing.T) {
+				srv.Do(t, "GET", "/users"+query, nil, dave...).Status(t, 400)
+			})
+		}
+	})
+}
+
+var nextLink = regexp.MustCompile(`^<([^>]+)>; rel="next"$`)
+
+func TestListUsersCursor(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		createUsers(t, "b", "a", "c", "a", "b")
+		b := logIn(t, 1)
+
+		// duplicate names are ordered by ID, so following the links visits every user once
+		var ids []int
+		path := "/users?sort=name&limit=2"
+		for pages := 0; path != ""; pages++ {
+			if pages > 5 {
+				t.Fatal("Link never ends")
+			}
+			resp := srv.Do(t, "GET", path, nil, b...).Status(t, 200)
+			var users []User
+			resp.Decode(t, &users)
+			for _, user := range users {
+				ids = append(ids, user.ID)
+			}
+
+			path = ""
+			if link := resp.Header.Get("Link"); link != "" {
+				match := nextLink.FindStringSubmatch(link)
+				if match == nil {
+					t.Fatalf("Link = %q", link)
+				}
+				path = match[1]
+			}
+		}
+
+		want := []int{2, 4, 1, 5, 3}
+		if len(ids) != len(want) {
+			t.Fatalf("ids = %v, want %v", ids, want)
+		}
+		for i := range want {
+			if ids[i] != want[i] {
+				t.Fatalf("ids = %v, want %v", ids, want)
+			}
+		}
+
+		// a cursor made for one order is refused for another
+		resp := srv.Do(t, "GET", "/users?sort=name&limit=1", nil, b...).Status(t, 200)
+		link := nextLink.FindStringSubmatch(resp.Header.Get("Link"))[1]
+		srv.Do(t, "GET", strings.Replace(link, "sort=name", "sort=-name", 1), nil, b...).Status(t, 400)
+	})
+}
+
+func TestAddresses(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		ann := signUp(t, srv, "Ann", "ann@example.com")
+		bob := signUp(t, srv, "Bob", "bob@example.com")
+
+		home := Address{Label: "home", Street: "1 Main St", City: "Springfield", PostalCode: "12345", Country: "US"}
+		run(t, srv, []step{
+			{"list empty", "GET", "/users/1/addresses", nil, 200, `[]`, ""},
+			{"create", "POST", "/users/1/addresses", home, 201,
+				`{"id":1,"user_id":1,"label":"home","street":"1 Main St","city":"Springfield","postal_code":"12345","country":"US"}`, ""},
+			{"create incomplete", "POST", "/users/1/addresses", Address{Street: "2 Side St"}, 422, "", "address needs city, country"},
+			{"create for another user", "POST", "/users/2/addresses", home, 403, "", "Not Your Account"},
+			{"create for missing user", "POST", "/users/9/addresses", home, 403, "", "Not Your Account"},
+			{"get", "GET", "/users/1/addresses/1", nil, 200,
+				`{"id":1,"user_id":1,"label":"home","street":"1 Main St","city":"Springfield","postal_code":"12345","country":"US"}`, ""},
+			{"get bad id", "GET", "/users/1/addresses/x", nil, 400, "", "Invalid Address ID"},
+			{"update", "PUT", "/users/1/addresses/1", Address{Label: "work", Street: "9 Office Rd", City: "Shelbyville", Country: "US"}, 200,
+				`{"id":1,"user_id":1,"label":"work","street":"9 Office Rd","city":"Shelbyville","postal_code":"","country":"US"}`, ""},
+			{"list", "GET", "/users/1/addresses", nil, 200,
+				`[{"id":1,"user_id":1,"label":"work","street":"9 Office Rd","city":"Shelbyville","postal_code":"","country":"US"}]`, ""},
+		}, ann...)
+		run(t, srv, []step{
+			{"list without login", "GET", "/users/1/addresses", nil, 401, "", "Login Required"},
+			{"get without login", "GET", "/users/1/addresses/1", nil, 401, "", "Login Required"},
+			{"delete without login", "DELETE", "/users/1/addresses/1", nil, 401, "", "Login Required"},
+		})
+		run(t, srv, []step{
+			{"list as another user", "GET", "/users/1/addresses", nil, 403, "", "Not Your Account"},
+			{"update as another user", "PUT", "/users/1/addresses/1", home, 403, "", "Not Your Account"},
+			// an address ID of another user is not found under one's own
+			{"get of another user", "GET", "/users/2/addresses/1", nil, 404, "", "Address Not Found"},
+			{"update of another user", "PUT", "/users/2/addresses/1", home, 404, "", "Address Not Found"},
+			{"delete of another user", "DELETE", "/users/2/addresses/1", nil, 404, "", "Address Not Found"},
+		}, bob...)
+		run(t, srv, []step{
+			{"delete", "DELETE", "/users/1/addresses/1", nil, 204, "", ""},
+			{"deleted", "GET", "/users/1/addresses/1", nil, 404, "", "Address Not Found"},
+			{"create again", "POST", "/users/1/addresses", home, 201, "", ""},
+			{"delete user", "DELETE", "/users/1", nil, 204, "", ""},
+			{"addresses of deleted user", "GET", "/users/1/addresses", nil, 401, "", "Login Required"},
+		}, ann...)
+	})
+}
+
+func TestPreferences(t *testing.T) {
+	forEachStore(t, func(t *testing.T, srv *apitest.Server) {
+		ann := signUp(t, srv, "Ann", "ann@example.com")
+		bob := signUp(t, srv, "Bob", "bob@example.com")
+
+		run(t, srv, []step{
+			{"defaults", "GET", "/users/1/preferences", nil, 200, `{"user_id":1,"language":"en","timezone":"UTC","newsletter":false}`, ""},
+			{"another user", "GET", "/users/2/preferences", nil, 403, "", "Not Your Account"},
+			{"missing user", "GET", "/users/9/preferences", nil, 403, "", "Not Your Account"},
+			{"put", "PUT", "/users/1/preferences", map[string]any{"language": "fr", "timezone": "Europe/Paris"}, 200,
+				`{"user_id":1,"language":"fr","timezone":"Europe/Paris","newsletter":false}`, ""},
+			{"put fills in defaults", "PUT", "/users/1/preferences", map[string]any{"newsletter": true}, 200,
+				`{"user_id":1,"language":"en","timezone":"UTC","newsletter":true}`, ""},
+			{"patch", "PATCH", "/users/1/preferences", map[string]any{"timezone": "America/Chicago"}, 200,
+				`{"user_id":1,"language":"en","timezone":"America/Chicago","newsletter":true}`, ""},
+			{"patch bad timezone", "PATCH", "/users/1/preferences", map[string]any{"timezone": "Mars/Olympus"}, 422, "",
+				`timezone "Mars/Olympus" is not an IANA time zone`},
+			{"patch empty language", "PATCH", "/users/1/preferences", map[string]any{"language": " "}, 422, "", "language is required"},
+			{"patch invalid json", "PATCH", "/users/1/preferences", "{", 400, "", "Invalid Json"},
+		}, ann...)
+		run(t, srv, []step{
+			{"get without login", "GET", "/users/1/preferences", nil, 401, "", "Login Required"},
+			{"patch without login", "PATCH", "/users/1/preferences", map[string]any{"newsletter": false}, 401, "", "Login Required"},
+		})
+		run(t, srv, []step{
+			{"get as another user", "GET", "/users/1/preferences", nil, 403, "", "Not Your Account"},
+			{"patch as another user", "PATCH", "/users/1/preferences", map[string]any{"newsletter": false}, 403, "", "Not Your Account"},
+			{"reset as another user", "DELETE", "/users/1/preferences", nil, 403, "", "Not Your Account"},
+		}, bob...)
+		run(t, srv, []step{
+			{"unchanged by rejected patches", "GET", "/users/1/preferences", nil, 200,
+				`{"user_id":1,"language":"en","timezone":"America/Chicago","newsletter":true}`, ""},
+			{"reset", "DELETE", "/users/1/preferences", nil, 204, "", ""},
+			{"defaults again", "GET", "/users/1/preferences", nil, 200, `{"user_id":1,"language":"en","timezone":"UTC","newsletter":false}`, ""},
+		}, ann...)
+	})
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	store = NewMemoryStore()
+	srv := apitest.NewServer(t, NewHandler())
+	createUsers(t, "Ann")
+	ann := logIn(t, 1)
+
+	home := Address{Street: "1 Main St", City: "Springfield", Country: "US"}
+	key := append([]string{"Idempotency-Key", "create-home"}, ann...)
+	first := srv.Do(t, "POST", "/users/1/addresses", home, key...).Status(t, http.StatusCreated)
+	retry := srv.Do(t, "POST", "/users/1/addresses", home, key...).Status(t, http.StatusCreated)
+	apitest.EqualJSON(t, retry.Body, string(first.Body))
+
+	srv.Do(t, "GET", "/users/1/addresses", nil, ann...).Status(t, 200).
+		JSON(t, `[{"id":1,"user_id":1,"label":"","street":"1 Main St","city":"Springfield","postal_code":"","country":"US"}]`)
+}
@@ -0,0 +1,213 @@
+package testingstatefulapi
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+var (
+	// ErrNotFound is returned by a Store for a user ID it does not hold.
+	ErrNotFound = errors.New("user not found")
+	// ErrAddressNotFound is returned for an address ID the user does not have.
+	ErrAddressNotFound = errors.New("address not found")
+	// ErrNoAccount is returned for an email or user no credentials are kept for.
+	ErrNoAccount = errors.New("account not found")
+	// ErrEmailTaken is returned when creating credentials for an email another account logs in with.
+	ErrEmailTaken = errors.New("email already registered")
+	// ErrNoSession is returned for a session token that is unknown, logged out or expired.
+	ErrNoSession = errors.New("session not found")
+	// ErrNoToken is returned for an email token that is unknown, used or expired.
+	ErrNoToken = errors.New("token not found")
+)
+
+// Store keeps the users with their addresses and preferences. The memory and SQLite stores
+// list, search and page users the same way, so clients can't tell which one is behind the API.
+// Deleting a user deletes what belongs to it, its account included.
+type Store interface {
+	List(ctx context.Context, q Query) (Page, error)
+	Get(ctx context.Context, id int) (User, error)
+	Create(ctx context.Context, user User) (User, error)
+	Update(ctx context.Context, user User) (User, error)
+	Delete(ctx context.Context, id int) error
+
+	// the address methods leave checking that the user exists to the caller
+	ListAddresses(ctx context.Context, userID int) ([]Address, error)
+	GetAddress(ctx context.Context, userID, id int) (Address, error)
+	CreateAddress(ctx context.Context, address Address) (Address, error)
+	UpdateAddress(ctx context.Context, address Address) (Address, error)
+	DeleteAddress(ctx context.Context, userID, id int) error
+
+	// GetPreferences returns DefaultPreferences for a user that never saved any.
+	GetPreferences(ctx context.Context, userID int) (Preferences, error)
+	PutPreferences(ctx context.Context, prefs Preferences) (Preferences, error)
+	DeletePreferences(ctx context.Context, userID int) error
+
+	// the account methods key credentials by the lowercased email, and sessions and tokens by
+	// the hash of the token; expired sessions and tokens are as good as gone
+	GetCredentials(ctx context.Context, email string) (Credentials, error)
+	GetCredentialsByUser(ctx context.Context, userID int) (Credentials, error)
+	CreateCredentials(ctx context.Context, creds Credentials) error
+	UpdateCredentials(ctx context.Context, creds Credentials) error
+	// RecordFailedLogin counts a wrong password in one step, so that concurrent logins can't
+	// lose a count. The max-th one in a row locks the account until until and starts the count
+	// over; locked reports whether this one did.
+	RecordFailedLogin(ctx context.Context, userID, max int, until time.Time) (locked bool, err error)
+	CreateSession(ctx context.Context, session Session) error
+	GetSession(ctx context.Context, tokenHash string) (Session, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	// DeleteSessions logs the user out everywhere but the session hashed as keep.
+	DeleteSessions(ctx context.Context, userID int, keep string) error
+	CreateToken(ctx context.Context, token Token) error
+	// ConsumeToken returns a token of purpose and deletes it, so it is only used once.
+	ConsumeToken(ctx context.Context, tokenHash, purpose string) (Token, error)
+}
+
+// Query selects a page of users. Search matches the name, ignoring ASCII case; emails are not
+// public, so they are neither searched nor sorted by. Users are ordered by Sort, ignoring ASCII case, then by ID so the order is total. After, when set, starts the
+// page behind the user it points at instead of at Offset.
+type Query struct {
+	Search     string
+	Sort       string
+	Descending bool
+	Limit      int
+	Offset     int
+	After      *Cursor
+}
+
+// Page is one page of users with the number of users the search matched.
+// Next points behind the last user of the page, it is nil on the last page.
+type Page struct {
+	Users []User
+	Total int
+	Next  *Cursor
+}
+
+// Cursor is the position of a user in a sort order: the order, the user's sort key and ID.
+type Cursor struct {
+	Order string `json:"o"`
+	Key   string `json:"k"`
+	ID    int    `json:"id"`
+}
+
+// Encode returns the cursor as the opaque token clients pass back in ?cursor=.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a token made by Cursor.Encode.
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.New("cursor is malformed")
+	}
+	return &c, nil
+}
+
+// ParseQuery reads q, sort, limit, offset and cursor. sort is id or name, with a
+// leading "-" for descending order. offset and cursor can't be used together.
+func ParseQuery(values url.Values) (Query, error) {
+	q := Query{Search: values.Get("q"), Sort: "id", Limit: defaultLimit}
+
+	if v := values.Get("sort"); v != "" {
+		q.Sort, q.Descending = strings.CutPrefix(v, "-")
+		if q.Sort != "id" && q.Sort != "name" {
+			return q, fmt.Errorf("sort must be id or name, optionally prefixed with -, got %q", v)
+		}
+	}
+
+	var err error
+	if v := values.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 || q.Limit > maxLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d, got %q", maxLimit, v)
+		}
+	}
+	if v := values.Get("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+			return q, fmt.Errorf("offset must be a non-negative integer, got %q", v)
+		}
+	}
+	if v := values.Get("cursor"); v != "" {
+		if values.Has("offset") {
+			return q, errors.New("offset and cursor can't be used together")
+		}
+		if q.After, err = DecodeCursor(v); err != nil {
+			return q, err
+		}
+		if q.After.Order != q.order() {
+			return q, fmt.Errorf("cursor was made for sort=%s, not sort=%s", q.After.Order, q.order())
+		}
+	}
+	return q, nil
+}
+
+// order returns the sort as it is written in ?sort=.
+func (q Query) order() string {
+	if q.Descending {
+		return "-" + q.Sort
+	}
+	return q.Sort
+}
+
+// key is the value users are ordered by, folded the way the SQLite NOCASE collation folds it.
+func (q Query) key(user User) string {
+	if q.Sort == "name" {
+		return foldASCII(user.Name)
+	}
+	return ""
+}
+
+// cursor returns the position of user in the order of q.
+func (q Query) cursor(user User) *Cursor {
+	return &Cursor{Order: q.order(), Key: q.key(user), ID: user.ID}
+}
+
+// compare orders a before b, by sort key then ID, both reversed when descending.
+func (q Query) compare(a, b User) int {
+	c := cmp.Or(cmp.Compare(q.key(a), q.key(b)), cmp.Compare(a.ID, b.ID))
+	if q.Descending {
+		return -c
+	}
+	return c
+}
+
+// behind reports whether user comes after the cursor in the order of q.
+func (q Query) behind(user User) bool {
+	return q.compare(user, User{ID: q.After.ID, Name: q.After.Key}) > 0
+}
+
+// matches reports whether user matches the search, the way SQLite's LIKE does.
+func (q Query) matches(user User) bool {
+	if q.Search == "" {
+		return true
+	}
+	return strings.Contains(foldASCII(user.Name), foldASCII(q.Search))
+}
+
+// foldASCII lower-cases ASCII letters only, like SQLite's NOCASE collation and LIKE, so both
+// stores agree on order and matches for any name.
+func foldASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
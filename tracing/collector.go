@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+	"github.com/Dav16Akin/go-dictionary/health"
+	"github.com/Dav16Akin/go-dictionary/lifecycle"
+)
+
+// maxExportBody bounds one OTLP request, larger batches are refused with 413.
+const maxExportBody = 8 << 20
+
+// Collector is a local stand-in for an OTLP collector. It accepts OTLP/HTTP JSON on
+// POST /v1/traces, logs every span and optionally appends them to a JSON lines file,
+// in the same format the file exporter writes.
+type Collector struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewCollector writes received spans to out, which may be nil to only log them.
+func NewCollector(out io.Writer) *Collector {
+	c := &Collector{}
+	if out != nil {
+		c.encoder = json.NewEncoder(out)
+	}
+	return c
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		// the protobuf encoding is what real collectors default to, exporters must be set to JSON
+		http.Error(w, "only OTLP JSON (application/json) is supported", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var request otlpRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxExportBody)).Decode(&request); err != nil {
+		status := http.StatusBadRequest
+		if _, ok := err.(*http.MaxBytesError); ok {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, "invalid OTLP request: "+err.Error(), status)
+		return
+	}
+
+	records := decodeOTLP(request)
+	for _, record := range records {
+		slog.Info("span",
+			"service", record.Service,
+			"trace_id", record.TraceID,
+			"span_id", record.SpanID,
+			"parent_span_id", record.ParentSpanID,
+			"name", record.Name,
+			"kind", record.Kind,
+			"duration", record.Duration(),
+			"error", record.Error,
+		)
+	}
+
+	if err := c.write(records); err != nil {
+		slog.Error("Error writing spans", "err", err)
+		http.Error(w, "could not store spans", http.StatusInternalServerError)
+		return
+	}
+
+	// an empty ExportTraceServiceResponse: everything was accepted
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, "{}")
+}
+
+func (c *Collector) write(records []Record) error {
+	if c.encoder == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, record := range records {
+		if err := c.encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunCollector serves the collector stand-in until SIGINT/SIGTERM.
+func RunCollector(cfg config.TraceCollector, lc config.Lifecycle) error {
+	m := lifecycle.New(lc)
+
+	var out io.Writer
+	if cfg.Output != "" {
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("opening collector output: %w", err)
+		}
+		m.AddCloser("collector output", file)
+		out = file
+	}
+
+	checker := health.New()
+	checker.AddReadinessCheck("lifecycle", health.Ready(m))
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /v1/traces", NewCollector(out))
+	checker.RegisterServeMux(mux)
+
+	m.AddServer("trace-collector", &http.Server{Addr: cfg.Addr, Handler: mux})
+	return m.Run(context.Background())
+}
@@ -0,0 +1,378 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exporter receives every finished, sampled span.
+type Exporter interface {
+	Export(record Record)
+	// Shutdown flushes buffered spans and releases the exporter.
+	Shutdown(ctx context.Context) error
+}
+
+type nopExporter struct{}
+
+func (nopExporter) Export(Record)                  {}
+func (nopExporter) Shutdown(context.Context) error { return nil }
+
+type exporterHolder struct{ Exporter }
+
+var current atomic.Pointer[exporterHolder]
+
+// SetExporter replaces the exporter spans are sent to. nil disables exporting;
+// spans are still created so traceparent headers keep being propagated.
+func SetExporter(e Exporter) {
+	if e == nil {
+		e = nopExporter{}
+	}
+	current.Store(&exporterHolder{e})
+}
+
+func exporter() Exporter {
+	if holder := current.Load(); holder != nil {
+		return holder.Exporter
+	}
+	return nopExporter{}
+}
+
+// FileExporter appends spans as JSON lines to a file.
+type FileExporter struct {
+	service string
+
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func NewFileExporter(path, service string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening trace file: %w", err)
+	}
+	return &FileExporter{service: service, file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (e *FileExporter) Export(record Record) {
+	record.Service = e.service
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if err := e.encoder.Encode(record); err != nil {
+		slog.Error("Error writing span", "err", err)
+	}
+}
+
+func (e *FileExporter) Shutdown(context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.file.Close()
+}
+
+// OTLPExporter sends spans in batches to an OTLP/HTTP collector, encoded as OTLP JSON.
+// Spans are queued and dropped when the queue is full, so a slow collector never blocks a request.
+type OTLPExporter struct {
+	url     string
+	service string
+	client  *http.Client
+
+	// mutex guards queue against sends after Shutdown closed it
+	mutex  sync.RWMutex
+	closed bool
+	queue  chan Record
+	done   chan struct{}
+}
+
+const (
+	otlpQueueSize     = 2048
+	otlpBatchSize     = 256
+	otlpFlushInterval = 2 * time.Second
+)
+
+// NewOTLPExporter exports to endpoint, the base URL of the collector such as http://localhost:4318.
+func NewOTLPExporter(endpoint, service string) *OTLPExporter {
+	e := &OTLPExporter{
+		url:     strings.TrimRight(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		queue:   make(chan Record, otlpQueueSize),
+		done:    make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+func (e *OTLPExporter) Export(record Record) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if e.closed {
+		return
+	}
+
+	select {
+	case e.queue <- record:
+	default:
+		slog.Warn("Trace queue full, dropping span", "trace_id", record.TraceID, "span_id", record.SpanID)
+	}
+}
+
+func (e *OTLPExporter) loop() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	var batch []Record
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			slog.Error("Error exporting spans", "spans", len(batch), "err", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case record, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (e *OTLPExporter) send(batch []Record) error {
+	body, err := json.Marshal(encodeOTLP(e.service, batch))
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Shutdown stops accepting spans and waits for the last batch to be sent.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.mutex.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mutex.Unlock()
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// The otlp* types are the subset of the OTLP/HTTP JSON encoding of ExportTraceServiceRequest
+// the exporter writes and the collector stand-in reads. IDs are hex strings and times are
+// decimal strings, as the OTLP JSON mapping specifies.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              Kind           `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+const otlpStatusError = 2
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+const scopeName = "github.com/Dav16Akin/go-dictionary/tracing"
+
+func encodeOTLP(service string, records []Record) otlpRequest {
+	spans := make([]otlpSpan, len(records))
+	for i, record := range records {
+		span := otlpSpan{
+			TraceID:           record.TraceID,
+			SpanID:            record.SpanID,
+			ParentSpanID:      record.ParentSpanID,
+			Name:              record.Name,
+			Kind:              kindFromString(record.Kind),
+			StartTimeUnixNano: strconv.FormatInt(record.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(record.End.UnixNano(), 10),
+		}
+		for key, value := range record.Attributes {
+			span.Attributes = append(span.Attributes, otlpKeyValue{Key: key, Value: anyValue(value)})
+		}
+		if record.Error != "" {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: record.Error}
+		}
+		spans[i] = span
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{{Key: "service.name", Value: anyValue(service)}}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: spans}},
+	}}}
+}
+
+func anyValue(v interface{}) otlpAnyValue {
+	switch v := v.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		s := strconv.Itoa(v)
+		return otlpAnyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpAnyValue{IntValue: &s}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	}
+	s := fmt.Sprint(v)
+	return otlpAnyValue{StringValue: &s}
+}
+
+func (v otlpAnyValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.IntValue != nil:
+		if n, err := strconv.ParseInt(*v.IntValue, 10, 64); err == nil {
+			return n
+		}
+		return *v.IntValue
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	}
+	return nil
+}
+
+func kindFromString(kind string) Kind {
+	switch kind {
+	case "server":
+		return KindServer
+	case "client":
+		return KindClient
+	}
+	return KindInternal
+}
+
+func parseUnixNano(s string) time.Time {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return time.Unix(0, n)
+}
+
+// decodeOTLP turns a collector request back into records.
+func decodeOTLP(request otlpRequest) []Record {
+	var records []Record
+	for _, resourceSpans := range request.ResourceSpans {
+		var service string
+		for _, attribute := range resourceSpans.Resource.Attributes {
+			if attribute.Key == "service.name" && attribute.Value.StringValue != nil {
+				service = *attribute.Value.StringValue
+			}
+		}
+
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, span := range scopeSpans.Spans {
+				record := Record{
+					TraceID:      span.TraceID,
+					SpanID:       span.SpanID,
+					ParentSpanID: span.ParentSpanID,
+					Name:         span.Name,
+					Kind:         span.Kind.String(),
+					Service:      service,
+					Start:        parseUnixNano(span.StartTimeUnixNano),
+					End:          parseUnixNano(span.EndTimeUnixNano),
+				}
+				if span.Status.Code == otlpStatusError {
+					record.Error = span.Status.Message
+					if record.Error == "" {
+						record.Error = "error"
+					}
+				}
+				if len(span.Attributes) > 0 {
+					record.Attributes = map[string]interface{}{}
+					for _, attribute := range span.Attributes {
+						record.Attributes[attribute.Key] = attribute.Value.value()
+					}
+				}
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
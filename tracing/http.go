@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Dav16Akin/go-dictionary/internal/recorder"
+)
+
+// Middleware starts a server span for each request, as child of the incoming traceparent header.
+// The span is named after the pattern http.ServeMux matched, or the path when no mux inside set one.
+func Middleware(service string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := StartRemote(r.Context(), r.Header.Get(HeaderTraceparent), r.Method+" "+r.URL.Path, KindServer)
+			defer span.End()
+
+			span.SetAttribute("service", service)
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.target", r.URL.RequestURI())
+
+			r = r.WithContext(ctx)
+			outer := r.Pattern
+
+			rec := recorder.New(w)
+			next.ServeHTTP(rec, r)
+
+			if r.Pattern != "" && r.Pattern != outer {
+				span.SetName(r.Method + " " + r.Pattern)
+			}
+			span.SetAttribute("http.status_code", rec.Status)
+			if rec.Status >= 500 {
+				span.RecordError(fmt.Errorf("HTTP %d", rec.Status))
+			}
+		})
+	}
+}
+
+// Transport propagates the span of the request context to outgoing requests and records a client span.
+type Transport struct {
+	// Base defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := Start(req.Context(), req.Method+" "+req.URL.Host, KindClient)
+	defer span.End()
+
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Redacted())
+
+	// a RoundTripper must not modify the request it was given
+	req = req.Clone(ctx)
+	req.Header.Set(HeaderTraceparent, span.Context().Traceparent())
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= 500 {
+		span.RecordError(fmt.Errorf("HTTP %d", resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// Client is an http.Client whose requests carry the traceparent of their context.
+var Client = &http.Client{Transport: &Transport{}}
@@ -0,0 +1,31 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Dav16Akin/go-dictionary/config"
+)
+
+// Setup installs the exporter cfg selects, reporting spans under service.
+// The returned function flushes and closes the exporter; call it before the process exits.
+func Setup(cfg config.Tracing, service string) (func(ctx context.Context) error, error) {
+	var e Exporter
+	switch cfg.Exporter {
+	case "none":
+		e = nopExporter{}
+	case "file":
+		fileExporter, err := NewFileExporter(cfg.File, service)
+		if err != nil {
+			return nil, err
+		}
+		e = fileExporter
+	case "otlp":
+		e = NewOTLPExporter(cfg.Endpoint, service)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+
+	SetExporter(e)
+	return e.Shutdown, nil
+}
@@ -0,0 +1,283 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderTraceparent is the W3C Trace Context header, https://www.w3.org/TR/trace-context/
+const HeaderTraceparent = "traceparent"
+
+type TraceID [16]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (t TraceID) IsValid() bool  { return t != TraceID{} }
+
+type SpanID [8]byte
+
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats sc as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent reads a traceparent header value. Versions above 00 are accepted as long as
+// they start with the four 00 fields, as the specification asks; version ff is invalid.
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if len(traceID) != 32 || len(spanID) != 16 || len(flags) != 2 ||
+		!isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var sc SpanContext
+	hex.Decode(sc.TraceID[:], []byte(traceID))
+	hex.Decode(sc.SpanID[:], []byte(spanID))
+
+	var flagBits [1]byte
+	hex.Decode(flagBits[:], []byte(flags))
+	sc.Sampled = flagBits[0]&0x01 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Kind says which side of a call a span describes. The values are the OTLP ones.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is one timed operation of a trace.
+type Span struct {
+	mutex sync.Mutex
+
+	name       string
+	kind       Kind
+	context    SpanContext
+	parent     SpanID
+	start      time.Time
+	end        time.Time
+	attributes []Attribute
+	err        string
+	ended      bool
+}
+
+func (s *Span) Context() SpanContext {
+	return s.context
+}
+
+func (s *Span) SetName(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.name = name
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.attributes = append(s.attributes, Attribute{Key: key, Value: value})
+}
+
+// RecordError marks the span as failed. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.err = err.Error()
+}
+
+// End stops the span and hands it to the exporter when the trace is sampled. Calls after the first are ignored.
+func (s *Span) End() {
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	record := s.record()
+	s.mutex.Unlock()
+
+	if s.context.Sampled {
+		exporter().Export(record)
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// SpanFromContext returns the span ctx carries, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemote returns ctx carrying a span context received from another process,
+// which the next Start uses as parent.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// parentOf returns the span context a new span in ctx descends from: the current span,
+// else a remote parent, else nothing.
+func parentOf(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context, true
+	}
+	if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok && sc.IsValid() {
+		return sc, true
+	}
+	return SpanContext{}, false
+}
+
+// Start begins a span as child of the span in ctx, or as root of a new trace.
+// The returned context carries the span and must be passed on for children to attach to it.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	span := &Span{name: name, kind: kind, start: time.Now()}
+
+	if parent, ok := parentOf(ctx); ok {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		rand.Read(span.context.TraceID[:])
+		span.context.Sampled = true
+	}
+	rand.Read(span.context.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartRemote begins a server span as child of a traceparent header value. An empty or invalid
+// header starts a new trace, as the specification requires.
+func StartRemote(ctx context.Context, traceparent, name string, kind Kind) (context.Context, *Span) {
+	if sc, err := ParseTraceparent(traceparent); err == nil {
+		ctx = ContextWithRemote(ctx, sc)
+	}
+	return Start(ctx, name, kind)
+}
+
+// Traceparent returns the header value to send for the span in ctx, or "" when there is none.
+func Traceparent(ctx context.Context) string {
+	if sc, ok := parentOf(ctx); ok {
+		return sc.Traceparent()
+	}
+	return ""
+}
+
+// Record is the exported form of a finished span.
+type Record struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	Service      string                 `json:"service,omitempty"`
+	Start        time.Time              `json:"start"`
+	End          time.Time              `json:"end"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+func (r Record) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+func (r Record) String() string {
+	status := "ok"
+	if r.Error != "" {
+		status = "error: " + r.Error
+	}
+	return fmt.Sprintf("trace=%s span=%s parent=%s %s %q %s %s", r.TraceID, r.SpanID, r.ParentSpanID, r.Kind, r.Name, r.Duration(), status)
+}
+
+func (s *Span) record() Record {
+	record := Record{
+		TraceID: s.context.TraceID.String(),
+		SpanID:  s.context.SpanID.String(),
+		Name:    s.name,
+		Kind:    s.kind.String(),
+		Start:   s.start,
+		End:     s.end,
+		Error:   s.err,
+	}
+	if s.parent.IsValid() {
+		record.ParentSpanID = s.parent.String()
+	}
+	if len(s.attributes) > 0 {
+		record.Attributes = make(map[string]interface{}, len(s.attributes))
+		for _, attribute := range s.attributes {
+			record.Attributes[attribute.Key] = attribute.Value
+		}
+	}
+	return record
+}
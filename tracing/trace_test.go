@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+const (
+	traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	spanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	valid := []struct {
+		value   string
+		sampled bool
+	}{
+		{"00-" + traceID + "-" + spanID + "-01", true},
+		{"00-" + traceID + "-" + spanID + "-00", false},
+		{" 00-" + traceID + "-" + spanID + "-01 ", true},
+		// unknown flag bits are ignored
+		{"00-" + traceID + "-" + spanID + "-03", true},
+		// a later version may append fields
+		{"01-" + traceID + "-" + spanID + "-01-extra", true},
+	}
+	for _, tt := range valid {
+		sc, err := ParseTraceparent(tt.value)
+		if err != nil {
+			t.Errorf("ParseTraceparent(%q): %v", tt.value, err)
+			continue
+		}
+		if sc.TraceID.String() != traceID || sc.SpanID.String() != spanID || sc.Sampled != tt.sampled {
+			t.Errorf("ParseTraceparent(%q) = %s %s sampled=%v", tt.value, sc.TraceID, sc.SpanID, sc.Sampled)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"00-" + traceID + "-" + spanID,
+		"00-" + traceID + "-" + spanID + "-01-extra",
+		"ff-" + traceID + "-" + spanID + "-01",
+		"0-" + traceID + "-" + spanID + "-01",
+		"00-" + traceID[1:] + "-" + spanID + "-01",
+		"00-" + traceID + "-" + spanID[1:] + "-01",
+		"00-" + traceID + "-" + spanID + "-1",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + spanID + "-01",
+		"00-" + traceID + "-00f067aa0ba902bz-01",
+		"00-00000000000000000000000000000000-" + spanID + "-01",
+		"00-" + traceID + "-0000000000000000-01",
+	}
+	for _, value := range invalid {
+		if _, err := ParseTraceparent(value); !errors.Is(err, ErrInvalidTraceparent) {
+			t.Errorf("ParseTraceparent(%q) = %v, want %v", value, err, ErrInvalidTraceparent)
+		}
+	}
+}
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	for _, value := range []string{
+		"00-" + traceID + "-" + spanID + "-01",
+		"00-" + traceID + "-" + spanID + "-00",
+	} {
+		sc, err := ParseTraceparent(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sc.Traceparent(); got != value {
+			t.Errorf("Traceparent() = %q, want %q", got, value)
+		}
+	}
+}
+
+func TestStartRemote(t *testing.T) {
+	ctx, span := StartRemote(context.Background(), "00-"+traceID+"-"+spanID+"-01", "test", KindServer)
+	if got := span.Context().TraceID.String(); got != traceID {
+		t.Errorf("trace ID = %s, want the caller's %s", got, traceID)
+	}
+	if span.parent.String() != spanID {
+		t.Errorf("parent = %s, want %s", span.parent, spanID)
+	}
+
+	// children and outgoing calls carry the server span, not the caller's
+	sc, err := ParseTraceparent(Traceparent(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != traceID || sc.SpanID != span.Context().SpanID {
+		t.Errorf("Traceparent(ctx) = %s, want the span %s", Traceparent(ctx), span.Context().SpanID)
+	}
+
+	_, root := StartRemote(context.Background(), "garbage", "test", KindServer)
+	if root.Context().TraceID.String() == traceID || root.parent.IsValid() {
+		t.Error("an invalid traceparent must start a new trace")
+	}
+	if !root.Context().IsValid() || !root.Context().Sampled {
+		t.Errorf("new root span context %+v, want a valid sampled one", root.Context())
+	}
+}
+
+func TestTraceparentWithoutSpan(t *testing.T) {
+	if got := Traceparent(context.Background()); got != "" {
+		t.Errorf("Traceparent() = %q, want empty", got)
+	}
+}